@@ -0,0 +1,138 @@
+/*
+Contracttest checks that the API server implements the OpenAPI specification in doc/api.yaml.
+It starts the API server in-process (with a temporary SQLite database and a fake clock), then sends a request for every
+operation declared in the spec. For each operation, the response status must be the success status declared in the
+spec, and the response body must conform to the declared schema.
+
+Requests follow a scenario: users log in first, then the following operations use the resources created by the
+previous ones (conversations, messages, groups). Request bodies and parameters are generated from the spec schemas.
+
+After the scenario, error probes check error responses: every operation is called without authentication, with
+identifiers of missing resources, and with a malformed JSON body (where they apply). The error status must be declared
+in the spec for the operation, and the body must conform to the declared schema.
+
+Usage:
+
+	contracttest [flags]
+
+The flags are:
+
+	-spec <path>
+		Path of the OpenAPI document (default: doc/api.yaml).
+
+	-v
+		Print the API server log.
+
+Return values (exit codes):
+
+	0
+		All operations conform to the spec
+
+	> 0
+		At least one response does not conform to the spec, or the check could not run
+*/
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/middleware"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	var specPath = flag.String("spec", "doc/api.yaml", "Path of the OpenAPI document")
+	var verbose = flag.Bool("v", false, "Print the API server log")
+
+	flag.Parse()
+
+	failed, err := run(*specPath, *verbose)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "error: ", err)
+		os.Exit(2)
+	} else if failed > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "%d response(s) do not conform to the spec\n", failed)
+		os.Exit(1)
+	}
+}
+
+// run starts the in-process server, runs the scenario and the error probes, and prints the results. It returns the
+// number of failed requests.
+func run(specPath string, verbose bool) (int, error) {
+	sp, err := loadSpec(specPath)
+	if err != nil {
+		return 0, err
+	}
+	ops, err := sp.operations()
+	if err != nil {
+		return 0, fmt.Errorf("reading operations: %w", err)
+	}
+
+	tmpdir, err := os.MkdirTemp("", "contracttest-")
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = os.RemoveAll(tmpdir) }()
+
+	dbconn, err := sql.Open("sqlite3", filepath.Join(tmpdir, "contracttest.db"))
+	if err != nil {
+		return 0, fmt.Errorf("opening SQLite: %w", err)
+	}
+	defer func() { _ = dbconn.Close() }()
+
+	clock := globaltime.NewFake(time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC))
+	db, err := database.New(dbconn, clock)
+	if err != nil {
+		return 0, fmt.Errorf("creating AppDatabase: %w", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	if verbose {
+		logger.SetOutput(os.Stderr)
+		logger.SetLevel(logrus.DebugLevel)
+	}
+
+	apirouter, err := api.New(api.Config{
+		Logger:   logger,
+		Database: db,
+		Clock:    clock,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating the API server instance: %w", err)
+	}
+	defer func() { _ = apirouter.Close() }()
+
+	router, err := middleware.Chain(apirouter.Handler(), middleware.CORSConfig{})
+	if err != nil {
+		return 0, fmt.Errorf("wrapping the API handler: %w", err)
+	}
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.ConnContext = api.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	failed := 0
+	for _, res := range runScenario(sp, ops, srv.URL, srv.Client()) {
+		if len(res.errs) == 0 {
+			fmt.Printf("ok    %-40s %-6s %s (%d)\n", res.name(), res.op.Method, res.op.Path, res.status)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL  %-40s %-6s %s\n", res.name(), res.op.Method, res.op.Path)
+		for _, e := range res.errs {
+			fmt.Printf("        %s\n", e)
+		}
+	}
+	return failed, nil
+}
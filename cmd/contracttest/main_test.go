@@ -0,0 +1,18 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestContract runs the whole contract test against the spec of the repository, so that a change of the API server
+// that breaks the spec (or the other way around) fails `go test`.
+func TestContract(t *testing.T) {
+	failed, err := run(filepath.Join("..", "..", "doc", "api.yaml"), testing.Verbose())
+	if err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if failed > 0 {
+		t.Fatalf("%d response(s) do not conform to the spec", failed)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// missingID is a value of path parameters that matches every identifier pattern of the spec, but no resource.
+const missingID = "9999999999999999"
+
+// probe is a request that must fail: the error status must be declared for the operation, and the body must conform
+// to the schema declared for that status.
+type probe struct {
+	name   string
+	status int
+
+	// applies returns true if the probe can be sent to the operation
+	applies func(op operation) bool
+
+	// request returns the request of the probe, usually a modified scenario request
+	request func(sp *spec, op operation, st *state, baseURL string) (*http.Request, error)
+}
+
+// probes are sent to every operation they apply to, after the scenario.
+var probes = []probe{
+	{
+		name:    "unauthorized",
+		status:  http.StatusUnauthorized,
+		applies: func(op operation) bool { return op.Secured },
+		request: func(sp *spec, op operation, st *state, baseURL string) (*http.Request, error) {
+			return buildRequest(sp, op, step{op: op.ID, as: -1}, st, baseURL)
+		},
+	},
+	{
+		name:   "not found",
+		status: http.StatusNotFound,
+		applies: func(op operation) bool {
+			for _, p := range op.Params {
+				if p.In == "path" {
+					return true
+				}
+			}
+			return false
+		},
+		request: func(sp *spec, op operation, st *state, baseURL string) (*http.Request, error) {
+			missing := &state{tokens: st.tokens, ids: st.ids, params: map[string]string{}}
+			for _, p := range op.Params {
+				if p.In == "path" {
+					missing.params[p.Name] = missingID
+				}
+			}
+			return buildRequest(sp, op, step{op: op.ID}, missing, baseURL)
+		},
+	},
+	{
+		name:   "bad request",
+		status: http.StatusBadRequest,
+		applies: func(op operation) bool {
+			return op.Body != nil && op.Body.ContentType == "application/json"
+		},
+		request: func(sp *spec, op operation, st *state, baseURL string) (*http.Request, error) {
+			req, err := buildRequest(sp, op, step{op: op.ID}, st, baseURL)
+			if err != nil {
+				return nil, err
+			}
+			req.Body = io.NopCloser(strings.NewReader("{"))
+			req.ContentLength = 1
+			return req, nil
+		},
+	},
+}
+
+// runProbes sends the probes to every operation they apply to. Requests are sent as the first user of the scenario,
+// with the values in `st`.
+func runProbes(sp *spec, ops []operation, st *state, baseURL string, client *http.Client) []result {
+	var results []result
+	for _, p := range probes {
+		for _, op := range ops {
+			if p.applies(op) {
+				results = append(results, runProbe(sp, op, p, st, baseURL, client))
+			}
+		}
+	}
+	return results
+}
+
+// runProbe sends a probe and checks the error response against the spec.
+func runProbe(sp *spec, op operation, p probe, st *state, baseURL string, client *http.Client) result {
+	res := result{op: op, probe: p.name}
+
+	req, err := p.request(sp, op, st, baseURL)
+	if err != nil {
+		res.errs = append(res.errs, fmt.Sprintf("building request: %v", err))
+		return res
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		res.errs = append(res.errs, err.Error())
+		return res
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		res.errs = append(res.errs, fmt.Sprintf("reading response: %v", err))
+		return res
+	}
+	res.status = resp.StatusCode
+
+	resSchema, declared := op.Responses[resp.StatusCode]
+	if !declared {
+		res.errs = append(res.errs, fmt.Sprintf("status %d is not declared in the spec", resp.StatusCode))
+	}
+	if resp.StatusCode != p.status {
+		res.errs = append(res.errs, fmt.Sprintf("status %d, want %d", resp.StatusCode, p.status))
+		return res
+	} else if !declared {
+		return res
+	} else if resSchema == nil {
+		res.errs = append(res.errs, fmt.Sprintf("no JSON schema declared for status %d", resp.StatusCode))
+		return res
+	}
+
+	var reply interface{}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		res.errs = append(res.errs, fmt.Sprintf("invalid JSON response: %v", err))
+		return res
+	}
+	res.errs = append(res.errs, sp.validate(reply, resSchema, "$")...)
+	return res
+}
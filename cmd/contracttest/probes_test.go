@@ -0,0 +1,123 @@
+package main
+
+import (
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// probeSpec declares an authenticated operation with a JSON error body for 401, and a public one without error bodies.
+const probeSpec = `
+security:
+  - bearerAuth: []
+paths:
+  /items/{itemId}:
+    parameters:
+      - name: itemId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      operationId: getItem
+      responses:
+        "200":
+          description: The item
+        "401":
+          description: Unauthorized
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Error'
+  /public:
+    get:
+      operationId: getPublic
+      security: []
+      responses:
+        "200":
+          description: Public data
+        "404":
+          description: Not found
+components:
+  schemas:
+    Error:
+      type: object
+      properties:
+        error:
+          type: string
+      required: [error]
+`
+
+func parseProbeSpec(t *testing.T) (*spec, map[string]operation) {
+	t.Helper()
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(probeSpec), &raw); err != nil {
+		t.Fatalf("parsing probe spec: %v", err)
+	}
+	sp := &spec{root: normalize(raw).(map[string]interface{})}
+	ops, err := sp.operations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[string]operation{}
+	for _, op := range ops {
+		byID[op.ID] = op
+	}
+	return sp, byID
+}
+
+func TestOperationSecured(t *testing.T) {
+	_, ops := parseProbeSpec(t)
+	if !ops["getItem"].Secured {
+		t.Error("getItem inherits the global security, but it's not secured")
+	}
+	if ops["getPublic"].Secured {
+		t.Error("getPublic overrides the global security, but it's secured")
+	}
+}
+
+func TestRunProbe(t *testing.T) {
+	sp, ops := parseProbeSpec(t)
+	unauthorized, notFound := probes[0], probes[1]
+
+	for _, tc := range []struct {
+		name    string
+		op      string
+		probe   probe
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"conforming", "getItem", unauthorized, 401, `{"error": "missing token"}`, ""},
+		{"invalid body", "getItem", unauthorized, 401, `{"message": "missing token"}`, "missing required field"},
+		{"not JSON", "getItem", unauthorized, 401, `missing token`, "invalid JSON response"},
+		{"wrong status", "getItem", unauthorized, 403, `{"error": "forbidden"}`, "status 403 is not declared"},
+		{"no schema", "getPublic", notFound, 404, `{"error": "not found"}`, "no JSON schema declared for status 404"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			st := &state{params: map[string]string{}}
+			res := runProbe(sp, ops[tc.op], tc.probe, st, srv.URL, srv.Client())
+			if tc.wantErr == "" {
+				if len(res.errs) > 0 {
+					t.Fatalf("errors = %v, want none", res.errs)
+				}
+				return
+			}
+			for _, e := range res.errs {
+				if strings.Contains(e, tc.wantErr) {
+					return
+				}
+			}
+			t.Fatalf("errors = %v, want one containing %q", res.errs, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// photo is a valid Base64 image used in requests.
+const photo = "aGVsbG8="
+
+// state contains the values produced by previous steps of the scenario, used to build the following requests.
+type state struct {
+	// tokens contains the bearer token of each user logged in by the scenario
+	tokens []string
+
+	// ids contains the identifier of each user logged in by the scenario
+	ids []string
+
+	// params contains the values for path parameters, by parameter name
+	params map[string]string
+}
+
+// step is a single request of the scenario. The request body is generated from the schema in the spec, then fields
+// in body() (if any) override the generated ones.
+type step struct {
+	op string
+
+	// as is the index of the user sending the request, or -1 for anonymous requests
+	as int
+
+	body    func(st *state) map[string]interface{}
+	query   func(st *state) map[string]string
+	capture func(st *state, reply map[string]interface{})
+}
+
+// scenario lists the requests in an order where each operation can use the resources created by the previous ones:
+// users log in first, then conversations, messages and groups are created, and finally resources are removed.
+// Operations in the spec and missing here are executed at the end, with generated values.
+var scenario = []step{
+	{op: "doLogin", as: -1, body: login("alice_ct"), capture: captureUser},
+	{op: "doLogin", as: -1, body: login("bob_ct"), capture: captureUser},
+	{op: "doLogin", as: -1, body: login("carol_ct"), capture: captureUser},
+	{op: "setMyUserName", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"name": "alice_ct2"}
+	}},
+	{op: "setMyPhoto", as: 0},
+	{op: "searchUsers", as: 0, query: func(st *state) map[string]string {
+		return map[string]string{"name": "bob"}
+	}},
+	{op: "startNewConversation", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"userId": st.userID(1)}
+	}, capture: captureParam("conversationId")},
+	{op: "setConversationSettings", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"archived": false, "pinned": true}
+	}},
+	{op: "getMyConversations", as: 0},
+	{op: "sendMessage", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"content": "Hello from the contract test"}
+	}, capture: captureParam("messageId")},
+	{op: "editMessage", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"content": "Hello from the contract test (edited)"}
+	}},
+	{op: "getMessageHistory", as: 1},
+	{op: "getConversation", as: 1},
+	{op: "searchMessages", as: 1, query: func(st *state) map[string]string {
+		return map[string]string{"q": "contract"}
+	}},
+	{op: "markConversationRead", as: 1, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"messageId": st.params["messageId"]}
+	}},
+	{op: "commentMessage", as: 1},
+	{op: "uncommentMessage", as: 1},
+	{op: "forwardMessage", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"conversationIds": []string{st.params["conversationId"]}}
+	}},
+	{op: "createGroup", as: 0, body: func(st *state) map[string]interface{} {
+		members, _ := json.Marshal([]string{st.userID(1)})
+		return map[string]interface{}{"name": "Contract Group", "membersJson": string(members), "image": photo}
+	}, capture: captureParam("groupId")},
+	{op: "setGroupName", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"name": "ct_group"}
+	}},
+	{op: "setGroupPhoto", as: 0},
+	{op: "addToGroup", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"userIds": []string{st.userID(2)}}
+	}},
+	{op: "setGroupMemberRole", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"role": "admin"}
+	}},
+	{op: "setGroupPermissions", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"rename": "admins", "addMembers": "admins", "removeMembers": "admins"}
+	}},
+	{op: "createGroupInvite", as: 0, body: func(st *state) map[string]interface{} {
+		return map[string]interface{}{"maxUses": 5}
+	}, capture: captureField("inviteToken", "token")},
+	{op: "listGroupInvites", as: 0},
+	{op: "leaveGroup", as: 2},
+	{op: "joinGroupByInvite", as: 2},
+	{op: "revokeGroupInvite", as: 0},
+	{op: "blockUser", as: 1},
+	{op: "getBlockedUsers", as: 1},
+	{op: "unblockUser", as: 1},
+	{op: "deleteMessage", as: 0},
+}
+
+// userID returns the identifier of the i-th user logged in by the scenario. If the login failed, it returns a
+// placeholder, so the following steps still run (and fail).
+func (st *state) userID(i int) string {
+	if i >= len(st.ids) {
+		return "missing_user"
+	}
+	return st.ids[i]
+}
+
+func login(name string) func(st *state) map[string]interface{} {
+	return func(st *state) map[string]interface{} {
+		return map[string]interface{}{"name": name, "photo": photo}
+	}
+}
+
+// captureUser saves the identifier returned by doLogin, used both as user ID and as bearer token. The last user
+// logged in is the default value for the `userId` path parameter.
+func captureUser(st *state, reply map[string]interface{}) {
+	id, _ := reply["identifier"].(string)
+	st.ids = append(st.ids, id)
+	st.tokens = append(st.tokens, id)
+	st.params["userId"] = id
+}
+
+// captureParam returns a capture function saving the `id` field of the reply as the value of a path parameter.
+func captureParam(name string) func(st *state, reply map[string]interface{}) {
+	return captureField(name, "id")
+}
+
+// captureField returns a capture function saving the string `field` of the reply as the value of a path parameter.
+func captureField(name string, field string) func(st *state, reply map[string]interface{}) {
+	return func(st *state, reply map[string]interface{}) {
+		if value, ok := reply[field].(string); ok {
+			st.params[name] = value
+		}
+	}
+}
+
+// result is the outcome of a step or of a probe.
+type result struct {
+	op     operation
+	probe  string
+	status int
+	errs   []string
+}
+
+// name returns the operation ID, followed by the probe name (if any).
+func (r result) name() string {
+	if r.probe != "" {
+		return r.op.ID + " (" + r.probe + ")"
+	}
+	return r.op.ID
+}
+
+// runScenario executes the scenario against the server at baseURL, then every operation not covered by the scenario,
+// and finally the error probes.
+func runScenario(sp *spec, ops []operation, baseURL string, client *http.Client) []result {
+	byID := map[string]operation{}
+	for _, op := range ops {
+		byID[op.ID] = op
+	}
+
+	steps := []step{}
+	covered := map[string]bool{}
+	for _, st := range scenario {
+		if _, ok := byID[st.op]; ok {
+			steps = append(steps, st)
+			covered[st.op] = true
+		}
+	}
+	var missing []string
+	for id := range byID {
+		if !covered[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	for _, id := range missing {
+		steps = append(steps, step{op: id})
+	}
+
+	st := &state{params: map[string]string{}}
+	var results []result
+	for _, s := range steps {
+		results = append(results, runStep(sp, byID[s.op], s, st, baseURL, client))
+	}
+	return append(results, runProbes(sp, ops, st, baseURL, client)...)
+}
+
+// runStep sends the request of a step and checks the response against the spec.
+func runStep(sp *spec, op operation, s step, st *state, baseURL string, client *http.Client) result {
+	res := result{op: op}
+
+	req, err := buildRequest(sp, op, s, st, baseURL)
+	if err != nil {
+		res.errs = append(res.errs, fmt.Sprintf("building request: %v", err))
+		return res
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		res.errs = append(res.errs, err.Error())
+		return res
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if op.Streams[resp.StatusCode] {
+		// The stream does not end: only the status and the content type are checked
+		res.status = resp.StatusCode
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+			res.errs = append(res.errs, fmt.Sprintf("content type %q, want text/event-stream", ct))
+		}
+		return res
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		res.errs = append(res.errs, fmt.Sprintf("reading response: %v", err))
+		return res
+	}
+	res.status = resp.StatusCode
+
+	want := op.successStatus()
+	if _, declared := op.Responses[resp.StatusCode]; !declared {
+		res.errs = append(res.errs, fmt.Sprintf("status %d is not declared in the spec", resp.StatusCode))
+	}
+	if resp.StatusCode != want {
+		res.errs = append(res.errs, fmt.Sprintf("status %d, want %d", resp.StatusCode, want))
+		return res
+	}
+
+	resSchema := op.Responses[resp.StatusCode]
+	if resSchema == nil {
+		return res
+	}
+	var reply interface{}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		res.errs = append(res.errs, fmt.Sprintf("invalid JSON response: %v", err))
+		return res
+	}
+	res.errs = append(res.errs, sp.validate(reply, resSchema, "$")...)
+
+	if obj, ok := reply.(map[string]interface{}); ok && s.capture != nil {
+		s.capture(st, obj)
+	}
+	return res
+}
+
+func buildRequest(sp *spec, op operation, s step, st *state, baseURL string) (*http.Request, error) {
+	path := op.Path
+	query := url.Values{}
+	var overrides map[string]string
+	if s.query != nil {
+		overrides = s.query(st)
+	}
+	for _, p := range op.Params {
+		switch p.In {
+		case "path":
+			value, ok := st.params[p.Name]
+			if !ok {
+				value = fmt.Sprint(sp.example(p.Schema))
+			}
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(value))
+		case "query":
+			if value, ok := overrides[p.Name]; ok {
+				query.Set(p.Name, value)
+			} else if p.Required {
+				query.Set(p.Name, fmt.Sprint(sp.example(p.Schema)))
+			}
+		}
+	}
+
+	target := baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	var contentType string
+	if op.Body != nil {
+		value := sp.example(op.Body.Schema)
+		if fields, ok := value.(map[string]interface{}); ok && s.body != nil {
+			for k, v := range s.body(st) {
+				fields[k] = v
+			}
+		}
+
+		var err error
+		body, contentType, err = encodeBody(op.Body.ContentType, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(op.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if s.as >= 0 && s.as < len(st.tokens) {
+		req.Header.Set("Authorization", "Bearer "+st.tokens[s.as])
+	}
+	return req, nil
+}
+
+// encodeBody encodes the request body according to the content type declared in the spec. JSON and multipart forms
+// are supported; any other content type (e.g., images) is sent as-is.
+func encodeBody(contentType string, value interface{}) (io.Reader, string, error) {
+	switch contentType {
+	case "application/json":
+		data, err := json.Marshal(value)
+		return bytes.NewReader(data), contentType, err
+	case "multipart/form-data":
+		fields, _ := value.(map[string]interface{})
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for name, field := range fields {
+			str, ok := field.(string)
+			if !ok {
+				data, err := json.Marshal(field)
+				if err != nil {
+					return nil, "", err
+				}
+				str = string(data)
+			}
+			if err := mw.WriteField(name, str); err != nil {
+				return nil, "", err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, mw.FormDataContentType(), nil
+	default:
+		return strings.NewReader(fmt.Sprint(value)), contentType, nil
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// patterns caches compiled `pattern` regular expressions.
+var patterns = map[string]*regexp.Regexp{}
+
+// validate checks `value` (decoded from JSON) against the schema, returning a list of violations. `path` is the
+// location of `value` in the document, used in messages (e.g., `$.reactions[0].emoji`).
+//
+// The supported subset of JSON schema is the one used in doc/api.yaml: $ref, allOf, oneOf, type, nullable, properties,
+// required, items, enum, format (date-time), pattern, and length/size limits.
+func (s *spec) validate(value interface{}, sch schema, path string) []string {
+	if sch == nil {
+		return nil
+	}
+
+	// `nullable` can be a sibling of `$ref`, so it's checked before and after resolving the reference
+	if nullable, _ := sch["nullable"].(bool); nullable && value == nil {
+		return nil
+	}
+	sch, err := s.resolve(sch)
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	if nullable, _ := sch["nullable"].(bool); nullable && value == nil {
+		return nil
+	}
+
+	var errs []string
+	if allOf, ok := sch["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			subSchema, _ := sub.(map[string]interface{})
+			errs = append(errs, s.validate(value, subSchema, path)...)
+		}
+	}
+	if oneOf, ok := sch["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			subSchema, _ := sub.(map[string]interface{})
+			if len(s.validate(value, subSchema, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs = append(errs, fmt.Sprintf("%s: matches %d schemas in oneOf, want exactly 1", path, matches))
+		}
+	}
+
+	typ, _ := sch["type"].(string)
+	if typ == "" {
+		typ = impliedType(value, sch)
+	}
+	switch typ {
+	case "object":
+		errs = append(errs, s.validateObject(value, sch, path)...)
+	case "array":
+		errs = append(errs, s.validateArray(value, sch, path)...)
+	case "string":
+		errs = append(errs, validateString(value, sch, path)...)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, fmt.Sprintf("%s: %s, want boolean", path, describe(value)))
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: %s, want %s", path, describe(value), typ))
+		} else if typ == "integer" && n != math.Trunc(n) {
+			errs = append(errs, fmt.Sprintf("%s: %v, want integer", path, n))
+		}
+	}
+
+	if enum, ok := sch["enum"].([]interface{}); ok && value != nil {
+		found := false
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		}
+	}
+	return errs
+}
+
+// impliedType returns the type whose keywords apply to `value` when the schema has no `type`, like the `minLength: 1`
+// alternative next to a `$ref` in allOf. As in JSON schema, keywords for other types are ignored.
+func impliedType(value interface{}, sch schema) string {
+	switch value.(type) {
+	case string:
+		for _, keyword := range []string{"minLength", "maxLength", "pattern", "format"} {
+			if _, ok := sch[keyword]; ok {
+				return "string"
+			}
+		}
+	case []interface{}:
+		for _, keyword := range []string{"items", "minItems", "maxItems"} {
+			if _, ok := sch[keyword]; ok {
+				return "array"
+			}
+		}
+	}
+	if sch["properties"] != nil {
+		return "object"
+	}
+	return ""
+}
+
+func (s *spec) validateObject(value interface{}, sch schema, path string) []string {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s, want object", path, describe(value))}
+	}
+
+	var errs []string
+	required, _ := sch["required"].([]interface{})
+	for _, name := range required {
+		if _, ok := obj[fmt.Sprint(name)]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: missing required field %q", path, name))
+		}
+	}
+
+	properties, _ := sch["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if field, ok := obj[name]; ok {
+			propSchema, _ := properties[name].(map[string]interface{})
+			errs = append(errs, s.validate(field, propSchema, path+"."+name)...)
+		}
+	}
+	return errs
+}
+
+func (s *spec) validateArray(value interface{}, sch schema, path string) []string {
+	arr, ok := value.([]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s, want array", path, describe(value))}
+	}
+
+	var errs []string
+	if min, ok := intValue(sch["minItems"]); ok && len(arr) < min {
+		errs = append(errs, fmt.Sprintf("%s: %d items, want at least %d", path, len(arr), min))
+	}
+	if max, ok := intValue(sch["maxItems"]); ok && len(arr) > max {
+		errs = append(errs, fmt.Sprintf("%s: %d items, want at most %d", path, len(arr), max))
+	}
+	items, _ := sch["items"].(map[string]interface{})
+	for i, item := range arr {
+		errs = append(errs, s.validate(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+	}
+	return errs
+}
+
+func validateString(value interface{}, sch schema, path string) []string {
+	str, ok := value.(string)
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s, want string", path, describe(value))}
+	}
+
+	var errs []string
+	length := utf8.RuneCountInString(str)
+	if min, ok := intValue(sch["minLength"]); ok && length < min {
+		errs = append(errs, fmt.Sprintf("%s: length %d, want at least %d", path, length, min))
+	}
+	if max, ok := intValue(sch["maxLength"]); ok && length > max {
+		errs = append(errs, fmt.Sprintf("%s: length %d, want at most %d", path, length, max))
+	}
+	if pattern, ok := sch["pattern"].(string); ok {
+		re, found := patterns[pattern]
+		if !found {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				return append(errs, fmt.Sprintf("%s: invalid pattern %q in spec: %v", path, pattern, err))
+			}
+			patterns[pattern] = re
+		}
+		if !re.MatchString(str) {
+			errs = append(errs, fmt.Sprintf("%s: %q does not match %q", path, truncate(str), pattern))
+		}
+	}
+	if format, _ := sch["format"].(string); format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %q is not a RFC 3339 date-time", path, truncate(str)))
+		}
+	}
+	return errs
+}
+
+// example generates a value valid for the schema, using `example` values from the spec when available.
+func (s *spec) example(sch schema) interface{} {
+	if sch == nil {
+		return nil
+	}
+	sch, err := s.resolve(sch)
+	if err != nil {
+		return nil
+	}
+	if example, ok := sch["example"]; ok {
+		// Callers modify the generated value (e.g., to override fields), so the spec must not be shared
+		return deepCopy(example)
+	}
+
+	if allOf, ok := sch["allOf"].([]interface{}); ok {
+		var merged interface{}
+		for _, sub := range allOf {
+			subSchema, _ := sub.(map[string]interface{})
+			merged = mergeExamples(merged, s.example(subSchema))
+		}
+		if merged != nil {
+			return merged
+		}
+	}
+
+	switch sch["type"] {
+	case "object":
+		return s.exampleObject(sch)
+	case "array":
+		items, _ := sch["items"].(map[string]interface{})
+		return []interface{}{s.example(items)}
+	case "string":
+		if enum, ok := sch["enum"].([]interface{}); ok && len(enum) > 0 {
+			return enum[0]
+		}
+		if sch["format"] == "date-time" {
+			return "2025-01-01T12:00:00Z"
+		}
+		length := 1
+		if min, ok := intValue(sch["minLength"]); ok && min > length {
+			length = min
+		}
+		return strings.Repeat("a", length)
+	case "boolean":
+		return false
+	case "integer", "number":
+		return 0
+	}
+	if sch["properties"] != nil {
+		return s.exampleObject(sch)
+	}
+	return nil
+}
+
+// exampleObject generates the required properties of an object schema, including those required by the first
+// `oneOf` alternative.
+func (s *spec) exampleObject(sch schema) map[string]interface{} {
+	required, _ := sch["required"].([]interface{})
+	if oneOf, ok := sch["oneOf"].([]interface{}); ok && len(oneOf) > 0 {
+		if first, ok := oneOf[0].(map[string]interface{}); ok {
+			alternative, _ := first["required"].([]interface{})
+			required = append(append([]interface{}{}, required...), alternative...)
+		}
+	}
+
+	properties, _ := sch["properties"].(map[string]interface{})
+	obj := map[string]interface{}{}
+	for _, name := range required {
+		propSchema, _ := properties[fmt.Sprint(name)].(map[string]interface{})
+		obj[fmt.Sprint(name)] = s.example(propSchema)
+	}
+	return obj
+}
+
+// mergeExamples combines the examples of two `allOf` alternatives: objects are merged, otherwise the first non-nil
+// value wins.
+func mergeExamples(a interface{}, b interface{}) interface{} {
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	if okA && okB {
+		for k, v := range mb {
+			ma[k] = v
+		}
+		return ma
+	}
+	if a != nil {
+		return a
+	}
+	return b
+}
+
+// deepCopy returns a copy of a value decoded from the spec, copying nested maps and slices.
+func deepCopy(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[k] = deepCopy(item)
+		}
+		return m
+	case []interface{}:
+		arr := make([]interface{}, len(value))
+		for i, item := range value {
+			arr[i] = deepCopy(item)
+		}
+		return arr
+	default:
+		return v
+	}
+}
+
+func intValue(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+func describe(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "..."
+	}
+	return s
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"strings"
+	"testing"
+)
+
+// testSpec is a small OpenAPI document using every schema feature supported by the validator.
+const testSpec = `
+components:
+  schemas:
+    Id:
+      type: string
+      pattern: '^[a-z0-9]+$'
+      minLength: 2
+      maxLength: 8
+    Tag:
+      type: object
+      example: {"label": "news", "colors": ["red"]}
+      properties:
+        label:
+          type: string
+        colors:
+          type: array
+          items:
+            type: string
+    Item:
+      type: object
+      required: [id, kind, count, createdAt, tags]
+      properties:
+        id:
+          $ref: '#/components/schemas/Id'
+        kind:
+          type: string
+          enum: ["a", "b"]
+        count:
+          type: integer
+        ratio:
+          type: number
+        enabled:
+          type: boolean
+        createdAt:
+          type: string
+          format: date-time
+        parentId:
+          $ref: '#/components/schemas/Id'
+          nullable: true
+        tags:
+          type: array
+          minItems: 1
+          maxItems: 2
+          items:
+            $ref: '#/components/schemas/Tag'
+        photo:
+          allOf:
+            - type: string
+              maxLength: 4
+            - minLength: 1
+    Choice:
+      type: object
+      properties:
+        text:
+          type: string
+        image:
+          type: string
+      oneOf:
+        - type: object
+          required: [text]
+        - type: object
+          required: [image]
+`
+
+func parseTestSpec(t *testing.T) *spec {
+	t.Helper()
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(testSpec), &raw); err != nil {
+		t.Fatalf("parsing test spec: %v", err)
+	}
+	return &spec{root: normalize(raw).(map[string]interface{})}
+}
+
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+// decode converts a JSON document to the values produced by encoding/json, like the responses checked by validate.
+func decode(t *testing.T, doc string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", doc, err)
+	}
+	return v
+}
+
+const validItem = `{
+	"id": "abc1",
+	"kind": "a",
+	"count": 3,
+	"ratio": 0.5,
+	"enabled": true,
+	"createdAt": "2025-01-01T12:00:00Z",
+	"parentId": null,
+	"tags": [{"label": "x", "colors": []}],
+	"photo": "aGk="
+}`
+
+func TestValidateValid(t *testing.T) {
+	sp := parseTestSpec(t)
+	if errs := sp.validate(decode(t, validItem), ref("Item"), "$"); len(errs) != 0 {
+		t.Fatalf("valid item rejected: %v", errs)
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+	sp := parseTestSpec(t)
+	for _, tc := range []struct {
+		name string
+		// patch replaces fields of validItem; a nil value removes the field
+		patch map[string]interface{}
+		want  string
+	}{
+		{"MissingRequired", map[string]interface{}{"kind": nil}, `$: missing required field "kind"`},
+		{"WrongType", map[string]interface{}{"count": "3"}, "$.count: string, want integer"},
+		{"NotInteger", map[string]interface{}{"count": 1.5}, "$.count: 1.5, want integer"},
+		{"WrongBoolean", map[string]interface{}{"enabled": 1.0}, "$.enabled: number, want boolean"},
+		{"Enum", map[string]interface{}{"kind": "c"}, "$.kind: c is not one of [a b]"},
+		{"Pattern", map[string]interface{}{"id": "ABC"}, `$.id: "ABC" does not match`},
+		{"MinLength", map[string]interface{}{"id": "a"}, "$.id: length 1, want at least 2"},
+		{"MaxLength", map[string]interface{}{"id": "abcdefghi"}, "$.id: length 9, want at most 8"},
+		{"DateTime", map[string]interface{}{"createdAt": "yesterday"}, `$.createdAt: "yesterday" is not a RFC 3339`},
+		{"MinItems", map[string]interface{}{"tags": []interface{}{}}, "$.tags: 0 items, want at least 1"},
+		{"MaxItems", map[string]interface{}{"tags": []interface{}{
+			map[string]interface{}{}, map[string]interface{}{}, map[string]interface{}{},
+		}}, "$.tags: 3 items, want at most 2"},
+		{"NestedItem", map[string]interface{}{"tags": []interface{}{
+			map[string]interface{}{"colors": "red"},
+		}}, "$.tags[0].colors: string, want array"},
+		{"AllOf", map[string]interface{}{"photo": ""}, "$.photo: length 0, want at least 1"},
+		{"AllOfFirst", map[string]interface{}{"photo": "aGVsbG8="}, "$.photo: length 8, want at most 4"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			item := decode(t, validItem).(map[string]interface{})
+			for k, v := range tc.patch {
+				if v == nil {
+					delete(item, k)
+				} else {
+					item[k] = v
+				}
+			}
+			errs := sp.validate(item, ref("Item"), "$")
+			for _, e := range errs {
+				if strings.Contains(e, tc.want) {
+					return
+				}
+			}
+			t.Fatalf("errors = %q, want one containing %q", errs, tc.want)
+		})
+	}
+}
+
+func TestValidateNullable(t *testing.T) {
+	sp := parseTestSpec(t)
+	item := decode(t, validItem).(map[string]interface{})
+	item["parentId"] = "xyz"
+	if errs := sp.validate(item, ref("Item"), "$"); len(errs) != 0 {
+		t.Fatalf("non-null nullable field rejected: %v", errs)
+	}
+	item["parentId"] = "X"
+	if errs := sp.validate(item, ref("Item"), "$"); len(errs) == 0 {
+		t.Fatal("invalid non-null value of a nullable field accepted")
+	}
+
+	// Fields without `nullable` reject null
+	item = decode(t, validItem).(map[string]interface{})
+	item["id"] = nil
+	if errs := sp.validate(item, ref("Item"), "$"); len(errs) != 1 || errs[0] != "$.id: null, want string" {
+		t.Fatalf("errors = %q, want null rejected", errs)
+	}
+}
+
+func TestValidateOneOf(t *testing.T) {
+	sp := parseTestSpec(t)
+	for _, tc := range []struct {
+		doc   string
+		valid bool
+	}{
+		{`{"text": "hi"}`, true},
+		{`{"image": "aGk="}`, true},
+		{`{}`, false},
+		{`{"text": "hi", "image": "aGk="}`, false},
+	} {
+		errs := sp.validate(decode(t, tc.doc), ref("Choice"), "$")
+		if tc.valid && len(errs) != 0 {
+			t.Errorf("%s rejected: %v", tc.doc, errs)
+		} else if !tc.valid && len(errs) == 0 {
+			t.Errorf("%s accepted", tc.doc)
+		}
+	}
+}
+
+func TestValidateInvalidReference(t *testing.T) {
+	sp := parseTestSpec(t)
+	if errs := sp.validate("x", ref("Missing"), "$"); len(errs) == 0 {
+		t.Fatal("reference to a missing schema accepted")
+	}
+}
+
+func TestExampleIsValid(t *testing.T) {
+	sp := parseTestSpec(t)
+	for _, name := range []string{"Id", "Tag", "Item", "Choice"} {
+		// Round trip through JSON, like a request body
+		data, err := json.Marshal(sp.example(ref(name)))
+		if err != nil {
+			t.Fatalf("%s: encoding example: %v", name, err)
+		}
+		if errs := sp.validate(decode(t, string(data)), ref(name), "$"); len(errs) != 0 {
+			t.Errorf("%s: generated example %s is not valid: %v", name, data, errs)
+		}
+	}
+}
+
+func TestExampleIsCopied(t *testing.T) {
+	sp := parseTestSpec(t)
+
+	// Steps override fields of the generated bodies: changes must not leak into the spec
+	first := sp.example(ref("Tag")).(map[string]interface{})
+	first["label"] = "changed"
+	first["colors"].([]interface{})[0] = "blue"
+
+	second := sp.example(ref("Tag")).(map[string]interface{})
+	if second["label"] != "news" || second["colors"].([]interface{})[0] != "red" {
+		t.Fatalf("example = %v after modifying a previous example, want the value in the spec", second)
+	}
+}
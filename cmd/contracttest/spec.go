@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schema is a JSON schema object from the OpenAPI document.
+type schema = map[string]interface{}
+
+// spec is a parsed OpenAPI document.
+type spec struct {
+	root map[string]interface{}
+}
+
+// operation is a single API operation (path + method) declared in the OpenAPI document.
+type operation struct {
+	ID     string
+	Method string
+	Path   string
+
+	// Params contains path and query parameters, including those declared at path level
+	Params []parameter
+
+	// Body is the request body, or nil if the operation has no request body
+	Body *requestBody
+
+	// Responses maps declared status codes to the schema of the JSON response (nil if there is no JSON content)
+	Responses map[int]schema
+
+	// Streams contains the status codes whose response is a stream of Server-Sent Events (text/event-stream)
+	Streams map[int]bool
+
+	// Secured is true if the operation requires authentication
+	Secured bool
+}
+
+type parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   schema
+}
+
+type requestBody struct {
+	ContentType string
+	Schema      schema
+}
+
+// loadSpec reads and parses the OpenAPI document at path.
+func loadSpec(path string) (*spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	root, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not an OpenAPI document", path)
+	}
+	return &spec{root: root}, nil
+}
+
+// normalize converts the maps decoded by yaml.v2 (with interface{} keys) to maps with string keys, like JSON.
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[fmt.Sprint(k)] = normalize(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// resolve follows the `$ref` of node, if any, returning the referenced object. Only local references (starting with
+// `#/`) are supported.
+func (s *spec) resolve(node map[string]interface{}) (map[string]interface{}, error) {
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node, nil
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("unsupported reference %q", ref)
+		}
+		var cur interface{} = s.root
+		for _, key := range strings.Split(ref[2:], "/") {
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid reference %q", ref)
+			}
+			cur, ok = m[key]
+			if !ok {
+				return nil, fmt.Errorf("reference %q not found", ref)
+			}
+		}
+		if node, ok = cur.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("reference %q is not an object", ref)
+		}
+	}
+	return nil, fmt.Errorf("too many nested references")
+}
+
+// operations returns all operations declared in the document, sorted by path and method.
+func (s *spec) operations() ([]operation, error) {
+	paths, _ := s.root["paths"].(map[string]interface{})
+
+	var ops []operation
+	for path, rawItem := range paths {
+		item, ok := rawItem.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %s: invalid path item", path)
+		}
+		common, err := s.parameters(item["parameters"])
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %w", path, err)
+		}
+
+		for _, method := range []string{"get", "put", "post", "delete", "patch"} {
+			rawOp, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			op, err := s.operation(path, strings.ToUpper(method), rawOp, common)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops, nil
+}
+
+func (s *spec) operation(path string, method string, raw map[string]interface{}, common []parameter) (operation, error) {
+	op := operation{
+		Method:    method,
+		Path:      path,
+		Responses: map[int]schema{},
+		Streams:   map[int]bool{},
+	}
+	op.ID, _ = raw["operationId"].(string)
+	if op.ID == "" {
+		return op, fmt.Errorf("missing operationId")
+	}
+
+	security, ok := raw["security"].([]interface{})
+	if !ok {
+		security, _ = s.root["security"].([]interface{})
+	}
+	op.Secured = len(security) > 0
+
+	params, err := s.parameters(raw["parameters"])
+	if err != nil {
+		return op, err
+	}
+	op.Params = append(append(op.Params, common...), params...)
+
+	if rawBody, ok := raw["requestBody"].(map[string]interface{}); ok {
+		body, err := s.resolve(rawBody)
+		if err != nil {
+			return op, err
+		}
+		content, _ := body["content"].(map[string]interface{})
+		var types []string
+		for contentType := range content {
+			types = append(types, contentType)
+		}
+		if len(types) == 0 {
+			return op, fmt.Errorf("request body without content")
+		}
+		sort.Strings(types)
+		media, _ := content[types[0]].(map[string]interface{})
+		bodySchema, _ := media["schema"].(map[string]interface{})
+		op.Body = &requestBody{ContentType: types[0], Schema: bodySchema}
+	}
+
+	responses, _ := raw["responses"].(map[string]interface{})
+	for code, rawRes := range responses {
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			return op, fmt.Errorf("unsupported response code %q", code)
+		}
+		res, ok := rawRes.(map[string]interface{})
+		if !ok {
+			return op, fmt.Errorf("response %s: invalid response", code)
+		}
+		if res, err = s.resolve(res); err != nil {
+			return op, fmt.Errorf("response %s: %w", code, err)
+		}
+		content, _ := res["content"].(map[string]interface{})
+		_, op.Streams[status] = content["text/event-stream"]
+		media, _ := content["application/json"].(map[string]interface{})
+		resSchema, _ := media["schema"].(map[string]interface{})
+		op.Responses[status] = resSchema
+	}
+	return op, nil
+}
+
+func (s *spec) parameters(raw interface{}) ([]parameter, error) {
+	list, _ := raw.([]interface{})
+	var params []parameter
+	for _, rawParam := range list {
+		node, ok := rawParam.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter")
+		}
+		node, err := s.resolve(node)
+		if err != nil {
+			return nil, err
+		}
+		var p parameter
+		p.Name, _ = node["name"].(string)
+		p.In, _ = node["in"].(string)
+		p.Required, _ = node["required"].(bool)
+		p.Schema, _ = node["schema"].(map[string]interface{})
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+// successStatus returns the lowest 2xx status code declared for the operation, or 0 if none is declared.
+func (op operation) successStatus() int {
+	best := 0
+	for code := range op.Responses {
+		if code >= 200 && code < 300 && (best == 0 || code < best) {
+			best = code
+		}
+	}
+	return best
+}
@@ -1,7 +1,8 @@
 /*
-Healthcheck is a simple program that sends an HTTP request to the local host (self) to a configured port number.
+Healthcheck is a simple program that sends an HTTP request to a server (by default, the local host) and checks the
+response status.
 It's used in environment where you need a simple probe for health checks (e.g., an empty container in docker).
-The probe URL is http://localhost:3000/liveness . Only the port can be changed.
+The default probe URL is http://localhost:3000/liveness .
 
 Usage:
 
@@ -9,40 +10,120 @@ Usage:
 
 The flags are:
 
+	-host <hostname>
+		Change the host where the request is sent (default: localhost).
+
 	-port <1-65535>
-		Change the port where the request is sent.
+		Change the port where the request is sent (default: 3000).
+
+	-scheme <http|https>
+		Change the URL scheme (default: http).
+
+	-insecure
+		Skip the verification of the TLS certificate (only for https).
+
+	-path <path>
+		Change the path of the request (default: /liveness, or /readiness when -ready is set).
+
+	-ready
+		Probe the readiness endpoint instead of the liveness endpoint.
+
+	-timeout <duration>
+		Maximum time for the whole request, including connection and response (default: 5s).
+
+	-status <list>
+		Comma-separated list of HTTP status codes considered successful (default: 200,204).
 
 Return values (exit codes):
 
 	0
-		The request was successful (HTTP 200 or HTTP 204)
+		The request was successful (HTTP status in the expected set)
 
 	> 0
-		The request was not successful (connection error or unexpected HTTP status code)
+		The request was not successful (connection error, timeout, or unexpected HTTP status code)
 */
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
+	var host = flag.String("host", "localhost", "HTTP host for healthcheck")
 	var port = flag.Int("port", 3000, "HTTP port for healthcheck")
+	var scheme = flag.String("scheme", "http", "URL scheme for healthcheck (http or https)")
+	var insecure = flag.Bool("insecure", false, "Skip TLS certificate verification")
+	var path = flag.String("path", "", "HTTP path for healthcheck (default /liveness, or /readiness with -ready)")
+	var ready = flag.Bool("ready", false, "Probe the readiness endpoint instead of the liveness one")
+	var timeout = flag.Duration("timeout", 5*time.Second, "Timeout for the whole request")
+	var status = flag.String("status", "200,204", "Comma-separated list of expected HTTP status codes")
 
 	flag.Parse()
 
-	res, err := http.Get(fmt.Sprintf("http://localhost:%d/liveness", *port))
-	if err != nil {
+	if err := probe(*host, *port, *scheme, *insecure, *path, *ready, *timeout, *status); err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
-	} else if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
-		_ = res.Body.Close()
-		_, _ = fmt.Fprintln(os.Stderr, "Healthcheck request not OK: ", res.Status)
-		os.Exit(1)
 	}
-	_ = res.Body.Close()
 	os.Exit(0)
 }
+
+// probe sends the request and returns an error if the server is not healthy.
+func probe(host string, port int, scheme string, insecure bool, path string, ready bool, timeout time.Duration, status string) error {
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("invalid scheme %q", scheme)
+	} else if port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	} else if timeout <= 0 {
+		return errors.New("timeout must be positive")
+	}
+
+	expected := map[int]bool{}
+	for _, code := range strings.Split(status, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(code))
+		if err != nil {
+			return fmt.Errorf("invalid status code %q", code)
+		}
+		expected[n] = true
+	}
+
+	if path == "" {
+		path = "/liveness"
+		if ready {
+			path = "/readiness"
+		}
+	}
+	probeURL := url.URL{
+		Scheme: scheme,
+		Host:   net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:   path,
+	}
+
+	client := http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: insecure, //nolint:gosec
+			},
+		},
+	}
+
+	res, err := client.Get(probeURL.String())
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if !expected[res.StatusCode] {
+		return fmt.Errorf("healthcheck request not OK: %s", res.Status)
+	}
+	return nil
+}
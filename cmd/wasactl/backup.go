@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+// backupCommand writes an online backup of the database to the file in args[0].
+func backupCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 1 {
+		return errors.New("backup: expected the backup file name")
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if err := database.Backup(context.Background(), db, args[0]); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	fmt.Printf("database backed up to %s\n", args[0])
+	return nil
+}
+
+// restoreCommand replaces the database with the backup file in args[0].
+func restoreCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 1 {
+		return errors.New("restore: expected the backup file name")
+	}
+
+	if err := database.Restore(args[0], cfg.DB.Filename); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	fmt.Printf("database restored from %s (previous database saved as %s.pre-restore)\n", args[0], cfg.DB.Filename)
+	return nil
+}
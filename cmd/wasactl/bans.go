@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+// banCommand bans the user in args[0], and revokes its session: the user cannot log in until unbanned.
+func banCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 1 {
+		return errors.New("ban: expected the user ID")
+	}
+
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	err = db.WithTx(ctx, func(tx database.AppDatabase) error {
+		if err := tx.SetUserBanned(ctx, args[0], true); err != nil {
+			return err
+		}
+		if err := tx.DeleteSession(ctx, args[0]); err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("ban: user %s not found", args[0])
+	} else if err != nil {
+		return fmt.Errorf("ban: %w", err)
+	}
+	fmt.Printf("user %s banned\n", args[0])
+	return nil
+}
+
+// unbanCommand removes the ban on the user in args[0].
+func unbanCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 1 {
+		return errors.New("unban: expected the user ID")
+	}
+
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	err = db.SetUserBanned(context.Background(), args[0], false)
+	if errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("unban: user %s not found", args[0])
+	} else if err != nil {
+		return fmt.Errorf("unban: %w", err)
+	}
+	fmt.Printf("user %s unbanned\n", args[0])
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxListedConversations is the maximum number of conversations printed by `conversations list`.
+const maxListedConversations = 1000
+
+// conversationsCommand runs the `conversations` subcommands: list [user ID], show <conversation ID>.
+func conversationsCommand(cfg WasactlConfiguration, args []string) error {
+	var id int64
+	switch {
+	case len(args) > 0 && args[0] == "list" && len(args) <= 2:
+	case len(args) == 2 && args[0] == "show":
+		var err error
+		if id, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("conversations show: invalid conversation ID %q", args[1])
+		}
+	default:
+		return fmt.Errorf("conversations: invalid subcommand or arguments %q", args)
+	}
+
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	if args[0] == "show" {
+		c, err := db.GetConversation(ctx, id)
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("conversations show: conversation %d not found", id)
+		} else if err != nil {
+			return fmt.Errorf("conversations show: %w", err)
+		}
+		printConversation(os.Stdout, c)
+		return nil
+	}
+
+	var ids []int64
+	if len(args) == 2 {
+		if _, err := db.GetUser(ctx, args[1]); errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("conversations list: user %s not found", args[1])
+		} else if err != nil {
+			return fmt.Errorf("conversations list: %w", err)
+		}
+		ids, err = db.ListUserConversations(ctx, args[1])
+	} else {
+		ids, err = db.ListConversations(ctx, maxListedConversations)
+	}
+	if err != nil {
+		return fmt.Errorf("conversations list: %w", err)
+	}
+	return printConversations(ctx, os.Stdout, db, ids)
+}
+
+// groupsCommand runs the `groups` subcommands: show <group ID>.
+func groupsCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 2 || args[0] != "show" {
+		return errors.New("groups: expected `show <group ID>`")
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("groups show: invalid group ID %q", args[1])
+	}
+
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	c, err := db.GetConversation(context.Background(), id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !c.IsGroup) {
+		return fmt.Errorf("groups show: group %d not found", id)
+	} else if err != nil {
+		return fmt.Errorf("groups show: %w", err)
+	}
+	printConversation(os.Stdout, c)
+	return nil
+}
+
+// conversationName returns the name of a group, or the names of the members of a one-to-one conversation.
+func conversationName(c database.Conversation) string {
+	if c.IsGroup {
+		return c.Name
+	}
+	var names = make([]string, 0, len(c.Members))
+	for _, m := range c.Members {
+		names = append(names, m.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// conversationType returns "group" or "direct".
+func conversationType(c database.Conversation) string {
+	if c.IsGroup {
+		return "group"
+	}
+	return "direct"
+}
+
+// printConversations prints a summary of the conversations `ids` to `w`.
+func printConversations(ctx context.Context, w io.Writer, db database.AppDatabase, ids []int64) error {
+	_, _ = fmt.Fprintf(w, "%-8s  %-6s  %-34s  %-7s  %s\n", "ID", "TYPE", "NAME", "MEMBERS", "CREATED")
+	for _, id := range ids {
+		c, err := db.GetConversation(ctx, id)
+		if err != nil {
+			return fmt.Errorf("conversation %d: %w", id, err)
+		}
+		_, _ = fmt.Fprintf(w, "%-8d  %-6s  %-34s  %-7d  %s\n", c.ID, conversationType(c), conversationName(c),
+			len(c.Members), c.CreatedAt.Format(time.RFC3339))
+	}
+	if len(ids) == maxListedConversations {
+		_, _ = fmt.Fprintf(w, "(only the first %d conversations are listed)\n", maxListedConversations)
+	}
+	return nil
+}
+
+// printConversation prints a conversation to `w`, with the permissions of groups and the roles of the members.
+func printConversation(w io.Writer, c database.Conversation) {
+	_, _ = fmt.Fprintf(w, "id:          %d\n", c.ID)
+	_, _ = fmt.Fprintf(w, "type:        %s\n", conversationType(c))
+	_, _ = fmt.Fprintf(w, "name:        %s\n", conversationName(c))
+	_, _ = fmt.Fprintf(w, "created:     %s\n", c.CreatedAt.Format(time.RFC3339))
+	if c.IsGroup {
+		_, _ = fmt.Fprintf(w, "permissions: rename: %s, add members: %s, remove members: %s\n", c.Permissions.Rename,
+			c.Permissions.AddMembers, c.Permissions.RemoveMembers)
+	}
+	_, _ = fmt.Fprintln(w, "members:")
+	_, _ = fmt.Fprintf(w, "  %-20s  %-16s  %-6s  %s\n", "ID", "NAME", "ROLE", "JOINED")
+	for _, m := range c.Members {
+		_, _ = fmt.Fprintf(w, "  %-20s  %-16s  %-6s  %s\n", m.UserID, m.Name, m.Role, m.JoinedAt.Format(time.RFC3339))
+	}
+}
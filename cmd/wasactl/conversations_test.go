@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database/inmemory"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testData creates alice, bob and carol, the one-to-one conversation of alice and bob, and the group of the three
+// users, returning the IDs of the conversations.
+func testData(t *testing.T, db database.AppDatabase) (int64, int64) {
+	ctx := context.Background()
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := db.CreateUser(ctx, "id_"+name, name, "aGVsbG8="); err != nil {
+			t.Fatalf("CreateUser() error: %v", err)
+		}
+	}
+	direct, err := db.CreateDirectConversation(ctx, "id_alice", "id_bob")
+	if err != nil {
+		t.Fatalf("CreateDirectConversation() error: %v", err)
+	}
+	group, err := db.CreateGroup(ctx, "friends", "aGVsbG8=", []string{"id_alice", "id_bob", "id_carol"})
+	if err != nil {
+		t.Fatalf("CreateGroup() error: %v", err)
+	}
+	if err := db.SetMemberRole(ctx, group, "id_bob", database.RoleAdmin); err != nil {
+		t.Fatalf("SetMemberRole() error: %v", err)
+	}
+	return direct, group
+}
+
+func TestPrintConversations(t *testing.T) {
+	db, err := inmemory.New(globaltime.NewFake(time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("inmemory.New() error: %v", err)
+	}
+	direct, group := testData(t, db)
+	ctx := context.Background()
+
+	var out bytes.Buffer
+	if err := printConversations(ctx, &out, db, []int64{direct, group}); err != nil {
+		t.Fatalf("printConversations() error: %v", err)
+	}
+	want := `ID        TYPE    NAME                                MEMBERS  CREATED
+1         direct  alice, bob                          2        2025-01-01T12:00:00Z
+2         group   friends                             3        2025-01-01T12:00:00Z
+`
+	if out.String() != want {
+		t.Fatalf("printConversations() output:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	c, err := db.GetConversation(ctx, group)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	out.Reset()
+	printConversation(&out, c)
+	want = `id:          2
+type:        group
+name:        friends
+created:     2025-01-01T12:00:00Z
+permissions: rename: members, add members: members, remove members: admins
+members:
+  ID                    NAME              ROLE    JOINED
+  id_alice              alice             owner   2025-01-01T12:00:00Z
+  id_bob                bob               admin   2025-01-01T12:00:00Z
+  id_carol              carol             member  2025-01-01T12:00:00Z
+`
+	if out.String() != want {
+		t.Fatalf("printConversation() output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestConversationsCommands(t *testing.T) {
+	var cfg WasactlConfiguration
+	cfg.DB.Filename = filepath.Join(t.TempDir(), "wasactl.db")
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		t.Fatalf("openAppDatabase() error: %v", err)
+	}
+	direct, group := testData(t, db)
+	closeDB()
+
+	for _, tc := range []struct {
+		cmd  command
+		args []string
+		err  string
+	}{
+		{conversationsCommand, []string{"list"}, ""},
+		{conversationsCommand, []string{"list", "id_carol"}, ""},
+		{conversationsCommand, []string{"show", fmt.Sprint(direct)}, ""},
+		{groupsCommand, []string{"show", fmt.Sprint(group)}, ""},
+		{conversationsCommand, nil, "invalid subcommand"},
+		{conversationsCommand, []string{"list", "id_dave"}, "user id_dave not found"},
+		{conversationsCommand, []string{"show", "x"}, "invalid conversation ID"},
+		{conversationsCommand, []string{"show", "1000"}, "conversation 1000 not found"},
+		{groupsCommand, []string{"show"}, "expected `show <group ID>`"},
+		// One-to-one conversations are not groups
+		{groupsCommand, []string{"show", fmt.Sprint(direct)}, "not found"},
+	} {
+		err := tc.cmd(cfg, tc.args)
+		if tc.err == "" && err != nil {
+			t.Errorf("command %q: error = %v", tc.args, err)
+		} else if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("command %q: error = %v, want %q", tc.args, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/config"
+	"github.com/ardanlabs/conf"
+	"os"
+)
+
+// WasactlConfiguration describes the wasactl configuration. It uses the same environment variables, flags and
+// configuration file as the `webapi` executable, so that wasactl opens the same database. Only the fields needed by
+// wasactl are declared here: other fields in the configuration file are ignored.
+type WasactlConfiguration struct {
+	Config struct {
+		Path string `conf:"default:/conf/config.yml"`
+	}
+	DB   config.DB
+	Args conf.Args
+}
+
+// loadConfiguration creates a WasactlConfiguration starting from flags, environment variables and configuration file,
+// like `webapi` does (see config.Load).
+func loadConfiguration() (WasactlConfiguration, error) {
+	var cfg WasactlConfiguration
+	err := config.Load(os.Args[1:], &cfg, &cfg.Config.Path)
+	if errors.Is(err, conf.ErrHelpWanted) {
+		usage, uerr := config.Usage(&cfg)
+		if uerr != nil {
+			return cfg, uerr
+		}
+		fmt.Println(usage)         //nolint:forbidigo
+		fmt.Println(commandsUsage) //nolint:forbidigo
+	}
+	return cfg, err
+}
@@ -0,0 +1,172 @@
+/*
+Wasactl is the command-line tool for operating a WASAText instance. It opens the same SQLite database used by `webapi`,
+reading the configuration (flags, environment variables and configuration file) in the same way.
+
+Usage:
+
+	wasactl [flags] <command> [arguments]
+
+Flags are handled automatically by the code in `load-configuration.go`; use `wasactl --help` for the list.
+
+The commands are:
+
+	migrate
+		Apply the missing database migrations, and print the schema version.
+
+	stats
+		Print database statistics: schema version, size, journal mode, and number of rows for each table.
+
+	backup <file>
+		Write an online backup of the database to <file>. The database can be in use.
+
+	restore <file>
+		Replace the database with the backup <file>, after checking its integrity. The current database is kept as
+		<database>.pre-restore. Stop webapi before running this command.
+
+	users list
+		Print all users, sorted by name, with the time of their ban (if any).
+
+	users search <query>
+		Print the users whose name contains <query>, ignoring case.
+
+	users rename <user ID> <name>
+		Change the name of a user.
+
+	sessions revoke <user ID>
+		End the session of a user, who must log in again.
+
+	conversations list [user ID]
+		Print the conversations (only those of the user, if given), with their type, name and number of members.
+
+	conversations show <conversation ID>
+		Print a conversation with its members, their roles, and the permissions of groups.
+
+	groups show <group ID>
+		Like `conversations show`, but only for groups.
+
+	ban <user ID>
+		Ban a user and end its session: the user cannot log in until unbanned.
+
+	unban <user ID>
+		Remove the ban on a user.
+
+The commands working on users, conversations and groups apply the missing migrations first, like webapi.
+
+Return values (exit codes):
+
+	0
+		The command completed successfully
+
+	> 0
+		The command failed, or the command line is invalid
+*/
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"github.com/ardanlabs/conf"
+	_ "github.com/mattn/go-sqlite3"
+	"os"
+	"sort"
+)
+
+// commandsUsage is printed after the flags in the help message.
+const commandsUsage = `COMMANDS
+  migrate                         apply the missing database migrations
+  stats                           print database statistics
+  backup <file>                   write an online backup of the database
+  restore <file>                  replace the database with a backup (stop webapi first)
+  users list                      list all users
+  users search <query>            search users by name
+  users rename <user ID> <name>   change the name of a user
+  sessions revoke <user ID>       end the session of a user
+  conversations list [user ID]    list all conversations, or the conversations of a user
+  conversations show <ID>         print a conversation with its members
+  groups show <ID>                print a group with its members, their roles and the permissions
+  ban <user ID>                   ban a user and end its session
+  unban <user ID>                 remove the ban on a user`
+
+// command is the signature of wasactl commands. `args` are the command arguments, without the command name.
+type command func(cfg WasactlConfiguration, args []string) error
+
+var commands = map[string]command{
+	"migrate":       migrateCommand,
+	"stats":         statsCommand,
+	"backup":        backupCommand,
+	"restore":       restoreCommand,
+	"users":         usersCommand,
+	"sessions":      sessionsCommand,
+	"conversations": conversationsCommand,
+	"groups":        groupsCommand,
+	"ban":           banCommand,
+	"unban":         unbanCommand,
+}
+
+// main is the program entry point. The only purpose of this function is to call run() and set the exit code if there is
+// any error
+func main() {
+	if err := run(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "error: ", err)
+		os.Exit(1)
+	}
+}
+
+// run reads the configuration and executes the command.
+func run() error {
+	cfg, err := loadConfiguration()
+	if err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			return nil
+		}
+		return err
+	}
+
+	if len(cfg.Args) == 0 {
+		return fmt.Errorf("missing command\n%s", commandsUsage)
+	}
+	cmd, ok := commands[cfg.Args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", cfg.Args[0], commandsUsage)
+	}
+
+	return cmd(cfg, cfg.Args[1:])
+}
+
+// openDatabase opens the database configured in cfg, with the same connection options as webapi. wasactl runs one
+// operation at a time, so the writer connection is used for everything. The caller must close it.
+func openDatabase(cfg WasactlConfiguration) (*sql.DB, error) {
+	dbconn, err := database.OpenWriter(cfg.DB.Filename, cfg.DB.ConnOptions())
+	if err != nil {
+		return nil, fmt.Errorf("opening SQLite: %w", err)
+	}
+	return dbconn, nil
+}
+
+// openAppDatabase opens the database configured in cfg as database.AppDatabase, applying the missing migrations. The
+// returned function closes the database.
+func openAppDatabase(cfg WasactlConfiguration) (database.AppDatabase, func(), error) {
+	dbconn, err := openDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := database.New(dbconn, globaltime.Real())
+	if err != nil {
+		_ = dbconn.Close()
+		return nil, nil, fmt.Errorf("creating AppDatabase: %w", err)
+	}
+	return db, func() { _ = dbconn.Close() }, nil
+}
+
+// sortedKeys returns the keys of a map of counters, sorted.
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
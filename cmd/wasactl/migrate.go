@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+// migrateCommand applies the missing migrations. The `webapi` executable applies them on startup too: this command is
+// useful to upgrade the database before deploying a new version.
+func migrateCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 0 {
+		return errors.New("migrate: no arguments expected")
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	applied, err := database.Migrate(db)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	fmt.Printf("applied %d migration(s), schema version is %d\n", applied, database.LatestSchemaVersion())
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+// sessionsCommand runs the `sessions` subcommands: revoke <user ID>.
+func sessionsCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 2 || args[0] != "revoke" {
+		return errors.New("sessions: expected `revoke <user ID>`")
+	}
+
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	err = db.DeleteSession(context.Background(), args[1])
+	if errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("sessions revoke: user %s has no session", args[1])
+	} else if err != nil {
+		return fmt.Errorf("sessions revoke: %w", err)
+	}
+	fmt.Printf("session of user %s revoked\n", args[1])
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+// statsCommand prints the database statistics.
+func statsCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) != 0 {
+		return errors.New("stats: no arguments expected")
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	stats, err := database.ReadStats(db)
+	if err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	fmt.Printf("schema version: %d (latest: %d)\n", stats.SchemaVersion, database.LatestSchemaVersion())
+	fmt.Printf("size:           %d bytes (%d bytes free)\n", stats.SizeBytes, stats.FreeBytes)
+	fmt.Printf("journal mode:   %s\n", stats.JournalMode)
+	fmt.Println("tables:")
+	for _, name := range sortedKeys(stats.Tables) {
+		fmt.Printf("  %-30s %d rows\n", name, stats.Tables[name])
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"time"
+)
+
+// maxListedUsers is the maximum number of users printed by `users list` and `users search`.
+const maxListedUsers = 1000
+
+// usersCommand runs the `users` subcommands: list, search <query>, rename <user ID> <name>.
+func usersCommand(cfg WasactlConfiguration, args []string) error {
+	if len(args) == 0 {
+		return errors.New("users: expected a subcommand (list, search, rename)")
+	}
+
+	db, closeDB, err := openAppDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		return printUsers(ctx, db, "")
+	case args[0] == "search" && len(args) == 2:
+		return printUsers(ctx, db, args[1])
+	case args[0] == "rename" && len(args) == 3:
+		err := db.SetUserName(ctx, args[1], args[2])
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("users rename: user %s not found", args[1])
+		} else if errors.Is(err, database.ErrConflict) {
+			return fmt.Errorf("users rename: name %s already in use", args[2])
+		} else if err != nil {
+			return fmt.Errorf("users rename: %w", err)
+		}
+		fmt.Printf("user %s renamed to %s\n", args[1], args[2])
+		return nil
+	default:
+		return fmt.Errorf("users: invalid subcommand or arguments %q", args)
+	}
+}
+
+// printUsers prints the users whose name contains `query` (all users if empty), sorted by name.
+func printUsers(ctx context.Context, db database.AppDatabase, query string) error {
+	users, err := db.SearchUsers(ctx, query, "", maxListedUsers)
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+
+	fmt.Printf("%-20s  %-16s  %-20s  %s\n", "ID", "NAME", "CREATED", "BANNED")
+	for _, u := range users {
+		banned := "-"
+		if !u.BannedAt.IsZero() {
+			banned = u.BannedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%-20s  %-16s  %-20s  %s\n", u.ID, u.Name, u.CreatedAt.Format(time.RFC3339), banned)
+	}
+	if len(users) == maxListedUsers {
+		fmt.Printf("(only the first %d users are listed)\n", maxListedUsers)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/backup"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/pprof"
+)
+
+// newDebugServer returns the debug web server. It serves debug variables (/debug/vars), profiler infos (/debug/pprof/)
+// and administration endpoints. It must not be exposed outside the host/cluster, as administration endpoints have no
+// authentication.
+//
+// Administration endpoints:
+//
+//	POST /debug/backup
+//		Take a database backup immediately. Replies with the backup file path, or HTTP 503 if backups are disabled.
+//
+// `scheduler` can be nil if backups are disabled.
+func newDebugServer(cfg WebAPIConfiguration, logger logrus.FieldLogger, scheduler *backup.Scheduler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/debug/backup", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		} else if scheduler == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		file, err := scheduler.BackupNow(r.Context())
+		if err != nil {
+			logger.WithError(err).Error("on-demand backup failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"file": file})
+	})
+
+	// No write timeout: backups and profiles can take longer than API requests
+	return &http.Server{
+		Addr:              cfg.Web.DebugHost,
+		Handler:           mux,
+		ReadTimeout:       cfg.Web.ReadTimeout,
+		ReadHeaderTimeout: cfg.Web.ReadTimeout,
+	}
+}
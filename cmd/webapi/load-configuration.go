@@ -3,9 +3,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/config"
 	"github.com/ardanlabs/conf"
-	"gopkg.in/yaml.v2"
-	"io"
 	"os"
 	"time"
 )
@@ -18,14 +17,42 @@ type WebAPIConfiguration struct {
 	}
 	Web struct {
 		APIHost         string        `conf:"default:0.0.0.0:3000"`
-		DebugHost       string        `conf:"default:0.0.0.0:4000"`
+		DebugHost       string        `conf:"default:127.0.0.1:4000"`
 		ReadTimeout     time.Duration `conf:"default:5s"`
 		WriteTimeout    time.Duration `conf:"default:5s"`
 		ShutdownTimeout time.Duration `conf:"default:5s"`
+		MaxBodySize     int64         `conf:"default:1048576"`
+		MaxUploadSize   int64         `conf:"default:15728640"`
+		MultipartMemory int64         `conf:"default:1048576"`
 	}
-	Debug bool
-	DB    struct {
-		Filename string `conf:"default:/tmp/decaf.db"`
+	CORS struct {
+		AllowedHeaders []string `conf:"default:Authorization;Content-Type"`
+		ExposedHeaders []string `conf:"default:X-Request-Id"`
+	}
+	RateLimit struct {
+		LoginRequests     int           `conf:"default:10"`
+		LoginPeriod       time.Duration `conf:"default:1m"`
+		MessagesRequests  int           `conf:"default:60"`
+		MessagesPeriod    time.Duration `conf:"default:1m"`
+		ReactionsRequests int           `conf:"default:120"`
+		ReactionsPeriod   time.Duration `conf:"default:1m"`
+		GroupsRequests    int           `conf:"default:30"`
+		GroupsPeriod      time.Duration `conf:"default:1m"`
+		MaxKeys           int           `conf:"default:10000"`
+		IdleTimeout       time.Duration `conf:"default:10m"`
+	}
+	Messages struct {
+		EditWindow         time.Duration `conf:"default:15m"`
+		DeleteWindow       time.Duration `conf:"default:48h"`
+		TombstoneRetention time.Duration `conf:"default:720h"`
+		PurgeInterval      time.Duration `conf:"default:1h"`
+	}
+	Debug  bool
+	DB     config.DB
+	Backup struct {
+		Directory string
+		Interval  time.Duration `conf:"default:24h"`
+		Retention int           `conf:"default:7"`
 	}
 }
 
@@ -36,35 +63,13 @@ type WebAPIConfiguration struct {
 // Note that the configuration file can be specified only via CLI or environment variable.
 func loadConfiguration() (WebAPIConfiguration, error) {
 	var cfg WebAPIConfiguration
-
-	// Try to load configuration from environment variables and command line switches
-	if err := conf.Parse(os.Args[1:], "CFG", &cfg); err != nil {
-		if errors.Is(err, conf.ErrHelpWanted) {
-			usage, err := conf.Usage("CFG", &cfg)
-			if err != nil {
-				return cfg, fmt.Errorf("generating config usage: %w", err)
-			}
-			fmt.Println(usage) //nolint:forbidigo
-			return cfg, conf.ErrHelpWanted
+	err := config.Load(os.Args[1:], &cfg, &cfg.Config.Path)
+	if errors.Is(err, conf.ErrHelpWanted) {
+		usage, uerr := config.Usage(&cfg)
+		if uerr != nil {
+			return cfg, uerr
 		}
-		return cfg, fmt.Errorf("parsing config: %w", err)
+		fmt.Println(usage) //nolint:forbidigo
 	}
-
-	// Override values from YAML if specified and if it exists (useful in k8s/compose)
-	fp, err := os.Open(cfg.Config.Path)
-	if err != nil && !os.IsNotExist(err) {
-		return cfg, fmt.Errorf("can't read the config file, while it exists: %w", err)
-	} else if err == nil {
-		yamlFile, err := io.ReadAll(fp)
-		if err != nil {
-			return cfg, fmt.Errorf("can't read config file: %w", err)
-		}
-		err = yaml.Unmarshal(yamlFile, &cfg)
-		if err != nil {
-			return cfg, fmt.Errorf("can't unmarshal config file: %w", err)
-		}
-		_ = fp.Close()
-	}
-
-	return cfg, nil
+	return cfg, err
 }
@@ -1,8 +1,10 @@
 /*
 Webapi is the executable for the main web server.
 It builds a web server around APIs from `service/api`.
-Webapi connects to external resources needed (database) and starts two web servers: the API web server, and the debug.
-Everything is served via the API web server, except debug variables (/debug/vars) and profiler infos (pprof).
+Webapi connects to external resources needed (database) and starts two web servers: the API web server, and the debug
+(only on the local host by default, and disabled when Web.DebugHost is empty).
+Everything is served via the API web server, except debug variables (/debug/vars), profiler infos (pprof) and
+administration endpoints (see newDebugServer).
 
 Usage:
 
@@ -25,14 +27,15 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/backup"
 	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
 	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/middleware"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/purge"
 	"github.com/ardanlabs/conf"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
 	"math/rand"
 	"net/http"
@@ -54,8 +57,9 @@ func main() {
 // * reads the configuration
 // * creates and configure the logger
 // * connects to any external resources (like databases, authenticators, etc.)
+// * starts background workers (like scheduled backups)
 // * creates an instance of the service/api package
-// * starts the principal web server (using the service/api.Router.Handler() for HTTP handlers)
+// * starts the principal web server (using the service/api.Router.Handler() for HTTP handlers), and the debug server
 // * waits for any termination event: SIGTERM signal (UNIX), non-recoverable server error, etc.
 // * closes the principal web server
 func run() error {
@@ -82,7 +86,12 @@ func run() error {
 
 	// Start Database
 	logger.Println("initializing database support")
-	dbconn, err := sql.Open("sqlite3", cfg.DB.Filename)
+	if !database.FullTextSearch {
+		// Without the full-text index, message searches would scan the messages of the user
+		return errors.New("SQLite FTS5 is not available: build with the sqlite_fts5 tag")
+	}
+	dbopts := cfg.DB.ConnOptions()
+	dbconn, err := database.Open(cfg.DB.Filename, dbopts)
 	if err != nil {
 		logger.WithError(err).Error("error opening SQLite DB")
 		return fmt.Errorf("opening SQLite: %w", err)
@@ -91,12 +100,70 @@ func run() error {
 		logger.Debug("database stopping")
 		_ = dbconn.Close()
 	}()
-	db, err := database.New(dbconn)
+	// Writes go through a separate single connection, to avoid SQLITE_BUSY errors between concurrent writers
+	dbwriter, err := database.OpenWriter(cfg.DB.Filename, dbopts)
+	if err != nil {
+		logger.WithError(err).Error("error opening SQLite DB for writing")
+		return fmt.Errorf("opening SQLite for writing: %w", err)
+	}
+	defer func() {
+		_ = dbwriter.Close()
+	}()
+	db, err := database.NewWithWriter(dbconn, dbwriter, globaltime.Real())
 	if err != nil {
 		logger.WithError(err).Error("error creating AppDatabase")
 		return fmt.Errorf("creating AppDatabase: %w", err)
 	}
 
+	// Start scheduled backups, if enabled
+	var scheduler *backup.Scheduler
+	if cfg.Backup.Directory != "" {
+		logger.Info("initializing database backups")
+		scheduler, err = backup.New(backup.Config{
+			Logger:    logger,
+			Clock:     globaltime.Real(),
+			DB:        dbconn,
+			Directory: cfg.Backup.Directory,
+			Interval:  cfg.Backup.Interval,
+			Retention: cfg.Backup.Retention,
+		})
+		if err != nil {
+			logger.WithError(err).Error("error initializing backups")
+			return fmt.Errorf("initializing backups: %w", err)
+		}
+		defer func() {
+			logger.Debug("backups stopping")
+			_ = scheduler.Close()
+		}()
+	}
+
+	// Start the purge of deleted messages
+	purger, err := purge.New(purge.Config{
+		Logger:    logger,
+		Clock:     globaltime.Real(),
+		DB:        db,
+		Interval:  cfg.Messages.PurgeInterval,
+		Retention: cfg.Messages.TombstoneRetention,
+	})
+	if err != nil {
+		logger.WithError(err).Error("error initializing the purge of deleted messages")
+		return fmt.Errorf("initializing the purge of deleted messages: %w", err)
+	}
+	defer func() {
+		logger.Debug("purge stopping")
+		_ = purger.Close()
+	}()
+
+	// Readiness checks in addition to those of the api package. Multipart uploads spill to the temporary directory
+	readinessChecks := map[string]func(context.Context) error{
+		"purge":   purger.Check,
+		"tempdir": writableDirectory(os.TempDir()),
+	}
+	if scheduler != nil {
+		readinessChecks["backups"] = scheduler.Check
+		readinessChecks["backupdir"] = writableDirectory(cfg.Backup.Directory)
+	}
+
 	// Start (main) API server
 	logger.Info("initializing API server")
 
@@ -113,6 +180,23 @@ func run() error {
 	apirouter, err := api.New(api.Config{
 		Logger:   logger,
 		Database: db,
+		Clock:    globaltime.Real(),
+		RateLimits: api.RateLimitConfig{
+			Login:       api.RateLimit{Requests: cfg.RateLimit.LoginRequests, Period: cfg.RateLimit.LoginPeriod},
+			Messages:    api.RateLimit{Requests: cfg.RateLimit.MessagesRequests, Period: cfg.RateLimit.MessagesPeriod},
+			Reactions:   api.RateLimit{Requests: cfg.RateLimit.ReactionsRequests, Period: cfg.RateLimit.ReactionsPeriod},
+			Groups:      api.RateLimit{Requests: cfg.RateLimit.GroupsRequests, Period: cfg.RateLimit.GroupsPeriod},
+			MaxKeys:     cfg.RateLimit.MaxKeys,
+			IdleTimeout: cfg.RateLimit.IdleTimeout,
+		},
+		BodyLimits: api.BodyLimitConfig{
+			Default:         cfg.Web.MaxBodySize,
+			Uploads:         cfg.Web.MaxUploadSize,
+			MultipartMemory: cfg.Web.MultipartMemory,
+		},
+		EditWindow:      cfg.Messages.EditWindow,
+		DeleteWindow:    cfg.Messages.DeleteWindow,
+		ReadinessChecks: readinessChecks,
 	})
 	if err != nil {
 		logger.WithError(err).Error("error creating the API server instance")
@@ -120,15 +204,16 @@ func run() error {
 	}
 	router := apirouter.Handler()
 
-	router, err = registerWebUI(router)
+	// Register the web UI (if embedded) and apply CORS policy
+	router, err = middleware.Chain(router, middleware.CORSConfig{
+		AllowedHeaders: cfg.CORS.AllowedHeaders,
+		ExposedHeaders: cfg.CORS.ExposedHeaders,
+	})
 	if err != nil {
-		logger.WithError(err).Error("error registering web UI handler")
-		return fmt.Errorf("registering web UI handler: %w", err)
+		logger.WithError(err).Error("error wrapping the API handler")
+		return fmt.Errorf("wrapping the API handler: %w", err)
 	}
 
-	// Apply CORS policy
-	router = applyCORSHandler(router)
-
 	// Create the API server
 	apiserver := http.Server{
 		Addr:              cfg.Web.APIHost,
@@ -136,6 +221,7 @@ func run() error {
 		ReadTimeout:       cfg.Web.ReadTimeout,
 		ReadHeaderTimeout: cfg.Web.ReadTimeout,
 		WriteTimeout:      cfg.Web.WriteTimeout,
+		ConnContext:       api.ConnContext,
 	}
 
 	// Start the service listening for requests in a separate goroutine
@@ -145,6 +231,22 @@ func run() error {
 		logger.Infof("stopping API server")
 	}()
 
+	// Start the debug server (if enabled) in a separate goroutine. Its errors are logged only: the API server keeps
+	// running without it
+	if cfg.Web.DebugHost != "" {
+		debugserver := newDebugServer(cfg, logger, scheduler)
+		go func() {
+			logger.Infof("debug server listening on %s", debugserver.Addr)
+			if err := debugserver.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.WithError(err).Error("debug server error")
+			}
+			logger.Infof("stopping debug server")
+		}()
+		defer func() {
+			_ = debugserver.Close()
+		}()
+	}
+
 	// Waiting for shutdown signal or POSIX signals
 	select {
 	case err := <-serverErrors:
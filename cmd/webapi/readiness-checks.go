@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// writableDirectory returns a readiness check that fails if no file can be created in the directory `dir`.
+func writableDirectory(dir string) func(context.Context) error {
+	return func(context.Context) error {
+		fp, err := os.CreateTemp(dir, ".readiness-")
+		if err != nil {
+			return fmt.Errorf("directory %s is not writable: %w", dir, err)
+		}
+		_ = fp.Close()
+		return os.Remove(fp.Name())
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// maxAddedMembers is the maximum number of users added to a group at once.
+const maxAddedMembers = 50
+
+type addToGroupRequest struct {
+	UserIDs []string `json:"userIds"`
+}
+
+// addToGroup adds the given users to a group. Users who are already members are ignored, users who blocked the
+// authenticated user cannot be added.
+func (rt *_router) addToGroup(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	var req addToGroupRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if len(req.UserIDs) == 0 || len(req.UserIDs) > maxAddedMembers {
+		sendError(w, ctx, http.StatusBadRequest, "invalid number of users")
+		return
+	}
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if err := checkPermission(c, ctx.UserID, c.Permissions.AddMembers); err != nil {
+			return err
+		}
+		for _, userID := range req.UserIDs {
+			if blocked, err := tx.IsBlocked(r.Context(), userID, ctx.UserID); err != nil {
+				return err
+			} else if blocked {
+				return errStatus(http.StatusForbidden, "a user blocked you")
+			}
+			// Read the group again, as the previous users are now members
+			c, err = tx.GetConversation(r.Context(), id)
+			if err != nil {
+				return err
+			}
+			if err := addGroupMember(r.Context(), tx, c, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't add the users to the group")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -31,6 +31,9 @@ func (rt *_router) wrap(fn httpRouterHandler) func(http.ResponseWriter, *http.Re
 			"remote-ip": r.RemoteAddr,
 		})
 
+		// Send the request ID back, so clients can refer to it when reporting issues
+		w.Header().Set("X-Request-Id", ctx.ReqUUID.String())
+
 		// Call the next handler in chain (usually, the handler function for the path)
 		fn(w, r, ps, ctx)
 	}
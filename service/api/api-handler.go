@@ -6,12 +6,74 @@ import (
 
 // Handler returns an instance of httprouter.Router that handle APIs registered here
 func (rt *_router) Handler() http.Handler {
-	// Register routes
+	// Register routes. Rate limits are applied after authentication, so that the buckets are per user (per IP for
+	// the login)
 	rt.router.GET("/", rt.getHelloWorld)
 	rt.router.GET("/context", rt.wrap(rt.getContextReply))
 
+	// Users
+	rt.router.POST("/session", rt.wrap(rt.rateLimited(rateLimitLogin, rt.limitBody(bodyLimitUploads, rt.doLogin))))
+	rt.router.PUT("/me/name", rt.wrap(rt.authenticated(rt.limitBody(bodyLimitDefault, rt.setMyUserName))))
+	rt.router.PUT("/me/photo", rt.wrap(rt.authenticated(rt.limitBody(bodyLimitUploads, rt.setMyPhoto))))
+	rt.router.GET("/me/blocked", rt.wrap(rt.authenticated(rt.getBlockedUsers)))
+	rt.router.PUT("/me/blocked/:userId", rt.wrap(rt.authenticated(rt.blockUser)))
+	rt.router.DELETE("/me/blocked/:userId", rt.wrap(rt.authenticated(rt.unblockUser)))
+	rt.router.GET("/users/search", rt.wrap(rt.authenticated(rt.searchUsers)))
+
+	// Conversations and messages
+	rt.router.GET("/conversations", rt.wrap(rt.authenticated(rt.getMyConversations)))
+	rt.router.POST("/conversations", rt.wrap(rt.authenticated(rt.limitBody(bodyLimitDefault, rt.startNewConversation))))
+	rt.router.GET("/conversations/:conversationId", rt.wrap(rt.authenticated(rt.getConversation)))
+	rt.router.POST("/conversations/:conversationId", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitMessages, rt.limitBody(bodyLimitUploads, rt.sendMessage)))))
+	rt.router.PUT("/conversations/:conversationId/settings", rt.wrap(rt.authenticated(
+		rt.limitBody(bodyLimitDefault, rt.setConversationSettings))))
+	rt.router.POST("/conversations/:conversationId/read", rt.wrap(rt.authenticated(
+		rt.limitBody(bodyLimitDefault, rt.markConversationRead))))
+	// httprouter does not allow GET /messages/search next to GET /messages/:messageId/history, so the search is
+	// served by the wildcard route
+	rt.router.GET("/messages/:messageId", rt.wrap(rt.authenticated(rt.searchMessagesRoute)))
+	rt.router.DELETE("/messages/:messageId", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitMessages, rt.deleteMessage))))
+	rt.router.PATCH("/messages/:messageId", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitMessages, rt.limitBody(bodyLimitDefault, rt.editMessage)))))
+	rt.router.GET("/messages/:messageId/history", rt.wrap(rt.authenticated(rt.getMessageHistory)))
+	rt.router.POST("/messages/:messageId/forward", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitMessages, rt.limitBody(bodyLimitDefault, rt.forwardMessage)))))
+	rt.router.POST("/messages/:messageId/reactions", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitReactions, rt.limitBody(bodyLimitDefault, rt.commentMessage)))))
+	rt.router.DELETE("/messages/:messageId/reactions", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitReactions, rt.uncommentMessage))))
+
+	// Live events
+	rt.router.GET("/events", rt.wrap(rt.authenticated(rt.streamEvents)))
+
+	// Groups
+	rt.router.POST("/groups", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitUploads, rt.createGroup)))))
+	rt.router.PUT("/groups/:groupId/name", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitDefault, rt.setGroupName)))))
+	rt.router.PUT("/groups/:groupId/photo", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitUploads, rt.setGroupPhoto)))))
+	rt.router.POST("/groups/:groupId/members", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitDefault, rt.addToGroup)))))
+	rt.router.DELETE("/groups/:groupId/members/:userId", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.leaveGroup))))
+	rt.router.PUT("/groups/:groupId/members/:userId/role", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitDefault, rt.setGroupMemberRole)))))
+	rt.router.PUT("/groups/:groupId/permissions", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitDefault, rt.setGroupPermissions)))))
+	rt.router.POST("/groups/:groupId/invites", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.limitBody(bodyLimitDefault, rt.createGroupInvite)))))
+	rt.router.GET("/groups/:groupId/invites", rt.wrap(rt.authenticated(rt.listGroupInvites)))
+	rt.router.DELETE("/groups/:groupId/invites/:inviteToken", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.revokeGroupInvite))))
+	rt.router.POST("/invites/:inviteToken/join", rt.wrap(rt.authenticated(
+		rt.rateLimited(rateLimitGroups, rt.joinGroupByInvite))))
+
 	// Special routes
 	rt.router.GET("/liveness", rt.liveness)
+	rt.router.GET("/readiness", rt.readiness)
 
 	return rt.router
 }
@@ -0,0 +1,39 @@
+package api_test
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedRoutes(t *testing.T) {
+	srv := apitest.NewWithConfig(t, func(cfg *api.Config) {
+		cfg.RateLimits = api.RateLimitConfig{
+			Login:    api.RateLimit{Requests: 3, Period: time.Minute},
+			Messages: api.RateLimit{Requests: 1, Period: time.Minute},
+		}
+	})
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	id := srv.StartConversation(alice, bob.ID)
+
+	// Messages are limited per user
+	srv.SendMessage(alice, id, "first")
+	res := srv.DoMultipart(alice, http.MethodPost, "/conversations/"+id, map[string]string{"content": "second"}).
+		AssertStatus(http.StatusTooManyRequests)
+	if got := res.Header.Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q, want 60", got)
+	}
+	srv.SendMessage(bob, id, "bob is not limited")
+
+	// The login is limited per IP
+	srv.LoginAs("carol")
+	srv.Do(nil, http.MethodPost, "/session", map[string]string{"name": "dave", "photo": apitest.Photo}).
+		AssertStatus(http.StatusTooManyRequests)
+
+	srv.Clock.Advance(time.Minute)
+	srv.SendMessage(alice, id, "after a minute")
+	srv.LoginAs("dave")
+}
@@ -11,6 +11,7 @@ Example:
 	apirouter, err := api.New(api.Config{
 		Logger:   logger,
 		Database: appdb,
+		Clock:    globaltime.Real(),
 	})
 	if err != nil {
 		logger.WithError(err).Error("error creating the API server instance")
@@ -37,11 +38,15 @@ See the `main.go` file inside the `cmd/webapi` for a full usage example.
 package api
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
 	"github.com/julienschmidt/httprouter"
 	"github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
 // Config is used to provide dependencies and configuration to the New function.
@@ -51,6 +56,26 @@ type Config struct {
 
 	// Database is the instance of database.AppDatabase where data are saved
 	Database database.AppDatabase
+
+	// Clock is the source of time for the package. Use globaltime.Real() outside tests
+	Clock globaltime.Clock
+
+	// RateLimits contains the rate limits for write endpoints. The zero value disables rate limiting
+	RateLimits RateLimitConfig
+
+	// BodyLimits contains the maximum size of request bodies. Zero limits are disabled
+	BodyLimits BodyLimitConfig
+
+	// EditWindow is the time after sending during which the sender can edit a message. Zero means defaultEditWindow
+	EditWindow time.Duration
+
+	// DeleteWindow is the time after sending during which the sender can delete a message for everyone. Zero means
+	// defaultDeleteWindow
+	DeleteWindow time.Duration
+
+	// ReadinessChecks are run by the readiness endpoint in addition to the checks of the package (database,
+	// migrations, workers), e.g. for background workers and directories. A check fails by returning an error
+	ReadinessChecks map[string]func(context.Context) error
 }
 
 // Router is the package API interface representing an API handler builder
@@ -71,6 +96,24 @@ func New(cfg Config) (Router, error) {
 	if cfg.Database == nil {
 		return nil, errors.New("database is required")
 	}
+	if cfg.Clock == nil {
+		return nil, errors.New("clock is required")
+	}
+
+	if cfg.BodyLimits.MultipartMemory <= 0 {
+		cfg.BodyLimits.MultipartMemory = defaultMultipartMemory
+	}
+	if cfg.EditWindow <= 0 {
+		cfg.EditWindow = defaultEditWindow
+	}
+	if cfg.DeleteWindow <= 0 {
+		cfg.DeleteWindow = defaultDeleteWindow
+	}
+	for _, name := range builtinReadinessChecks {
+		if _, ok := cfg.ReadinessChecks[name]; ok {
+			return nil, fmt.Errorf("readiness check %q is already defined", name)
+		}
+	}
 
 	// Create a new router where we will register HTTP endpoints. The server will pass requests to this router to be
 	// handled.
@@ -79,9 +122,17 @@ func New(cfg Config) (Router, error) {
 	router.RedirectFixedPath = false
 
 	return &_router{
-		router:     router,
-		baseLogger: cfg.Logger,
-		db:         cfg.Database,
+		router:          router,
+		baseLogger:      cfg.Logger,
+		db:              cfg.Database,
+		clock:           cfg.Clock,
+		limiter:         newRateLimiter(cfg.RateLimits, cfg.Clock),
+		bodyLimits:      cfg.BodyLimits,
+		editWindow:      cfg.EditWindow,
+		deleteWindow:    cfg.DeleteWindow,
+		readinessChecks: cfg.ReadinessChecks,
+		events:          newEventHub(),
+		shutdown:        make(chan struct{}),
 	}, nil
 }
 
@@ -93,4 +144,28 @@ type _router struct {
 	baseLogger logrus.FieldLogger
 
 	db database.AppDatabase
+
+	// clock is the source of time; never use time.Now() or timers from the `time` package directly
+	clock globaltime.Clock
+
+	// limiter tracks the token buckets for rate limited endpoints
+	limiter *rateLimiter
+
+	// bodyLimits contains the maximum size of request bodies
+	bodyLimits BodyLimitConfig
+
+	// editWindow is the time after sending during which a message can be edited
+	editWindow time.Duration
+
+	// deleteWindow is the time after sending during which a message can be deleted for everyone
+	deleteWindow time.Duration
+
+	// readinessChecks are the checks of the readiness endpoint added through Config.ReadinessChecks
+	readinessChecks map[string]func(context.Context) error
+
+	// events delivers the changes to the clients connected to streamEvents
+	events *eventHub
+
+	// shutdown is closed when Close is called, so that the readiness probe fails while requests are drained
+	shutdown chan struct{}
 }
@@ -0,0 +1,309 @@
+/*
+Package apitest is the harness for HTTP integration tests of the `api` package. It builds the full handler chain used
+by the `webapi` executable (API router, web UI and CORS policy) on top of a temporary SQLite database and a fake clock,
+and serves it with an httptest.Server.
+
+Example:
+
+	func TestStartConversation(t *testing.T) {
+		srv := apitest.New(t)
+		alice := srv.LoginAs("alice")
+		bob := srv.LoginAs("bob")
+
+		conversationID := srv.StartConversation(alice, bob.ID)
+		srv.Do(bob, http.MethodGet, "/conversations/"+conversationID, nil).
+			AssertStatus(http.StatusOK)
+	}
+
+Each Server is independent: tests using different servers can run in parallel.
+*/
+package apitest
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/middleware"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Epoch is the initial time of the fake clock of every Server.
+var Epoch = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// Photo is a valid Base64 image used when the API requires a photo (login, group creation).
+const Photo = "aGVsbG8="
+
+// Server is an API server for integration tests. It embeds the httptest.Server, so Server.URL and Server.Client() are
+// available.
+type Server struct {
+	*httptest.Server
+
+	// Clock is the fake clock used by the API and the database. Use Clock.Advance to move the time forward
+	Clock *globaltime.Fake
+
+	// DB is the database used by the API, useful to prepare data or to check the outcome of requests
+	DB database.AppDatabase
+
+	t testing.TB
+}
+
+// User is a user logged in with Server.LoginAs.
+type User struct {
+	ID   string
+	Name string
+
+	// Token is the value sent in the `Authorization: Bearer` header
+	Token string
+}
+
+// New creates and starts a new Server. The server and its resources are released when the test ends.
+func New(t testing.TB) *Server {
+	t.Helper()
+	return NewWithConfig(t, nil)
+}
+
+// NewWithConfig is like New, but `configure` (if not nil) can change the configuration of the API router before the
+// server starts, e.g. to enable rate limits or body limits.
+func NewWithConfig(t testing.TB, configure func(cfg *api.Config)) *Server {
+	t.Helper()
+
+	clock := globaltime.NewFake(Epoch)
+
+	dbconn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "apitest.db"))
+	if err != nil {
+		t.Fatalf("opening SQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = dbconn.Close() })
+
+	db, err := database.New(dbconn, clock)
+	if err != nil {
+		t.Fatalf("creating AppDatabase: %v", err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	cfg := api.Config{
+		Logger:   logger,
+		Database: db,
+		Clock:    clock,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	apirouter, err := api.New(cfg)
+	if err != nil {
+		t.Fatalf("creating the API server instance: %v", err)
+	}
+	t.Cleanup(func() { _ = apirouter.Close() })
+
+	router, err := middleware.Chain(apirouter.Handler(), middleware.CORSConfig{
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Request-Id"},
+	})
+	if err != nil {
+		t.Fatalf("wrapping the API handler: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(router)
+	srv.Config.ConnContext = api.ConnContext
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	return &Server{
+		Server: srv,
+		Clock:  clock,
+		DB:     db,
+		t:      t,
+	}
+}
+
+// Do sends a request to the server as `user` (nil for anonymous requests). If body is not nil, it's encoded as JSON,
+// unless it's an io.Reader (sent as-is). The test fails if the request cannot be sent.
+func (s *Server) Do(user *User, method string, path string, body interface{}) *Response {
+	s.t.Helper()
+
+	var rd io.Reader
+	var contentType string
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		rd = b
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatalf("encoding request body: %v", err)
+		}
+		rd = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, rd)
+	if err != nil {
+		s.t.Fatalf("creating request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return s.send(user, req)
+}
+
+// DoMultipart sends a multipart/form-data request to the server as `user` (nil for anonymous requests), with the
+// given form fields.
+func (s *Server) DoMultipart(user *User, method string, path string, fields map[string]string) *Response {
+	s.t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			s.t.Fatalf("encoding multipart field %q: %v", name, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		s.t.Fatalf("encoding multipart body: %v", err)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	if err != nil {
+		s.t.Fatalf("creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return s.send(user, req)
+}
+
+func (s *Server) send(user *User, req *http.Request) *Response {
+	s.t.Helper()
+
+	if user != nil {
+		req.Header.Set("Authorization", "Bearer "+user.Token)
+	}
+
+	res, err := s.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		s.t.Fatalf("%s %s: reading response: %v", req.Method, req.URL.Path, err)
+	}
+	return &Response{Response: res, Body: body, t: s.t}
+}
+
+// LoginAs logs in (or registers) the user with the given name using `doLogin`. The returned identifier is used as
+// bearer token for the following requests.
+func (s *Server) LoginAs(name string) *User {
+	s.t.Helper()
+
+	var reply struct {
+		Identifier string `json:"identifier"`
+	}
+	s.Do(nil, http.MethodPost, "/session", map[string]string{"name": name, "photo": Photo}).
+		AssertStatus(http.StatusCreated).
+		DecodeJSON(&reply)
+	return &User{ID: reply.Identifier, Name: name, Token: reply.Identifier}
+}
+
+// StartConversation starts a one-to-one conversation between `user` and `otherID` using `startNewConversation`, and
+// returns the conversation ID.
+func (s *Server) StartConversation(user *User, otherID string) string {
+	s.t.Helper()
+
+	var reply struct {
+		ID string `json:"id"`
+	}
+	s.Do(user, http.MethodPost, "/conversations", map[string]string{"userId": otherID}).
+		AssertStatus(http.StatusCreated).
+		DecodeJSON(&reply)
+	return reply.ID
+}
+
+// CreateGroup creates a group named `name` with `user` and `memberIDs` using `createGroup`, and returns the group ID.
+func (s *Server) CreateGroup(user *User, name string, memberIDs ...string) string {
+	s.t.Helper()
+
+	members, err := json.Marshal(memberIDs)
+	if err != nil {
+		s.t.Fatalf("encoding members: %v", err)
+	}
+
+	var reply struct {
+		ID string `json:"id"`
+	}
+	s.DoMultipart(user, http.MethodPost, "/groups", map[string]string{
+		"name":        name,
+		"membersJson": string(members),
+		"image":       Photo,
+	}).AssertStatus(http.StatusCreated).DecodeJSON(&reply)
+	return reply.ID
+}
+
+// SendMessage sends a text message to a conversation using `sendMessage`, and returns the message ID.
+func (s *Server) SendMessage(user *User, conversationID string, content string) string {
+	s.t.Helper()
+
+	var reply struct {
+		ID string `json:"id"`
+	}
+	s.DoMultipart(user, http.MethodPost, "/conversations/"+conversationID, map[string]string{"content": content}).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&reply)
+	return reply.ID
+}
+
+// Response is a completed response, with the body already read.
+type Response struct {
+	*http.Response
+	Body []byte
+
+	t testing.TB
+}
+
+// AssertStatus fails the test if the response status code is not `code`.
+func (r *Response) AssertStatus(code int) *Response {
+	r.t.Helper()
+	if r.StatusCode != code {
+		r.t.Fatalf("%s %s: status = %d, want %d; body: %s", r.Request.Method, r.Request.URL.Path, r.StatusCode, code,
+			r.Body)
+	}
+	return r
+}
+
+// DecodeJSON decodes the response body in `v`, failing the test on errors.
+func (r *Response) DecodeJSON(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		r.t.Fatalf("%s %s: decoding JSON response: %v; body: %s", r.Request.Method, r.Request.URL.Path, err, r.Body)
+	}
+	return r
+}
+
+// AssertJSON fails the test if the response body is not the same JSON value as `expected`. Formatting and the order
+// of object keys are ignored.
+func (r *Response) AssertJSON(expected string) *Response {
+	r.t.Helper()
+
+	var want, got interface{}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		r.t.Fatalf("invalid expected JSON: %v", err)
+	}
+	r.DecodeJSON(&got)
+	if !reflect.DeepEqual(want, got) {
+		r.t.Fatalf("%s %s: body = %s, want %s", r.Request.Method, r.Request.URL.Path, r.Body, expected)
+	}
+	return r
+}
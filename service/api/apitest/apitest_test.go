@@ -0,0 +1,89 @@
+package apitest_test
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginAs(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	if alice.ID == "" || alice.Name != "alice" || alice.Token != alice.ID {
+		t.Fatalf("LoginAs() = %+v", alice)
+	}
+
+	// Logging in again returns the same user
+	if again := srv.LoginAs("alice"); again.ID != alice.ID {
+		t.Fatalf("second LoginAs() = %+v, want ID %q", again, alice.ID)
+	}
+	// The token authenticates the requests
+	srv.Do(alice, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+	srv.Do(nil, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusUnauthorized)
+}
+
+func TestHelpers(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+
+	conversationID := srv.StartConversation(alice, bob.ID)
+	groupID := srv.CreateGroup(alice, "The Group", bob.ID)
+	if conversationID == "" || groupID == "" || conversationID == groupID {
+		t.Fatalf("StartConversation() = %q, CreateGroup() = %q", conversationID, groupID)
+	}
+
+	for _, id := range []string{conversationID, groupID} {
+		messageID := srv.SendMessage(alice, id, "hello")
+
+		var c struct {
+			Messages []struct {
+				ID      string `json:"id"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		srv.Do(bob, http.MethodGet, "/conversations/"+id, nil).AssertStatus(http.StatusOK).DecodeJSON(&c)
+		if len(c.Messages) != 1 || c.Messages[0].ID != messageID || c.Messages[0].Content != "hello" {
+			t.Fatalf("messages of %s = %+v", id, c.Messages)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+
+	// Values are encoded as JSON, readers are sent as-is
+	srv.Do(alice, http.MethodPut, "/me/name", map[string]string{"name": "alice2"}).
+		AssertStatus(http.StatusOK).
+		AssertJSON(`{"id": "` + alice.ID + `", "name": "alice2", "photo": "` + apitest.Photo + `"}`)
+	srv.Do(alice, http.MethodPut, "/me/name", strings.NewReader(`{"name": "alice3"}`)).
+		AssertStatus(http.StatusOK)
+
+	res := srv.Do(alice, http.MethodGet, "/context", nil).AssertStatus(http.StatusOK)
+	if res.Header.Get("X-Request-Id") == "" {
+		t.Fatal("missing X-Request-Id header")
+	}
+}
+
+func TestClock(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	id := srv.StartConversation(alice, bob.ID)
+
+	srv.Clock.Advance(time.Hour)
+	srv.SendMessage(alice, id, "an hour later")
+
+	var c struct {
+		Messages []struct {
+			SentAt time.Time `json:"sentAt"`
+		} `json:"messages"`
+	}
+	srv.Do(alice, http.MethodGet, "/conversations/"+id, nil).AssertStatus(http.StatusOK).DecodeJSON(&c)
+	if want := apitest.Epoch.Add(time.Hour); len(c.Messages) != 1 || !c.Messages[0].SentAt.Equal(want) {
+		t.Fatalf("messages = %+v, want sent at %v", c.Messages, want)
+	}
+}
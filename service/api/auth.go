@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+// authenticated wraps a handler so that it's called only for requests of logged in users. The bearer token is the
+// identifier returned by doLogin, and the user must have a session. The user ID is stored in
+// reqcontext.RequestContext.UserID and added to the request logger.
+//
+// Example:
+//
+//	rt.router.PUT("/me/name", rt.wrap(rt.authenticated(rt.setMyUserName)))
+func (rt *_router) authenticated(fn httpRouterHandler) httpRouterHandler {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+		token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if token == "" || !idRx.MatchString(token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendError(w, ctx, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+
+		_, err := rt.db.GetSession(r.Context(), token)
+		if errors.Is(err, database.ErrNotFound) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			sendError(w, ctx, http.StatusUnauthorized, "no active session")
+			return
+		} else if err != nil {
+			sendInternalError(w, ctx, err, "can't read the session")
+			return
+		}
+
+		ctx.UserID = token
+		ctx.Logger = ctx.Logger.WithField("user", token)
+		fn(w, r, ps, ctx)
+	}
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// blockUser makes the authenticated user block the given user. Blocking a user twice has no effect.
+func (rt *_router) blockUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	userID := ps.ByName("userId")
+	if userID == ctx.UserID {
+		sendError(w, ctx, http.StatusBadRequest, "can't block yourself")
+		return
+	}
+
+	err := rt.db.BlockUser(r.Context(), ctx.UserID, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		sendError(w, ctx, http.StatusNotFound, "user not found")
+		return
+	} else if err != nil {
+		sendInternalError(w, ctx, err, "can't block the user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
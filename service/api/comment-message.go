@@ -0,0 +1,49 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+)
+
+type reactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// isValidEmoji returns true if `emoji` is a single printable character.
+func isValidEmoji(emoji string) bool {
+	r, size := utf8.DecodeRuneInString(emoji)
+	return r != utf8.RuneError && size == len(emoji) && unicode.IsGraphic(r) && !unicode.IsSpace(r)
+}
+
+// commentMessage sets the reaction of the authenticated user to the message, replacing the previous one.
+func (rt *_router) commentMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("messageId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "message not found")
+		return
+	}
+	var req reactionRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if !isValidEmoji(req.Emoji) {
+		sendError(w, ctx, http.StatusBadRequest, "the reaction must be a single emoji")
+		return
+	}
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, _, err := memberLiveMessage(r.Context(), tx, id, ctx.UserID); err != nil {
+			return err
+		}
+		return tx.SetReaction(r.Context(), id, ctx.UserID, req.Emoji)
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't add the reaction")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"net/http"
+	"time"
+)
+
+// maxConversationMessages is the maximum number of messages returned with a conversation.
+const maxConversationMessages = 1000
+
+// isMember returns true if the user is a member of the conversation.
+func isMember(c database.Conversation, userID string) bool {
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// memberSettings returns the settings of the conversation for the user, or the zero value if it's not a member.
+func memberSettings(c database.Conversation, userID string) database.ConversationSettings {
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			return m.Settings
+		}
+	}
+	return database.ConversationSettings{}
+}
+
+// memberConversation returns the conversation `id`. It returns an httpError with HTTP 404 if the conversation does not
+// exist, or HTTP 403 if the user is not a member.
+func memberConversation(ctx context.Context, db database.AppDatabase, id int64, userID string) (database.Conversation, error) {
+	c, err := db.GetConversation(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return c, errStatus(http.StatusNotFound, "conversation not found")
+	} else if err != nil {
+		return c, err
+	} else if !isMember(c, userID) {
+		return c, errStatus(http.StatusForbidden, "not a member of the conversation")
+	}
+	return c, nil
+}
+
+// checkNotBlocked returns an httpError with HTTP 403 if `c` is a one-to-one conversation and the other member blocked
+// the user.
+func checkNotBlocked(ctx context.Context, db database.AppDatabase, c database.Conversation, userID string) error {
+	if c.IsGroup {
+		return nil
+	}
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			continue
+		}
+		if blocked, err := db.IsBlocked(ctx, m.UserID, userID); err != nil {
+			return err
+		} else if blocked {
+			return errStatus(http.StatusForbidden, "blocked by the other member of the conversation")
+		}
+	}
+	return nil
+}
+
+// memberMessage returns the message `id` and its conversation. It returns an httpError with HTTP 404 if the message
+// does not exist, or if the user is not a member of its conversation: non-members cannot know whether a message exists.
+func memberMessage(ctx context.Context, db database.AppDatabase, id int64, userID string) (database.Message, database.Conversation, error) {
+	m, err := db.GetMessage(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return m, database.Conversation{}, errStatus(http.StatusNotFound, "message not found")
+	} else if err != nil {
+		return m, database.Conversation{}, err
+	}
+
+	c, err := db.GetConversation(ctx, m.ConversationID)
+	if err != nil {
+		return m, c, err
+	} else if !isMember(c, userID) {
+		return m, c, errStatus(http.StatusNotFound, "message not found")
+	}
+	return m, c, nil
+}
+
+// memberLiveMessage is like memberMessage, but it also returns HTTP 404 if the message has been deleted: tombstones can
+// only be read.
+func memberLiveMessage(ctx context.Context, db database.AppDatabase, id int64, userID string) (database.Message, database.Conversation, error) {
+	m, c, err := memberMessage(ctx, db, id, userID)
+	if err == nil && !m.DeletedAt.IsZero() {
+		return m, c, errStatus(http.StatusNotFound, "message not found")
+	}
+	return m, c, err
+}
+
+// newConversationJSON converts the conversation `c` as seen by `userID` at time `now`: one-to-one conversations have the
+// name and the photo of the other user, and the settings, the read marker and the unread count are the ones of the
+// user.
+func newConversationJSON(ctx context.Context, db database.AppDatabase, c database.Conversation, userID string, now time.Time) (conversationJSON, error) {
+	conv := conversationJSON{
+		ID:      formatID(c.ID),
+		Name:    c.Name,
+		Photo:   c.Photo,
+		IsGroup: c.IsGroup,
+		Members: make([]string, 0, len(c.Members)),
+	}
+	for _, m := range c.Members {
+		conv.Members = append(conv.Members, m.UserID)
+		if m.UserID == userID {
+			conv.Settings = newSettingsJSON(m.Settings, now)
+			if m.LastReadID != 0 {
+				lastRead := formatID(m.LastReadID)
+				conv.LastReadMessageID = &lastRead
+			}
+		} else if !c.IsGroup {
+			other, err := db.GetUser(ctx, m.UserID)
+			if err != nil {
+				return conv, err
+			}
+			conv.Name, conv.Photo = other.Name, other.Photo
+		}
+	}
+
+	last, err := db.ListMessages(ctx, c.ID, userID, 1)
+	if err != nil {
+		return conv, err
+	} else if len(last) > 0 {
+		msg := newMessageJSON(last[0], c)
+		conv.LastMessage = &msg
+	}
+	if conv.UnreadCount, err = db.CountUnread(ctx, c.ID, userID); err != nil {
+		return conv, err
+	}
+	conv.MentionCount, err = db.CountMentions(ctx, c.ID, userID)
+	return conv, err
+}
+
+// readConversation marks all the messages of the conversation as read by the user, and returns the details of the
+// conversation as seen by the user at time `now`, without the messages hidden by the user.
+func readConversation(ctx context.Context, db database.AppDatabase, id int64, userID string, now time.Time) (conversationDetailsJSON, error) {
+	// The messages hidden by the user count as read
+	newest, err := db.ListMessages(ctx, id, "", 1)
+	if err != nil {
+		return conversationDetailsJSON{}, err
+	}
+	if len(newest) > 0 {
+		if err := db.MarkRead(ctx, id, userID, newest[0].ID); err != nil {
+			return conversationDetailsJSON{}, err
+		}
+	}
+	messages, err := db.ListMessages(ctx, id, userID, maxConversationMessages)
+	if err != nil {
+		return conversationDetailsJSON{}, err
+	}
+
+	// Read the conversation after updating the markers, so the state of messages is up-to-date
+	c, err := db.GetConversation(ctx, id)
+	if err != nil {
+		return conversationDetailsJSON{}, err
+	}
+	conv, err := newConversationJSON(ctx, db, c, userID, now)
+	if err != nil {
+		return conversationDetailsJSON{}, err
+	}
+	return conversationDetailsJSON{conversationJSON: conv, Messages: newMessagesJSON(messages, c)}, nil
+}
+
+// createMessage stores a new message in the conversation `c`, with the mentions of the members in its content. The
+// message counts as read by the sender. The conversation is unarchived for the members that did not mute it, and for
+// the mentioned members.
+func createMessage(ctx context.Context, db database.AppDatabase, c database.Conversation, nm database.NewMessage) (messageJSON, error) {
+	nm.Mentions = parseMentions(nm.Content, c)
+	m, err := db.CreateMessage(ctx, nm)
+	if err != nil {
+		return messageJSON{}, err
+	}
+	if err := db.MarkRead(ctx, nm.ConversationID, nm.SenderID, m.ID); err != nil {
+		return messageJSON{}, err
+	} else if err := db.UnarchiveConversation(ctx, nm.ConversationID, mentionedUsers(m.Mentions)); err != nil {
+		return messageJSON{}, err
+	}
+
+	// Read the conversation again, with the marker of the sender
+	c, err = db.GetConversation(ctx, nm.ConversationID)
+	if err != nil {
+		return messageJSON{}, err
+	}
+	return newMessageJSON(m, c), nil
+}
@@ -0,0 +1,388 @@
+package api_test
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type message struct {
+	ID          string     `json:"id"`
+	State       string     `json:"state"`
+	SenderID    string     `json:"senderId"`
+	SenderName  string     `json:"senderName"`
+	Content     string     `json:"content"`
+	ReplyTo     *string    `json:"replyTo"`
+	IsForwarded bool       `json:"isForwarded"`
+	Deleted     bool       `json:"deleted"`
+	EditedAt    *time.Time `json:"editedAt"`
+	Reactions   []struct {
+		Emoji  string `json:"emoji"`
+		UserID string `json:"userId"`
+	} `json:"reactions"`
+	Mentions []mention `json:"mentions"`
+}
+
+type mention struct {
+	UserID string `json:"userId"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+type conversation struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Members     []string  `json:"members"`
+	Photo       string    `json:"photo"`
+	IsGroup     bool      `json:"isGroup"`
+	LastMessage *message  `json:"lastMessage"`
+	Settings    settings  `json:"settings"`
+	Messages    []message `json:"messages"`
+
+	UnreadCount       int     `json:"unreadCount"`
+	LastReadMessageID *string `json:"lastReadMessageId"`
+	MentionCount      int     `json:"mentionCount"`
+}
+
+type settings struct {
+	MutedUntil *time.Time `json:"mutedUntil"`
+	Archived   bool       `json:"archived"`
+	Pinned     bool       `json:"pinned"`
+	PinnedAt   *time.Time `json:"pinnedAt"`
+}
+
+// getConversation reads a conversation as `user`, which marks its messages as read.
+func getConversation(srv *apitest.Server, user *apitest.User, id string) conversation {
+	var c conversation
+	srv.Do(user, http.MethodGet, "/conversations/"+id, nil).AssertStatus(http.StatusOK).DecodeJSON(&c)
+	return c
+}
+
+func TestStartNewConversation(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+
+	id := srv.StartConversation(alice, bob.ID)
+	// Starting it again returns the same conversation, from both sides
+	if again := srv.StartConversation(bob, alice.ID); again != id {
+		t.Fatalf("second startNewConversation = %q, want %q", again, id)
+	}
+
+	var c conversation
+	srv.Do(alice, http.MethodPost, "/conversations", map[string]string{"userId": bob.ID}).
+		AssertStatus(http.StatusCreated).
+		DecodeJSON(&c)
+	if c.Name != "bob" || c.IsGroup || len(c.Members) != 2 || c.LastMessage != nil || c.Messages == nil {
+		t.Fatalf("conversation = %+v", c)
+	}
+
+	srv.Do(alice, http.MethodPost, "/conversations", map[string]string{"userId": "missing"}).
+		AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodPost, "/conversations", map[string]string{"userId": alice.ID}).
+		AssertStatus(http.StatusBadRequest)
+}
+
+func TestConversationMembership(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+
+	srv.Do(carol, http.MethodGet, "/conversations/"+id, nil).AssertStatus(http.StatusForbidden)
+	srv.DoMultipart(carol, http.MethodPost, "/conversations/"+id, map[string]string{"content": "hi"}).
+		AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodGet, "/conversations/1000", nil).AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodGet, "/conversations/abc", nil).AssertStatus(http.StatusNotFound)
+}
+
+func TestMessageStates(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	id := srv.StartConversation(alice, bob.ID)
+	srv.SendMessage(alice, id, "hello")
+
+	state := func() string {
+		t.Helper()
+		var list []conversation
+		srv.Do(alice, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+		return list[0].LastMessage.State
+	}
+	if s := state(); s != "sent" {
+		t.Fatalf("state = %q, want sent", s)
+	}
+
+	// Bob lists the conversations: the message is delivered
+	srv.Do(bob, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusOK)
+	if s := state(); s != "delivered" {
+		t.Fatalf("state after delivery = %q, want delivered", s)
+	}
+
+	// Bob opens the conversation: the message is read
+	c := getConversation(srv, bob, id)
+	if len(c.Messages) != 1 || c.Messages[0].State != "read" || c.Messages[0].SenderName != "alice" {
+		t.Fatalf("messages = %+v", c.Messages)
+	}
+	if s := state(); s != "read" {
+		t.Fatalf("state after reading = %q, want read", s)
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	id := srv.StartConversation(alice, bob.ID)
+	first := srv.SendMessage(alice, id, "hello")
+
+	var reply message
+	srv.DoMultipart(bob, http.MethodPost, "/conversations/"+id, map[string]string{
+		"attachment": apitest.Photo,
+		"replyTo":    first,
+	}).AssertStatus(http.StatusOK).DecodeJSON(&reply)
+	if reply.ReplyTo == nil || *reply.ReplyTo != first || reply.Content != "" || reply.State != "sent" {
+		t.Fatalf("reply = %+v", reply)
+	}
+
+	for _, fields := range []map[string]string{
+		{},
+		{"content": ""},
+		{"attachment": "not base64"},
+		{"content": "hi", "replyTo": "1000"},
+		{"content": "hi", "forwarded": "maybe"},
+	} {
+		srv.DoMultipart(alice, http.MethodPost, "/conversations/"+id, fields).AssertStatus(http.StatusBadRequest)
+	}
+
+	// Messages are sorted from newest to oldest
+	c := getConversation(srv, alice, id)
+	if len(c.Messages) != 2 || c.Messages[0].ID != reply.ID || c.Messages[1].ID != first {
+		t.Fatalf("messages = %+v", c.Messages)
+	}
+}
+
+func TestConversationListOrder(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	withBob := srv.StartConversation(alice, bob.ID)
+	srv.Clock.Advance(time.Minute)
+	withCarol := srv.StartConversation(alice, carol.ID)
+
+	order := func() []string {
+		t.Helper()
+		var list []conversation
+		srv.Do(alice, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+		var ids []string
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+	if got := order(); len(got) != 2 || got[0] != withCarol {
+		t.Fatalf("conversations = %v, want the newest first", got)
+	}
+
+	srv.Clock.Advance(time.Minute)
+	srv.SendMessage(bob, withBob, "hi")
+	if got := order(); len(got) != 2 || got[0] != withBob {
+		t.Fatalf("conversations = %v, want the one with the newest message first", got)
+	}
+}
+
+func TestConversationSettings(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	dave := srv.LoginAs("dave")
+	withBob := srv.StartConversation(alice, bob.ID)
+	withCarol := srv.StartConversation(alice, carol.ID)
+	withDave := srv.StartConversation(alice, dave.ID)
+	setSettings := func(id string, body map[string]interface{}) *apitest.Response {
+		return srv.Do(alice, http.MethodPut, "/conversations/"+id+"/settings", body)
+	}
+	list := func(query string) []string {
+		t.Helper()
+		var list []conversation
+		srv.Do(alice, http.MethodGet, "/conversations"+query, nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+		var ids []string
+		for _, c := range list {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	setSettings(withBob, map[string]interface{}{"archived": false}).AssertStatus(http.StatusBadRequest)
+	setSettings(withBob, map[string]interface{}{"archived": false, "pinned": false, "mutedUntil": apitest.Epoch}).
+		AssertStatus(http.StatusBadRequest)
+	srv.Do(bob, http.MethodPut, "/conversations/"+withCarol+"/settings", map[string]bool{"archived": true, "pinned": true}).
+		AssertStatus(http.StatusForbidden)
+	setSettings("1000", map[string]interface{}{"archived": false, "pinned": false}).AssertStatus(http.StatusNotFound)
+
+	// Pinned conversations come first, the most recently pinned at the top
+	var s settings
+	setSettings(withBob, map[string]interface{}{"archived": false, "pinned": true}).
+		AssertStatus(http.StatusOK).DecodeJSON(&s)
+	if !s.Pinned || s.PinnedAt == nil || !s.PinnedAt.Equal(apitest.Epoch) || s.MutedUntil != nil || s.Archived {
+		t.Fatalf("settings = %+v", s)
+	}
+	srv.Clock.Advance(time.Minute)
+	setSettings(withCarol, map[string]interface{}{"archived": false, "pinned": true}).AssertStatus(http.StatusOK)
+	srv.Clock.Advance(time.Minute)
+	// Pinning again keeps the pin time
+	setSettings(withBob, map[string]interface{}{"archived": false, "pinned": true}).
+		AssertStatus(http.StatusOK).DecodeJSON(&s)
+	if !s.PinnedAt.Equal(apitest.Epoch) {
+		t.Fatalf("pinnedAt = %v, want %v", s.PinnedAt, apitest.Epoch)
+	}
+	srv.SendMessage(alice, withDave, "hi")
+	if got := list(""); len(got) != 3 || got[0] != withCarol || got[1] != withBob || got[2] != withDave {
+		t.Fatalf("conversations = %v, want [%s %s %s]", got, withCarol, withBob, withDave)
+	}
+
+	// Archived conversations are listed only on request
+	setSettings(withCarol, map[string]interface{}{"archived": true, "pinned": false}).AssertStatus(http.StatusOK)
+	mutedUntil := srv.Clock.Now().Add(time.Hour)
+	setSettings(withDave, map[string]interface{}{"archived": true, "pinned": false, "mutedUntil": mutedUntil}).
+		AssertStatus(http.StatusOK).DecodeJSON(&s)
+	if !s.Archived || s.Pinned || s.MutedUntil == nil || !s.MutedUntil.Equal(mutedUntil) {
+		t.Fatalf("settings = %+v", s)
+	}
+	if got := list(""); len(got) != 1 || got[0] != withBob {
+		t.Fatalf("conversations = %v, want [%s]", got, withBob)
+	}
+	if got := list("?archived=true"); len(got) != 3 {
+		t.Fatalf("conversations with the archived ones = %v", got)
+	}
+	srv.Do(alice, http.MethodGet, "/conversations?archived=maybe", nil).AssertStatus(http.StatusBadRequest)
+
+	// A new message unarchives the conversation, unless it's muted
+	srv.SendMessage(carol, withCarol, "hi")
+	srv.SendMessage(dave, withDave, "hi")
+	if got := list(""); len(got) != 2 || got[1] != withCarol {
+		t.Fatalf("conversations after the new messages = %v", got)
+	}
+	srv.Clock.Advance(time.Hour)
+	srv.SendMessage(dave, withDave, "are you there?")
+	if c := getConversation(srv, alice, withDave); c.Settings.Archived || c.Settings.MutedUntil != nil {
+		t.Fatalf("settings after the mute period = %+v", c.Settings)
+	}
+
+	// Settings are personal
+	if c := getConversation(srv, bob, withBob); c.Settings.Pinned || c.Settings.Archived {
+		t.Fatalf("settings of the other member = %+v", c.Settings)
+	}
+}
+
+func TestMarkConversationRead(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	other := srv.StartConversation(alice, carol.ID)
+	first := srv.SendMessage(alice, id, "first")
+	srv.SendMessage(alice, id, "second")
+	last := srv.SendMessage(alice, id, "third")
+	elsewhere := srv.SendMessage(alice, other, "hi carol")
+
+	// Listing the conversations marks the messages as delivered, not read
+	unread := func(user *apitest.User) (int, string) {
+		t.Helper()
+		var list []conversation
+		srv.Do(user, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+		for _, c := range list {
+			if c.ID == id {
+				if c.LastReadMessageID == nil {
+					return c.UnreadCount, ""
+				}
+				return c.UnreadCount, *c.LastReadMessageID
+			}
+		}
+		t.Fatalf("conversation %s not listed", id)
+		return 0, ""
+	}
+	if n, lastRead := unread(bob); n != 3 || lastRead != "" {
+		t.Fatalf("unread = %d, %q; want 3, none", n, lastRead)
+	}
+	if n, lastRead := unread(alice); n != 0 || lastRead != last {
+		t.Fatalf("unread of the sender = %d, %q; want 0, %s", n, lastRead, last)
+	}
+
+	read := func(user *apitest.User, body interface{}) *apitest.Response {
+		return srv.Do(user, http.MethodPost, "/conversations/"+id+"/read", body)
+	}
+	read(bob, map[string]string{"messageId": first}).AssertStatus(http.StatusNoContent)
+	if n, lastRead := unread(bob); n != 2 || lastRead != first {
+		t.Fatalf("unread = %d, %q; want 2, %s", n, lastRead, first)
+	}
+
+	read(carol, nil).AssertStatus(http.StatusForbidden)
+	read(bob, map[string]string{"messageId": elsewhere}).AssertStatus(http.StatusNotFound)
+	read(bob, map[string]string{"messageId": "x"}).AssertStatus(http.StatusNotFound)
+	read(bob, strings.NewReader("{")).AssertStatus(http.StatusBadRequest)
+
+	// Without a message, the marker moves to the newest one
+	read(bob, nil).AssertStatus(http.StatusNoContent)
+	read(bob, map[string]string{"messageId": first}).AssertStatus(http.StatusNoContent)
+	if n, lastRead := unread(bob); n != 0 || lastRead != last {
+		t.Fatalf("unread = %d, %q; want 0, %s", n, lastRead, last)
+	}
+}
+
+func TestForwardMessage(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	withBob := srv.StartConversation(alice, bob.ID)
+	withCarol := srv.StartConversation(alice, carol.ID)
+	bobCarol := srv.StartConversation(bob, carol.ID)
+	id := srv.SendMessage(bob, withBob, "forward me")
+
+	var copied message
+	srv.Do(alice, http.MethodPost, "/messages/"+id+"/forward", map[string][]string{"conversationIds": {withCarol}}).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&copied)
+	if !copied.IsForwarded || copied.SenderID != alice.ID || copied.Content != "forward me" || copied.ID == id {
+		t.Fatalf("forwarded message = %+v", copied)
+	}
+
+	// Alice is not a member of the target, and Carol cannot see the original message
+	srv.Do(alice, http.MethodPost, "/messages/"+id+"/forward", map[string][]string{"conversationIds": {bobCarol}}).
+		AssertStatus(http.StatusForbidden)
+	srv.Do(carol, http.MethodPost, "/messages/"+id+"/forward", map[string][]string{"conversationIds": {bobCarol}}).
+		AssertStatus(http.StatusNotFound)
+}
+
+func TestReactions(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	msg := srv.SendMessage(alice, id, "react to me")
+
+	srv.Do(bob, http.MethodPost, "/messages/"+msg+"/reactions", map[string]string{"emoji": "👍"}).
+		AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodPost, "/messages/"+msg+"/reactions", map[string]string{"emoji": "🎉"}).
+		AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodPost, "/messages/"+msg+"/reactions", map[string]string{"emoji": "ab"}).
+		AssertStatus(http.StatusBadRequest)
+	srv.Do(carol, http.MethodPost, "/messages/"+msg+"/reactions", map[string]string{"emoji": "👍"}).
+		AssertStatus(http.StatusNotFound)
+
+	c := getConversation(srv, alice, id)
+	if r := c.Messages[0].Reactions; len(r) != 1 || r[0].Emoji != "🎉" || r[0].UserID != bob.ID {
+		t.Fatalf("reactions = %+v", r)
+	}
+
+	srv.Do(bob, http.MethodDelete, "/messages/"+msg+"/reactions", nil).AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodDelete, "/messages/"+msg+"/reactions", nil).AssertStatus(http.StatusNotFound)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+// maxInviteUses is the highest usage limit of an invite.
+const maxInviteUses = 1000
+
+// createInviteRequest is the CreateGroupInviteRequest schema.
+type createInviteRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	MaxUses   *int       `json:"maxUses"`
+}
+
+// createGroupInvite creates an invite to join a group, and replies with it. Only the owner and the admins can create
+// invites.
+func (rt *_router) createGroupInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	var req createInviteRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	}
+	ni := database.NewInvite{ConversationID: id, CreatedBy: ctx.UserID}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(rt.clock.Now()) {
+			sendError(w, ctx, http.StatusBadRequest, "expiration in the past")
+			return
+		}
+		ni.ExpiresAt = *req.ExpiresAt
+	}
+	if req.MaxUses != nil {
+		if *req.MaxUses < 1 || *req.MaxUses > maxInviteUses {
+			sendError(w, ctx, http.StatusBadRequest, "invalid usage limit")
+			return
+		}
+		ni.MaxUses = *req.MaxUses
+	}
+	token, err := newInviteToken()
+	if err != nil {
+		sendInternalError(w, ctx, err, "can't generate the invite token")
+		return
+	}
+	ni.Token = token
+
+	var inv database.Invite
+	err = rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if !isGroupAdmin(c, ctx.UserID) {
+			return errStatus(http.StatusForbidden, "only the owner and the admins can create invites")
+		}
+		inv, err = tx.CreateInvite(r.Context(), ni)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't create the invite")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusCreated, newInviteJSON(inv))
+}
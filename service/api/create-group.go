@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// newGroup contains the fields of the createGroup form.
+type newGroup struct {
+	Name      string
+	Photo     string
+	MemberIDs []string
+}
+
+// readNewGroup reads the multipart form of createGroup. On errors, it replies with HTTP 400 or 413 and returns false.
+func (rt *_router) readNewGroup(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext) (newGroup, bool) {
+	var g newGroup
+	form, err := rt.readMultipartForm(r)
+	if isBodyTooLarge(err) {
+		sendError(w, ctx, http.StatusRequestEntityTooLarge, "group too large")
+		return g, false
+	} else if err != nil {
+		sendError(w, ctx, http.StatusBadRequest, "invalid multipart form")
+		return g, false
+	}
+	defer func() { _ = form.RemoveAll() }()
+
+	var fields = map[string]string{}
+	for _, name := range []string{"name", "membersJson", "image"} {
+		if !form.Has(name) {
+			sendError(w, ctx, http.StatusBadRequest, "missing field "+name)
+			return g, false
+		}
+		if fields[name], err = form.Value(name); err != nil {
+			sendError(w, ctx, http.StatusBadRequest, "can't read the field "+name)
+			return g, false
+		}
+	}
+
+	g.Name, g.Photo = fields["name"], fields["image"]
+	if !groupNameRx.MatchString(g.Name) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid name")
+		return g, false
+	} else if g.Photo == "" || !isValidImage(g.Photo) {
+		sendError(w, ctx, http.StatusBadRequest, "the image must be a Base64 image")
+		return g, false
+	} else if err := json.Unmarshal([]byte(fields["membersJson"]), &g.MemberIDs); err != nil {
+		sendError(w, ctx, http.StatusBadRequest, "membersJson must be a JSON array of user IDs")
+		return g, false
+	}
+	for _, id := range g.MemberIDs {
+		if !idRx.MatchString(id) {
+			sendError(w, ctx, http.StatusBadRequest, "invalid user ID")
+			return g, false
+		}
+	}
+	return g, true
+}
+
+// createGroup creates a group with the authenticated user and the given members, and replies with the new group.
+// Duplicate members are ignored. It replies with HTTP 403 if one of the members blocked the user.
+func (rt *_router) createGroup(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	g, ok := rt.readNewGroup(w, r, ctx)
+	if !ok {
+		return
+	}
+
+	var members = []string{ctx.UserID}
+	var seen = map[string]bool{ctx.UserID: true}
+	for _, id := range g.MemberIDs {
+		if !seen[id] {
+			seen[id] = true
+			members = append(members, id)
+		}
+	}
+	if len(members) > maxGroupMembers {
+		sendError(w, ctx, http.StatusBadRequest, "too many members")
+		return
+	}
+
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		for _, userID := range members[1:] {
+			if blocked, err := tx.IsBlocked(r.Context(), userID, ctx.UserID); err != nil {
+				return err
+			} else if blocked {
+				return errStatus(http.StatusForbidden, "a user blocked you")
+			}
+		}
+		id, err := tx.CreateGroup(r.Context(), g.Name, g.Photo, members)
+		if errors.Is(err, database.ErrNotFound) {
+			return errStatus(http.StatusNotFound, "user not found")
+		} else if err != nil {
+			return err
+		}
+		c, err = tx.GetConversation(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't create the group")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusCreated, newGroupJSON(c))
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+// defaultDeleteWindow is the time after sending during which a message can be deleted for everyone, when
+// Config.DeleteWindow is zero.
+const defaultDeleteWindow = 48 * time.Hour
+
+// Scopes of deleteMessage.
+const (
+	deleteForMe       = "me"
+	deleteForEveryone = "everyone"
+)
+
+// deleteMessage deletes a message according to the `scope` query parameter. With `me`, the message is hidden from
+// the conversation of the authenticated user. With `everyone` (the default), the sender replaces the message with a
+// tombstone within the delete window, and the tombstone is sent as event to the members of the conversation.
+// Deleting a tombstone succeeds without changes.
+func (rt *_router) deleteMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("messageId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "message not found")
+		return
+	}
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = deleteForEveryone
+	} else if scope != deleteForMe && scope != deleteForEveryone {
+		sendError(w, ctx, http.StatusBadRequest, "invalid scope")
+		return
+	}
+
+	var deleted database.Message
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		m, conv, err := memberMessage(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if scope == deleteForMe {
+			return tx.HideMessage(r.Context(), id, ctx.UserID)
+		} else if m.SenderID != ctx.UserID {
+			return errStatus(http.StatusForbidden, "only the sender can delete the message for everyone")
+		} else if !m.DeletedAt.IsZero() {
+			return nil
+		} else if rt.clock.Since(m.SentAt) > rt.deleteWindow {
+			return errStatus(http.StatusForbidden, "the delete window has expired")
+		}
+
+		c = conv
+		if err := tx.DeleteMessage(r.Context(), id); err != nil {
+			return err
+		}
+		deleted, err = tx.GetMessage(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't delete the message")
+		return
+	}
+
+	if deleted.ID != 0 {
+		rt.publishMessage(eventMessageDeleted, deleted, c)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
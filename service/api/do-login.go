@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+type loginRequest struct {
+	Name  string `json:"name"`
+	Photo string `json:"photo"`
+}
+
+type loginReply struct {
+	Identifier string `json:"identifier"`
+}
+
+// doLogin logs in the user with the given name, creating it (with the given photo) if it doesn't exist. The reply
+// contains the user identifier, which is also the bearer token for the following requests. Banned users cannot log in.
+func (rt *_router) doLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var req loginRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if !userNameRx.MatchString(req.Name) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid name")
+		return
+	} else if req.Photo == "" || !isValidImage(req.Photo) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid photo")
+		return
+	}
+
+	var userID string
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		u, err := tx.GetUserByName(r.Context(), req.Name)
+		if errors.Is(err, database.ErrNotFound) {
+			var id string
+			if id, err = newUserID(); err != nil {
+				return err
+			}
+			u, err = tx.CreateUser(r.Context(), id, req.Name, req.Photo)
+		}
+		if err != nil {
+			return err
+		} else if !u.BannedAt.IsZero() {
+			return errStatus(http.StatusForbidden, "the user is banned")
+		}
+
+		userID = u.ID
+		_, err = tx.CreateSession(r.Context(), u.ID)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't log in the user")
+		return
+	}
+
+	ctx.Logger.WithField("user", userID).Info("user logged in")
+	sendJSON(w, ctx, http.StatusCreated, loginReply{Identifier: userID})
+}
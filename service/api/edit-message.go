@@ -0,0 +1,58 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+// defaultEditWindow is the time after sending during which a message can be edited, when Config.EditWindow is zero.
+const defaultEditWindow = 15 * time.Minute
+
+type editMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// editMessage replaces the content of a message sent by the authenticated user, within the edit window, parsing the
+// mentions again. The previous content is kept in the history, and the members of the conversation receive the edited
+// message as event.
+func (rt *_router) editMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("messageId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "message not found")
+		return
+	}
+	var req editMessageRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if req.Content == "" || !isValidContent(req.Content) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid content")
+		return
+	}
+
+	var edited database.Message
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		m, conv, err := memberLiveMessage(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if m.SenderID != ctx.UserID {
+			return errStatus(http.StatusForbidden, "only the sender can edit the message")
+		} else if rt.clock.Since(m.SentAt) > rt.editWindow {
+			return errStatus(http.StatusForbidden, "the edit window has expired")
+		}
+
+		c = conv
+		edited, err = tx.EditMessage(r.Context(), id, req.Content, parseMentions(req.Content, conv))
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't edit the message")
+		return
+	}
+
+	rt.publishMessage(eventMessageEdited, edited, c)
+	sendJSON(w, ctx, http.StatusOK, newMessageJSON(edited, c))
+}
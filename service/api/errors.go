@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"net/http"
+)
+
+// httpError is an error with the status code of the reply. Business rules running inside WithTx return it to abort the
+// transaction and reply with that status (see sendErrorFor).
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+// errStatus returns an httpError.
+func errStatus(status int, message string) error {
+	return &httpError{status: status, message: message}
+}
+
+// sendErrorFor replies with the status and the message of `err` if it's an httpError. For any other error, it logs
+// `message` and replies with HTTP 500.
+func sendErrorFor(w http.ResponseWriter, ctx reqcontext.RequestContext, err error, message string) {
+	var herr *httpError
+	if errors.As(err, &herr) {
+		sendError(w, ctx, herr.status, herr.message)
+		return
+	}
+	sendInternalError(w, ctx, err, message)
+}
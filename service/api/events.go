@@ -0,0 +1,98 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sync"
+)
+
+// eventBufferSize is the number of events queued for each subscriber. A subscriber that falls behind is disconnected:
+// the client reconnects and reloads the conversations.
+const eventBufferSize = 32
+
+// Event names, sent as the `event` field of the stream.
+const (
+	eventMessageEdited  = "messageEdited"
+	eventMessageDeleted = "messageDeleted"
+)
+
+// event is a change pushed to the clients connected to streamEvents.
+type event struct {
+	name string
+	data interface{}
+}
+
+// messageEventJSON is the MessageEvent schema.
+type messageEventJSON struct {
+	ConversationID string      `json:"conversationId"`
+	Message        messageJSON `json:"message"`
+}
+
+// eventHub delivers events to the subscribers of each user. Publishing never blocks.
+type eventHub struct {
+	mu sync.Mutex
+
+	// subs contains the channels of the subscribers, by user ID
+	subs map[string]map[chan event]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[string]map[chan event]struct{}{}}
+}
+
+// subscribe returns a channel receiving the events for the user, and the function to unsubscribe. The channel is
+// closed on unsubscribe, or when the subscriber falls behind.
+func (h *eventHub) subscribe(userID string) (<-chan event, func()) {
+	ch := make(chan event, eventBufferSize)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = map[chan event]struct{}{}
+	}
+	h.subs[userID][ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(userID, ch)
+	}
+}
+
+// remove closes the channel of a subscriber, if still subscribed. The caller must hold mu.
+func (h *eventHub) remove(userID string, ch chan event) {
+	if _, ok := h.subs[userID][ch]; !ok {
+		return
+	}
+	delete(h.subs[userID], ch)
+	close(ch)
+	if len(h.subs[userID]) == 0 {
+		delete(h.subs, userID)
+	}
+}
+
+// publish sends the event to all the subscribers of the users. Subscribers with a full queue are disconnected.
+func (h *eventHub) publish(userIDs []string, ev event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, userID := range userIDs {
+		for ch := range h.subs[userID] {
+			select {
+			case ch <- ev:
+			default:
+				h.remove(userID, ch)
+			}
+		}
+	}
+}
+
+// publishMessage sends an event with the message to all the members of its conversation `c`. Call it after the
+// transaction changing the message has been committed.
+func (rt *_router) publishMessage(name string, m database.Message, c database.Conversation) {
+	var userIDs = make([]string, 0, len(c.Members))
+	for _, member := range c.Members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	rt.events.publish(userIDs, event{name: name, data: messageEventJSON{
+		ConversationID: formatID(c.ID),
+		Message:        newMessageJSON(m, c),
+	}})
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database/inmemory"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := newEventHub()
+	alice, unsubscribeAlice := hub.subscribe("alice")
+	bob, unsubscribeBob := hub.subscribe("bob")
+	defer unsubscribeBob()
+
+	hub.publish([]string{"alice"}, event{name: "test", data: 1})
+	if ev := <-alice; ev.name != "test" || ev.data != 1 {
+		t.Fatalf("event = %+v", ev)
+	}
+	select {
+	case ev := <-bob:
+		t.Fatalf("event %+v delivered to a user not in the list", ev)
+	default:
+	}
+
+	// A subscriber that falls behind is disconnected, without blocking the publisher
+	for i := 0; i <= eventBufferSize; i++ {
+		hub.publish([]string{"alice", "bob"}, event{name: "test", data: i})
+	}
+	for i := 0; i < eventBufferSize; i++ {
+		<-alice
+	}
+	if _, ok := <-alice; ok {
+		t.Fatal("the channel of a slow subscriber is still open")
+	}
+	unsubscribeAlice()
+
+	if len(hub.subs) != 0 {
+		t.Fatalf("subscribers after disconnection = %v", hub.subs)
+	}
+}
+
+func TestStreamEventsWriteTimeout(t *testing.T) {
+	clock := globaltime.NewFake(time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC))
+	db, err := inmemory.New(clock)
+	if err != nil {
+		t.Fatalf("inmemory.New() error: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := db.CreateUser(ctx, "alice", "alice", "aGVsbG8="); err != nil {
+		t.Fatalf("CreateUser() error: %v", err)
+	}
+	if _, err := db.CreateSession(ctx, "alice"); err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	router, err := New(Config{Logger: logger, Database: db, Clock: clock})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer func() { _ = router.Close() }()
+
+	const writeTimeout = 100 * time.Millisecond
+	srv := httptest.NewUnstartedServer(router.Handler())
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Config.ConnContext = ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer alice")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /events error: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	lines := bufio.NewReader(resp.Body)
+	if line, err := lines.ReadString('\n'); err != nil || line != fmt.Sprintf("retry: %d\n", eventRetry) {
+		t.Fatalf("first line = %q, %v", line, err)
+	}
+
+	// The stream is still open after the write timeout
+	time.Sleep(3 * writeTimeout)
+	clock.Advance(eventKeepAlive)
+	for _, want := range []string{"\n", ": keep-alive\n"} {
+		if line, err := lines.ReadString('\n'); err != nil || line != want {
+			t.Fatalf("line after the write timeout = %q, %v; want %q", line, err, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// maxForwardTargets is the maximum number of conversations a message can be forwarded to at once.
+const maxForwardTargets = 50
+
+type forwardMessageRequest struct {
+	ConversationIDs []string `json:"conversationIds"`
+}
+
+// forwardMessage sends a copy of the message, marked as forwarded, to each of the given conversations. The reply is the
+// copy in the last conversation.
+func (rt *_router) forwardMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("messageId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "message not found")
+		return
+	}
+	var req forwardMessageRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if len(req.ConversationIDs) == 0 || len(req.ConversationIDs) > maxForwardTargets {
+		sendError(w, ctx, http.StatusBadRequest, "invalid number of conversations")
+		return
+	}
+
+	var msg messageJSON
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		original, _, err := memberLiveMessage(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		}
+
+		for _, target := range req.ConversationIDs {
+			conversationID, ok := parseID(target)
+			if !ok {
+				return errStatus(http.StatusNotFound, "conversation not found")
+			}
+			c, err := memberConversation(r.Context(), tx, conversationID, ctx.UserID)
+			if err != nil {
+				return err
+			} else if err := checkNotBlocked(r.Context(), tx, c, ctx.UserID); err != nil {
+				return err
+			}
+
+			msg, err = createMessage(r.Context(), tx, c, database.NewMessage{
+				ConversationID: conversationID,
+				SenderID:       ctx.UserID,
+				Content:        original.Content,
+				Attachment:     original.Attachment,
+				Forwarded:      true,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't forward the message")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, msg)
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// getBlockedUsers replies with the users blocked by the authenticated user, sorted by name.
+func (rt *_router) getBlockedUsers(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	users, err := rt.db.ListBlockedUsers(r.Context(), ctx.UserID)
+	if err != nil {
+		sendInternalError(w, ctx, err, "can't list the blocked users")
+		return
+	}
+	sendJSON(w, ctx, http.StatusOK, newUsersJSON(users))
+}
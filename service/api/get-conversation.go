@@ -0,0 +1,34 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// getConversation replies with the conversation and its messages, newest first. All the messages are marked as read by
+// the authenticated user.
+func (rt *_router) getConversation(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("conversationId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "conversation not found")
+		return
+	}
+
+	var details conversationDetailsJSON
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, err := memberConversation(r.Context(), tx, id, ctx.UserID); err != nil {
+			return err
+		}
+		var err error
+		details, err = readConversation(r.Context(), tx, id, ctx.UserID, rt.clock.Now())
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't read the conversation")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, details)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// getMessageHistory replies with the previous contents of the message, oldest first.
+func (rt *_router) getMessageHistory(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("messageId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "message not found")
+		return
+	}
+
+	var revisions []database.Revision
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, _, err := memberMessage(r.Context(), tx, id, ctx.UserID); err != nil {
+			return err
+		}
+		var err error
+		revisions, err = tx.ListRevisions(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't read the message history")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newRevisionsJSON(revisions))
+}
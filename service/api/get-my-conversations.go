@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// getMyConversations replies with the conversations of the authenticated user: the pinned ones first, the most recently
+// pinned at the top, then the others, the most recently active first. Archived conversations are included only if the
+// `archived` query parameter is true. The newest message of each listed conversation is marked as delivered to the
+// user.
+func (rt *_router) getMyConversations(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var archived bool
+	if value := r.URL.Query().Get("archived"); value != "" {
+		var err error
+		if archived, err = strconv.ParseBool(value); err != nil {
+			sendError(w, ctx, http.StatusBadRequest, "invalid archived")
+			return
+		}
+	}
+
+	// The conversations are read from the read pool; only the delivered markers that move are written, at the end
+	ids, err := rt.db.ListUserConversations(r.Context(), ctx.UserID)
+	if err != nil {
+		sendInternalError(w, ctx, err, "can't list the conversations")
+		return
+	}
+
+	now := rt.clock.Now()
+	var list = []conversationJSON{}
+	var activity = map[string]time.Time{}
+	var delivered = map[int64]int64{}
+	for _, id := range ids {
+		c, err := rt.db.GetConversation(r.Context(), id)
+		if errors.Is(err, database.ErrNotFound) {
+			// Deleted after listing
+			continue
+		} else if err != nil {
+			sendInternalError(w, ctx, err, "can't list the conversations")
+			return
+		} else if memberSettings(c, ctx.UserID).Archived && !archived {
+			continue
+		}
+
+		// The messages hidden by the user have been delivered too. The marker is moved in `c` as well, so the state
+		// of the newest message is up-to-date
+		last, err := rt.db.ListMessages(r.Context(), id, "", 1)
+		if err != nil {
+			sendInternalError(w, ctx, err, "can't list the conversations")
+			return
+		}
+		for i := range c.Members {
+			if m := &c.Members[i]; len(last) > 0 && m.UserID == ctx.UserID && m.LastDeliveredID < last[0].ID {
+				m.LastDeliveredID = last[0].ID
+				delivered[id] = last[0].ID
+			}
+		}
+
+		conv, err := newConversationJSON(r.Context(), rt.db, c, ctx.UserID, now)
+		if err != nil {
+			sendInternalError(w, ctx, err, "can't list the conversations")
+			return
+		}
+		activity[conv.ID] = c.CreatedAt
+		if conv.LastMessage != nil {
+			activity[conv.ID] = conv.LastMessage.SentAt
+		}
+		list = append(list, conv)
+	}
+
+	if len(delivered) > 0 {
+		err = rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+			for id, messageID := range delivered {
+				// The user may have left the conversation after listing
+				err := tx.MarkDelivered(r.Context(), id, ctx.UserID, messageID)
+				if err != nil && !errors.Is(err, database.ErrNotFound) {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			sendInternalError(w, ctx, err, "can't mark the conversations as delivered")
+			return
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].Settings.PinnedAt, list[j].Settings.PinnedAt
+		if a != nil || b != nil {
+			return b == nil || (a != nil && a.After(*b))
+		}
+		return activity[list[i].ID].After(activity[list[j].ID])
+	})
+	sendJSON(w, ctx, http.StatusOK, list)
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"net/http"
+)
+
+// maxGroupMembers is the maximum number of members of a group.
+const maxGroupMembers = 1000
+
+// memberGroup returns the group `id`. It returns an httpError with HTTP 404 if the group does not exist (one-to-one
+// conversations are not groups), or HTTP 403 if the user is not a member.
+func memberGroup(ctx context.Context, db database.AppDatabase, id int64, userID string) (database.Conversation, error) {
+	c, err := db.GetConversation(ctx, id)
+	if errors.Is(err, database.ErrNotFound) || (err == nil && !c.IsGroup) {
+		return c, errStatus(http.StatusNotFound, "group not found")
+	} else if err != nil {
+		return c, err
+	} else if !isMember(c, userID) {
+		return c, errStatus(http.StatusForbidden, "not a member of the group")
+	}
+	return c, nil
+}
+
+// memberRole returns the role of the user in the conversation, or an empty string if it's not a member.
+func memberRole(c database.Conversation, userID string) string {
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// isGroupAdmin returns true if the user is the owner or an admin of the group.
+func isGroupAdmin(c database.Conversation, userID string) bool {
+	role := memberRole(c, userID)
+	return role == database.RoleOwner || role == database.RoleAdmin
+}
+
+// checkPermission returns an httpError with HTTP 403 if the group permission `perm` (one of the fields of
+// database.GroupPermissions) does not allow the user to manage the group.
+func checkPermission(c database.Conversation, userID string, perm string) error {
+	if perm == database.PermissionMembers && isMember(c, userID) || isGroupAdmin(c, userID) {
+		return nil
+	}
+	return errStatus(http.StatusForbidden, "not allowed by the group permissions")
+}
+
+// addGroupMember adds a user to the group, unless it's already a member. It returns an httpError with HTTP 404 if the
+// user does not exist, or HTTP 400 if the group is full.
+func addGroupMember(ctx context.Context, db database.AppDatabase, c database.Conversation, userID string) error {
+	if isMember(c, userID) {
+		return nil
+	} else if len(c.Members) >= maxGroupMembers {
+		return errStatus(http.StatusBadRequest, "too many members")
+	}
+
+	err := db.AddMember(ctx, c.ID, userID)
+	if errors.Is(err, database.ErrNotFound) {
+		return errStatus(http.StatusNotFound, "user not found")
+	}
+	return err
+}
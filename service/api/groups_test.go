@@ -0,0 +1,361 @@
+package api_test
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type group struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+	Roles   []struct {
+		UserID string `json:"userId"`
+		Role   string `json:"role"`
+	} `json:"roles"`
+	Permissions permissions `json:"permissions"`
+	Photo       string      `json:"photo"`
+}
+
+type permissions struct {
+	Rename        string `json:"rename"`
+	AddMembers    string `json:"addMembers"`
+	RemoveMembers string `json:"removeMembers"`
+}
+
+// roles returns the role of each member of the group.
+func (g group) roles() map[string]string {
+	roles := map[string]string{}
+	for _, r := range g.Roles {
+		roles[r.UserID] = r.Role
+	}
+	return roles
+}
+
+// setPermissions changes the permissions of the group `id` using `setGroupPermissions`, and returns the updated group.
+func setPermissions(srv *apitest.Server, user *apitest.User, id string, perms permissions) group {
+	var g group
+	srv.Do(user, http.MethodPut, "/groups/"+id+"/permissions", perms).AssertStatus(http.StatusOK).DecodeJSON(&g)
+	return g
+}
+
+func TestCreateGroup(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+
+	var g group
+	srv.DoMultipart(alice, http.MethodPost, "/groups", map[string]string{
+		"name":        "The Group",
+		"membersJson": `["` + bob.ID + `","` + bob.ID + `","` + alice.ID + `"]`,
+		"image":       apitest.Photo,
+	}).AssertStatus(http.StatusCreated).DecodeJSON(&g)
+	if g.Name != "The Group" || g.Photo != apitest.Photo || len(g.Members) != 2 {
+		t.Fatalf("group = %+v", g)
+	}
+	// The creator is the owner, and the default permissions only restrict removing members
+	if roles := g.roles(); roles[alice.ID] != "owner" || roles[bob.ID] != "member" {
+		t.Fatalf("roles = %v", roles)
+	}
+	if g.Permissions != (permissions{Rename: "members", AddMembers: "members", RemoveMembers: "admins"}) {
+		t.Fatalf("permissions = %+v", g.Permissions)
+	}
+
+	// The group is a conversation of both members
+	c := getConversation(srv, bob, g.ID)
+	if !c.IsGroup || c.Name != "The Group" {
+		t.Fatalf("conversation = %+v", c)
+	}
+	srv.SendMessage(bob, g.ID, "hello group")
+
+	for _, fields := range []map[string]string{
+		{"name": "x", "membersJson": "[]", "image": apitest.Photo},
+		{"name": "The Group", "membersJson": "not json", "image": apitest.Photo},
+		{"name": "The Group", "membersJson": "[]", "image": ""},
+		{"name": "The Group", "membersJson": "[]"},
+	} {
+		srv.DoMultipart(alice, http.MethodPost, "/groups", fields).AssertStatus(http.StatusBadRequest)
+	}
+	srv.DoMultipart(alice, http.MethodPost, "/groups", map[string]string{
+		"name": "The Group", "membersJson": `["missing"]`, "image": apitest.Photo,
+	}).AssertStatus(http.StatusNotFound)
+}
+
+func TestSetGroupNameAndPhoto(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.CreateGroup(alice, "The Group", bob.ID)
+
+	var g group
+	srv.Do(bob, http.MethodPut, "/groups/"+id+"/name", map[string]string{"name": "Renamed"}).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&g)
+	if g.ID != id || g.Name != "Renamed" || len(g.Members) != 2 || g.Photo != apitest.Photo {
+		t.Fatalf("group = %+v", g)
+	}
+	srv.Do(bob, http.MethodPut, "/groups/"+id+"/name", map[string]string{"name": "!"}).
+		AssertStatus(http.StatusBadRequest)
+	srv.Do(carol, http.MethodPut, "/groups/"+id+"/name", map[string]string{"name": "Renamed"}).
+		AssertStatus(http.StatusForbidden)
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/groups/"+id+"/photo", strings.NewReader("d29ybGQ="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+alice.Token)
+	req.Header.Set("Content-Type", "image/png")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("setGroupPhoto status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if c := getConversation(srv, alice, id); c.Photo != "d29ybGQ=" {
+		t.Fatalf("photo after setGroupPhoto = %q, want d29ybGQ=", c.Photo)
+	}
+
+	// One-to-one conversations are not groups
+	direct := srv.StartConversation(alice, bob.ID)
+	srv.Do(alice, http.MethodPut, "/groups/"+direct+"/name", map[string]string{"name": "Renamed"}).
+		AssertStatus(http.StatusNotFound)
+}
+
+func TestAddToGroup(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.CreateGroup(alice, "The Group")
+	srv.SendMessage(alice, id, "before bob joined")
+
+	srv.Do(alice, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {bob.ID, alice.ID}}).
+		AssertStatus(http.StatusOK)
+	// Members joined at the same time are sorted by ID
+	members := []string{alice.ID, bob.ID}
+	sort.Strings(members)
+	if c := getConversation(srv, bob, id); !reflect.DeepEqual(c.Members, members) || len(c.Messages) != 1 {
+		t.Fatalf("conversation = %+v", c)
+	}
+
+	srv.Do(carol, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {carol.ID}}).
+		AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {carol.ID, "missing"}}).
+		AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {}}).
+		AssertStatus(http.StatusBadRequest)
+
+	// The failed request added nobody
+	if c := getConversation(srv, alice, id); len(c.Members) != 2 {
+		t.Fatalf("members = %v", c.Members)
+	}
+}
+
+func TestLeaveGroup(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.CreateGroup(alice, "The Group", bob.ID)
+
+	srv.Do(carol, http.MethodDelete, "/groups/"+id+"/members/"+carol.ID, nil).AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodDelete, "/groups/"+id+"/members/"+carol.ID, nil).AssertStatus(http.StatusNotFound)
+
+	srv.Do(bob, http.MethodDelete, "/groups/"+id+"/members/"+bob.ID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodGet, "/conversations/"+id, nil).AssertStatus(http.StatusForbidden)
+
+	// The group is deleted when the last member leaves
+	srv.Do(alice, http.MethodDelete, "/groups/"+id+"/members/"+alice.ID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(alice, http.MethodGet, "/conversations/"+id, nil).AssertStatus(http.StatusNotFound)
+}
+
+func TestLeaveGroupOwnership(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	dave := srv.LoginAs("dave")
+	id := srv.CreateGroup(alice, "The Group", bob.ID)
+	srv.Clock.Advance(time.Second)
+	srv.Do(alice, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {carol.ID}}).
+		AssertStatus(http.StatusOK)
+	srv.Clock.Advance(time.Second)
+	srv.Do(alice, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {dave.ID}}).
+		AssertStatus(http.StatusOK)
+	srv.Do(alice, http.MethodPut, "/groups/"+id+"/members/"+dave.ID+"/role", map[string]string{"role": "admin"}).
+		AssertStatus(http.StatusOK)
+	perms := permissions{Rename: "members", AddMembers: "members", RemoveMembers: "members"}
+	setPermissions(srv, alice, id, perms)
+
+	// Nobody else can remove the owner, even when every member can remove members
+	srv.Do(dave, http.MethodDelete, "/groups/"+id+"/members/"+alice.ID, nil).AssertStatus(http.StatusForbidden)
+
+	// The admin who joined first gets the ownership, before the members who joined earlier
+	srv.Do(alice, http.MethodDelete, "/groups/"+id+"/members/"+alice.ID, nil).AssertStatus(http.StatusNoContent)
+	if roles := setPermissions(srv, dave, id, perms).roles(); roles[dave.ID] != "owner" || roles[bob.ID] != "member" {
+		t.Fatalf("roles after the owner left = %v", roles)
+	}
+
+	// Without admins, the member who joined first gets it
+	srv.Do(dave, http.MethodDelete, "/groups/"+id+"/members/"+dave.ID, nil).AssertStatus(http.StatusNoContent)
+	if roles := setPermissions(srv, bob, id, perms).roles(); roles[bob.ID] != "owner" || roles[carol.ID] != "member" {
+		t.Fatalf("roles after the owner left = %v", roles)
+	}
+}
+
+func TestGroupPermissions(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	dave := srv.LoginAs("dave")
+	id := srv.CreateGroup(alice, "The Group", bob.ID, carol.ID)
+
+	// By default members can rename the group, but not remove others
+	srv.Do(bob, http.MethodPut, "/groups/"+id+"/name", map[string]string{"name": "Renamed"}).
+		AssertStatus(http.StatusOK)
+	srv.Do(bob, http.MethodDelete, "/groups/"+id+"/members/"+carol.ID, nil).AssertStatus(http.StatusForbidden)
+
+	admins := permissions{Rename: "admins", AddMembers: "admins", RemoveMembers: "admins"}
+	srv.Do(bob, http.MethodPut, "/groups/"+id+"/permissions", admins).AssertStatus(http.StatusForbidden)
+	for _, invalid := range []permissions{{}, {Rename: "admins", AddMembers: "admins", RemoveMembers: "owner"}} {
+		srv.Do(alice, http.MethodPut, "/groups/"+id+"/permissions", invalid).AssertStatus(http.StatusBadRequest)
+	}
+	if g := setPermissions(srv, alice, id, admins); g.Permissions != admins {
+		t.Fatalf("permissions = %+v", g.Permissions)
+	}
+
+	srv.Do(bob, http.MethodPut, "/groups/"+id+"/name", map[string]string{"name": "Renamed again"}).
+		AssertStatus(http.StatusForbidden)
+	srv.Do(bob, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {dave.ID}}).
+		AssertStatus(http.StatusForbidden)
+
+	// Admins are allowed
+	srv.Do(alice, http.MethodPut, "/groups/"+id+"/members/"+bob.ID+"/role", map[string]string{"role": "admin"}).
+		AssertStatus(http.StatusOK)
+	srv.Do(bob, http.MethodPut, "/groups/"+id+"/name", map[string]string{"name": "Renamed again"}).
+		AssertStatus(http.StatusOK)
+	srv.Do(bob, http.MethodPost, "/groups/"+id+"/members", map[string][]string{"userIds": {dave.ID}}).
+		AssertStatus(http.StatusOK)
+	srv.Do(bob, http.MethodDelete, "/groups/"+id+"/members/"+carol.ID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(carol, http.MethodPut, "/groups/"+id+"/permissions", admins).AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodPut, "/groups/1000/permissions", admins).AssertStatus(http.StatusNotFound)
+}
+
+func TestSetGroupMemberRole(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	dave := srv.LoginAs("dave")
+	id := srv.CreateGroup(alice, "The Group", bob.ID, carol.ID)
+	setRole := func(user *apitest.User, target *apitest.User, role string) *apitest.Response {
+		return srv.Do(user, http.MethodPut, "/groups/"+id+"/members/"+target.ID+"/role", map[string]string{"role": role})
+	}
+
+	setRole(bob, carol, "admin").AssertStatus(http.StatusForbidden)
+	setRole(alice, bob, "king").AssertStatus(http.StatusBadRequest)
+	setRole(alice, dave, "admin").AssertStatus(http.StatusNotFound)
+	setRole(dave, bob, "admin").AssertStatus(http.StatusForbidden)
+
+	var g group
+	setRole(alice, bob, "admin").AssertStatus(http.StatusOK).DecodeJSON(&g)
+	if roles := g.roles(); roles[alice.ID] != "owner" || roles[bob.ID] != "admin" || roles[carol.ID] != "member" {
+		t.Fatalf("roles = %v", roles)
+	}
+
+	// Admins can promote members, but only the owner can change admins and the ownership
+	setRole(bob, carol, "admin").AssertStatus(http.StatusOK)
+	setRole(bob, carol, "member").AssertStatus(http.StatusForbidden)
+	setRole(bob, bob, "member").AssertStatus(http.StatusForbidden)
+	setRole(bob, alice, "member").AssertStatus(http.StatusForbidden)
+	setRole(bob, bob, "owner").AssertStatus(http.StatusForbidden)
+	setRole(alice, alice, "admin").AssertStatus(http.StatusForbidden)
+
+	// Transferring the ownership makes the previous owner an admin
+	setRole(alice, carol, "owner").AssertStatus(http.StatusOK).DecodeJSON(&g)
+	if roles := g.roles(); roles[alice.ID] != "admin" || roles[bob.ID] != "admin" || roles[carol.ID] != "owner" {
+		t.Fatalf("roles after the transfer = %v", roles)
+	}
+	setRole(carol, alice, "member").AssertStatus(http.StatusOK)
+}
+
+func TestGroupInvites(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	dave := srv.LoginAs("dave")
+	id := srv.CreateGroup(alice, "The Group", bob.ID)
+	type invite struct {
+		Token     string     `json:"token"`
+		GroupID   string     `json:"groupId"`
+		CreatedBy string     `json:"createdBy"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+		MaxUses   *int       `json:"maxUses"`
+		Uses      int        `json:"uses"`
+	}
+	createInvite := func(user *apitest.User, body map[string]interface{}) *apitest.Response {
+		return srv.Do(user, http.MethodPost, "/groups/"+id+"/invites", body)
+	}
+	join := func(user *apitest.User, token string) *apitest.Response {
+		return srv.Do(user, http.MethodPost, "/invites/"+token+"/join", nil)
+	}
+
+	createInvite(bob, map[string]interface{}{}).AssertStatus(http.StatusForbidden)
+	createInvite(alice, map[string]interface{}{"maxUses": 0}).AssertStatus(http.StatusBadRequest)
+	createInvite(alice, map[string]interface{}{"expiresAt": apitest.Epoch}).AssertStatus(http.StatusBadRequest)
+
+	var once, unlimited, expiring invite
+	createInvite(alice, map[string]interface{}{"maxUses": 1}).AssertStatus(http.StatusCreated).DecodeJSON(&once)
+	createInvite(alice, map[string]interface{}{}).AssertStatus(http.StatusCreated).DecodeJSON(&unlimited)
+	createInvite(alice, map[string]interface{}{"expiresAt": apitest.Epoch.Add(time.Hour)}).
+		AssertStatus(http.StatusCreated).DecodeJSON(&expiring)
+	if once.GroupID != id || once.CreatedBy != alice.ID || once.MaxUses == nil || *once.MaxUses != 1 ||
+		once.ExpiresAt != nil || once.Uses != 0 || len(once.Token) < 16 {
+		t.Fatalf("invite = %+v", once)
+	}
+	if unlimited.MaxUses != nil || expiring.ExpiresAt == nil || !expiring.ExpiresAt.Equal(apitest.Epoch.Add(time.Hour)) {
+		t.Fatalf("invites = %+v, %+v", unlimited, expiring)
+	}
+
+	// Joining adds a member, and uses up the invite with a cap
+	var g group
+	join(carol, once.Token).AssertStatus(http.StatusOK).DecodeJSON(&g)
+	if roles := g.roles(); roles[carol.ID] != "member" || len(roles) != 3 {
+		t.Fatalf("roles = %v", roles)
+	}
+	join(dave, once.Token).AssertStatus(http.StatusGone)
+	join(carol, unlimited.Token).AssertStatus(http.StatusConflict)
+	join(dave, "missing-invite-token").AssertStatus(http.StatusNotFound)
+
+	var list []invite
+	srv.Do(bob, http.MethodGet, "/groups/"+id+"/invites", nil).AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodGet, "/groups/"+id+"/invites", nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+	// Invites created at the same time are sorted by token
+	if len(list) != 2 || list[0].Token == list[1].Token || list[0].Token == once.Token || list[1].Token == once.Token {
+		t.Fatalf("invites = %+v", list)
+	}
+
+	// Expired invites can't be used, and are not listed
+	srv.Clock.Advance(time.Hour)
+	join(dave, expiring.Token).AssertStatus(http.StatusGone)
+	srv.Do(alice, http.MethodGet, "/groups/"+id+"/invites", nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+	if len(list) != 1 || list[0].Token != unlimited.Token {
+		t.Fatalf("invites after the expiration = %+v", list)
+	}
+
+	// Revoked invites can't be used
+	srv.Do(bob, http.MethodDelete, "/groups/"+id+"/invites/"+unlimited.Token, nil).AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodDelete, "/groups/"+id+"/invites/"+unlimited.Token, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(alice, http.MethodDelete, "/groups/"+id+"/invites/"+unlimited.Token, nil).AssertStatus(http.StatusNotFound)
+	join(dave, unlimited.Token).AssertStatus(http.StatusNotFound)
+}
@@ -0,0 +1,108 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"testing"
+)
+
+func TestLiveness(t *testing.T) {
+	srv := apitest.New(t)
+	srv.Do(nil, http.MethodGet, "/liveness", nil).AssertStatus(http.StatusOK)
+}
+
+func TestReadiness(t *testing.T) {
+	srv := apitest.New(t)
+	srv.Do(nil, http.MethodGet, "/readiness", nil).
+		AssertStatus(http.StatusOK).
+		AssertJSON(`{"status": "ok", "checks": {"database": "ok", "migrations": "ok", "workers": "ok"}}`)
+}
+
+func TestReadinessFailingCheck(t *testing.T) {
+	srv := apitest.NewWithConfig(t, func(cfg *api.Config) {
+		cfg.ReadinessChecks = map[string]func(context.Context) error{
+			"backups": func(context.Context) error { return errors.New("last backup failed") },
+			"tempdir": func(context.Context) error { return nil },
+		}
+	})
+	srv.Do(nil, http.MethodGet, "/readiness", nil).
+		AssertStatus(http.StatusServiceUnavailable).
+		AssertJSON(`{"status": "unavailable", "checks": {"database": "ok", "migrations": "ok", "workers": "ok",
+			"backups": "last backup failed", "tempdir": "ok"}}`)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	srv := apitest.New(t)
+
+	req, err := http.NewRequest(http.MethodOptions, srv.URL+"/context", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("preflight status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want %q", origin, "*")
+	}
+	if headers := res.Header.Get("Access-Control-Allow-Headers"); headers != "Authorization,Content-Type" {
+		t.Fatalf("Access-Control-Allow-Headers = %q, want %q", headers, "Authorization,Content-Type")
+	}
+	if credentials := res.Header.Get("Access-Control-Allow-Credentials"); credentials != "" {
+		t.Fatalf("Access-Control-Allow-Credentials = %q, want no header", credentials)
+	}
+}
+
+func TestCORSPreflightHeaderNotAllowed(t *testing.T) {
+	srv := apitest.New(t)
+
+	req, err := http.NewRequest(http.MethodOptions, srv.URL+"/context", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "X-Not-Allowed")
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("preflight status = %d, want %d", res.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestCORSExposedHeaders(t *testing.T) {
+	srv := apitest.New(t)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/liveness", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "http://example.com")
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want %q", origin, "*")
+	}
+	if headers := res.Header.Get("Access-Control-Expose-Headers"); headers != "X-Request-Id" {
+		t.Fatalf("Access-Control-Expose-Headers = %q, want %q", headers, "X-Request-Id")
+	}
+}
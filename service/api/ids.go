@@ -0,0 +1,52 @@
+package api
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strconv"
+)
+
+// tokenAlphabet contains the characters of user identifiers and invite tokens. Identifiers are also bearer tokens, so
+// both are random and long enough not to be guessed.
+const tokenAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+const (
+	userIDLength      = 20
+	inviteTokenLength = 24
+)
+
+// newUserID returns a new random user identifier.
+func newUserID() (string, error) {
+	return randomToken(userIDLength)
+}
+
+// newInviteToken returns a new random invite token.
+func newInviteToken() (string, error) {
+	return randomToken(inviteTokenLength)
+}
+
+// randomToken returns a random string of `length` characters from tokenAlphabet.
+func randomToken(length int) (string, error) {
+	var token = make([]byte, length)
+	max := big.NewInt(int64(len(tokenAlphabet)))
+	for i := range token {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		token[i] = tokenAlphabet[n.Int64()]
+	}
+	return string(token), nil
+}
+
+// formatID converts a numeric database ID (conversations, messages) to the string used in the API.
+func formatID(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+// parseID converts an API identifier to a numeric database ID. Identifiers that are not numbers cannot exist, so
+// handlers should reply with HTTP 404 when it returns false.
+func parseID(s string) (int64, bool) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	return id, err == nil && id > 0
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// joinGroupByInvite adds the authenticated user to the group of an invite, and replies with the group. Expired and used
+// up invites are rejected with HTTP 410.
+func (rt *_router) joinGroupByInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	token := ps.ByName("inviteToken")
+
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		inv, err := tx.GetInvite(r.Context(), token)
+		if errors.Is(err, database.ErrNotFound) {
+			return errStatus(http.StatusNotFound, "invite not found")
+		} else if err != nil {
+			return err
+		} else if !inv.Usable(rt.clock.Now()) {
+			return errStatus(http.StatusGone, "invite expired or used up")
+		}
+
+		if c, err = tx.GetConversation(r.Context(), inv.ConversationID); err != nil {
+			return err
+		} else if isMember(c, ctx.UserID) {
+			return errStatus(http.StatusConflict, "already a member of the group")
+		}
+		if err := addGroupMember(r.Context(), tx, c, ctx.UserID); err != nil {
+			return err
+		}
+		if err := tx.UseInvite(r.Context(), token); err != nil {
+			return err
+		}
+		c, err = tx.GetConversation(r.Context(), inv.ConversationID)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't join the group")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newGroupJSON(c))
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// leaveGroup removes a user from a group: members can always leave, and other members can be removed as allowed by the
+// group permissions. When the owner leaves, the ownership passes to another member. The group is deleted when the last
+// member leaves.
+func (rt *_router) leaveGroup(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	userID := ps.ByName("userId")
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if !isMember(c, userID) {
+			return errStatus(http.StatusNotFound, "user not found in the group")
+		} else if userID != ctx.UserID && memberRole(c, userID) == database.RoleOwner {
+			return errStatus(http.StatusForbidden, "can't remove the owner")
+		} else if userID != ctx.UserID {
+			if err := checkPermission(c, ctx.UserID, c.Permissions.RemoveMembers); err != nil {
+				return err
+			}
+		}
+
+		if len(c.Members) == 1 {
+			return tx.DeleteConversation(r.Context(), id)
+		}
+		if err := tx.RemoveMember(r.Context(), id, userID); err != nil {
+			return err
+		} else if memberRole(c, userID) == database.RoleOwner {
+			return tx.SetMemberRole(r.Context(), id, nextOwner(c, userID), database.RoleOwner)
+		}
+		return nil
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't leave the group")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// nextOwner returns the member who gets the ownership of the group when the owner `ownerID` leaves: the admin who joined
+// first or, without admins, the member who joined first. The group must have other members.
+func nextOwner(c database.Conversation, ownerID string) string {
+	next := ""
+	for _, m := range c.Members {
+		if m.Role == database.RoleAdmin {
+			return m.UserID
+		} else if next == "" && m.UserID != ownerID {
+			next = m.UserID
+		}
+	}
+	return next
+}
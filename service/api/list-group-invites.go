@@ -0,0 +1,43 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// listGroupInvites replies with the invites of a group that can still be used. Only the owner and the admins can list
+// invites.
+func (rt *_router) listGroupInvites(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+
+	var invites []database.Invite
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if !isGroupAdmin(c, ctx.UserID) {
+			return errStatus(http.StatusForbidden, "only the owner and the admins can list invites")
+		}
+		invites, err = tx.ListInvites(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't list the invites")
+		return
+	}
+
+	now := rt.clock.Now()
+	var list = make([]inviteJSON, 0, len(invites))
+	for _, inv := range invites {
+		if inv.Usable(now) {
+			list = append(list, newInviteJSON(inv))
+		}
+	}
+	sendJSON(w, ctx, http.StatusOK, list)
+}
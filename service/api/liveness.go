@@ -5,12 +5,7 @@ import (
 	"net/http"
 )
 
-// liveness is an HTTP handler that checks the API server status. If the server cannot serve requests (e.g., some
-// resources are not ready), this should reply with HTTP Status 500. Otherwise, with HTTP Status 200
+// liveness is an HTTP handler that checks that the API server process is alive, replying with HTTP Status 200. It's
+// meant to be cheap, so it doesn't check any dependency: see readiness for checks on the database and other resources.
 func (rt *_router) liveness(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	/* Example of liveness check:
-	if err := rt.DB.Ping(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}*/
 }
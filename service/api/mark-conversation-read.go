@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// markReadRequest is the MarkReadRequest schema.
+type markReadRequest struct {
+	MessageID string `json:"messageId"`
+}
+
+// markConversationRead moves the read marker of the authenticated user forward to the given message of the
+// conversation, or to the newest message if the body is empty or has no message.
+func (rt *_router) markConversationRead(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("conversationId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "conversation not found")
+		return
+	}
+	var req markReadRequest
+	if !decodeOptionalJSONBody(w, r, ctx, &req) {
+		return
+	}
+	var messageID int64
+	if req.MessageID != "" {
+		if messageID, ok = parseID(req.MessageID); !ok {
+			sendError(w, ctx, http.StatusNotFound, "message not found")
+			return
+		}
+	}
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, err := memberConversation(r.Context(), tx, id, ctx.UserID); err != nil {
+			return err
+		}
+		if messageID != 0 {
+			m, err := tx.GetMessage(r.Context(), messageID)
+			if errors.Is(err, database.ErrNotFound) || (err == nil && m.ConversationID != id) {
+				return errStatus(http.StatusNotFound, "message not found")
+			} else if err != nil {
+				return err
+			}
+		} else {
+			newest, err := tx.ListMessages(r.Context(), id, "", 1)
+			if err != nil || len(newest) == 0 {
+				return err
+			}
+			messageID = newest[0].ID
+		}
+		return tx.MarkRead(r.Context(), id, ctx.UserID, messageID)
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't mark the conversation as read")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
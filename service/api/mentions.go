@@ -0,0 +1,56 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+// maxMentions is the maximum number of mentions stored for a message.
+const maxMentions = 100
+
+// isNameRune returns true if `r` can be part of a user name (see userNameRx).
+func isNameRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// parseMentions returns the mentions in `content` of the members of the group `c`: the `@name` tokens where name is the
+// name of a member. A `@` after a name character (as in e-mail addresses) does not start a token, and the names of
+// non-members are plain text. Messages of one-to-one conversations have no mentions.
+func parseMentions(content string, c database.Conversation) []database.Mention {
+	var mentions = []database.Mention{}
+	if !c.IsGroup {
+		return mentions
+	}
+	var members = make(map[string]string, len(c.Members))
+	for _, m := range c.Members {
+		members[m.Name] = m.UserID
+	}
+
+	runes := []rune(content)
+	for i := 0; i < len(runes) && len(mentions) < maxMentions; i++ {
+		if runes[i] != '@' || (i > 0 && isNameRune(runes[i-1])) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isNameRune(runes[end]) {
+			end++
+		}
+		if userID, ok := members[string(runes[i+1:end])]; ok {
+			mentions = append(mentions, database.Mention{UserID: userID, Offset: i, Length: end - i})
+		}
+		i = end - 1
+	}
+	return mentions
+}
+
+// mentionedUsers returns the IDs of the users mentioned at least once, in order of appearance.
+func mentionedUsers(mentions []database.Mention) []string {
+	var ids []string
+	var seen = map[string]bool{}
+	for _, m := range mentions {
+		if !seen[m.UserID] {
+			seen[m.UserID] = true
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids
+}
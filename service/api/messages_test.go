@@ -0,0 +1,395 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type revision struct {
+	Content  string    `json:"content"`
+	EditedAt time.Time `json:"editedAt"`
+}
+
+func TestEditMessage(t *testing.T) {
+	srv := apitest.NewWithConfig(t, func(cfg *api.Config) {
+		cfg.EditWindow = 10 * time.Minute
+	})
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	msgID := srv.SendMessage(alice, id, "helo")
+
+	srv.Clock.Advance(time.Minute)
+	var m message
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hello"}).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&m)
+	if m.ID != msgID || m.Content != "hello" || m.EditedAt == nil || !m.EditedAt.Equal(apitest.Epoch.Add(time.Minute)) {
+		t.Fatalf("edited message = %+v", m)
+	}
+	if c := getConversation(srv, bob, id); c.Messages[0].Content != "hello" || c.Messages[0].EditedAt == nil {
+		t.Fatalf("message in the conversation = %+v", c.Messages[0])
+	}
+
+	// Only the sender can edit, and only valid contents
+	srv.Do(bob, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hi"}).
+		AssertStatus(http.StatusForbidden)
+	srv.Do(carol, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hi"}).
+		AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": ""}).
+		AssertStatus(http.StatusBadRequest)
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": strings.Repeat("a", 1001)}).
+		AssertStatus(http.StatusBadRequest)
+	srv.Do(alice, http.MethodPatch, "/messages/1000", map[string]string{"content": "hi"}).
+		AssertStatus(http.StatusNotFound)
+
+	// The window starts when the message is sent
+	srv.Clock.Advance(9 * time.Minute)
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hello!"}).
+		AssertStatus(http.StatusOK)
+	srv.Clock.Advance(time.Millisecond)
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hello?"}).
+		AssertStatus(http.StatusForbidden)
+}
+
+func TestMessageHistory(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	msgID := srv.SendMessage(alice, id, "one")
+
+	srv.Do(bob, http.MethodGet, "/messages/"+msgID+"/history", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+
+	for _, content := range []string{"two", "three"} {
+		srv.Clock.Advance(time.Second)
+		srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": content}).
+			AssertStatus(http.StatusOK)
+	}
+	var history []revision
+	srv.Do(bob, http.MethodGet, "/messages/"+msgID+"/history", nil).AssertStatus(http.StatusOK).DecodeJSON(&history)
+	want := []revision{
+		{Content: "one", EditedAt: apitest.Epoch.Add(time.Second)},
+		{Content: "two", EditedAt: apitest.Epoch.Add(2 * time.Second)},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history = %+v, want %+v", history, want)
+	}
+	for i := range want {
+		if history[i].Content != want[i].Content || !history[i].EditedAt.Equal(want[i].EditedAt) {
+			t.Fatalf("history = %+v, want %+v", history, want)
+		}
+	}
+
+	srv.Do(carol, http.MethodGet, "/messages/"+msgID+"/history", nil).AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodGet, "/messages/1000/history", nil).AssertStatus(http.StatusNotFound)
+}
+
+func TestDeleteMessage(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	msgID := srv.SendMessage(alice, id, "hello")
+	var reply message
+	srv.DoMultipart(bob, http.MethodPost, "/conversations/"+id, map[string]string{"content": "hi", "replyTo": msgID}).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&reply)
+	srv.Do(bob, http.MethodPost, "/messages/"+msgID+"/reactions", map[string]string{"emoji": "👍"}).
+		AssertStatus(http.StatusNoContent)
+
+	srv.Do(bob, http.MethodDelete, "/messages/"+msgID, nil).AssertStatus(http.StatusForbidden)
+	srv.Do(carol, http.MethodDelete, "/messages/"+msgID, nil).AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodDelete, "/messages/"+msgID, nil).AssertStatus(http.StatusNoContent)
+
+	// The tombstone keeps sender and time, and the reply still refers to it
+	c := getConversation(srv, bob, id)
+	if len(c.Messages) != 2 {
+		t.Fatalf("messages = %+v", c.Messages)
+	}
+	tombstone := c.Messages[1]
+	if tombstone.ID != msgID || !tombstone.Deleted || tombstone.Content != "" || tombstone.SenderID != alice.ID ||
+		len(tombstone.Reactions) != 0 {
+		t.Fatalf("tombstone = %+v", tombstone)
+	}
+	if c.Messages[0].ReplyTo == nil || *c.Messages[0].ReplyTo != msgID {
+		t.Fatalf("reply = %+v", c.Messages[0])
+	}
+
+	// Tombstones cannot be changed, reacted to, forwarded or replied to; deleting them again succeeds
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hi"}).
+		AssertStatus(http.StatusNotFound)
+	srv.Do(bob, http.MethodPost, "/messages/"+msgID+"/reactions", map[string]string{"emoji": "👍"}).
+		AssertStatus(http.StatusNotFound)
+	srv.Do(alice, http.MethodPost, "/messages/"+msgID+"/forward", map[string][]string{"conversationIds": {id}}).
+		AssertStatus(http.StatusNotFound)
+	srv.DoMultipart(bob, http.MethodPost, "/conversations/"+id, map[string]string{"content": "hi", "replyTo": msgID}).
+		AssertStatus(http.StatusBadRequest)
+	srv.Do(alice, http.MethodDelete, "/messages/"+msgID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(alice, http.MethodDelete, "/messages/1000", nil).AssertStatus(http.StatusNotFound)
+}
+
+func TestDeleteMessageScopes(t *testing.T) {
+	srv := apitest.NewWithConfig(t, func(cfg *api.Config) {
+		cfg.DeleteWindow = time.Hour
+	})
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	first := srv.SendMessage(alice, id, "one")
+	second := srv.SendMessage(alice, id, "two")
+
+	// Any member can hide a message from their own view
+	srv.Do(bob, http.MethodDelete, "/messages/"+second+"?scope=me", nil).AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodDelete, "/messages/"+second+"?scope=me", nil).AssertStatus(http.StatusNoContent)
+	srv.Do(carol, http.MethodDelete, "/messages/"+second+"?scope=me", nil).AssertStatus(http.StatusNotFound)
+	if c := getConversation(srv, bob, id); len(c.Messages) != 1 || c.Messages[0].ID != first ||
+		c.LastMessage == nil || c.LastMessage.ID != first {
+		t.Fatalf("conversation of bob = %+v, want the hidden message excluded", c)
+	}
+	if c := getConversation(srv, alice, id); len(c.Messages) != 2 || c.Messages[0].Deleted {
+		t.Fatalf("conversation of alice = %+v, want all messages", c)
+	}
+
+	// Only the sender can delete for everyone, within the window
+	srv.Do(alice, http.MethodDelete, "/messages/"+first+"?scope=all", nil).AssertStatus(http.StatusBadRequest)
+	srv.Do(bob, http.MethodDelete, "/messages/"+first+"?scope=everyone", nil).AssertStatus(http.StatusForbidden)
+	srv.Clock.Advance(time.Hour)
+	srv.Do(alice, http.MethodDelete, "/messages/"+first+"?scope=everyone", nil).AssertStatus(http.StatusNoContent)
+	srv.Clock.Advance(time.Millisecond)
+	srv.Do(alice, http.MethodDelete, "/messages/"+second, nil).AssertStatus(http.StatusForbidden)
+
+	// Deleting a tombstone again succeeds even after the window
+	srv.Do(alice, http.MethodDelete, "/messages/"+first+"?scope=everyone", nil).AssertStatus(http.StatusNoContent)
+	if c := getConversation(srv, alice, id); len(c.Messages) != 2 || !c.Messages[1].Deleted || c.Messages[0].Deleted {
+		t.Fatalf("conversation of alice = %+v", c)
+	}
+}
+
+// streamEvents connects to the event stream as `user`, and returns a function reading the next event. The stream is
+// closed when the test ends.
+func streamEvents(t *testing.T, srv *apitest.Server, user *apitest.User) func() (string, string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+user.Token)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("streamEvents: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	return func() (string, string) {
+		t.Helper()
+		var name, data string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading the event stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && name != "":
+				return name, data
+			}
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	id := srv.StartConversation(alice, bob.ID)
+	other := srv.StartConversation(carol, alice.ID)
+	msgID := srv.SendMessage(alice, id, "helo")
+	otherID := srv.SendMessage(carol, other, "hi")
+
+	srv.Do(nil, http.MethodGet, "/events", nil).AssertStatus(http.StatusUnauthorized)
+	next := streamEvents(t, srv, bob)
+
+	// Only the members of the conversation receive the event
+	srv.Do(carol, http.MethodPatch, "/messages/"+otherID, map[string]string{"content": "hi!"}).
+		AssertStatus(http.StatusOK)
+	srv.Do(alice, http.MethodPatch, "/messages/"+msgID, map[string]string{"content": "hello"}).
+		AssertStatus(http.StatusOK)
+
+	name, data := next()
+	var ev struct {
+		ConversationID string  `json:"conversationId"`
+		Message        message `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatalf("event data %q: %v", data, err)
+	}
+	if name != "messageEdited" || ev.ConversationID != id || ev.Message.ID != msgID || ev.Message.Content != "hello" {
+		t.Fatalf("event %s = %+v", name, ev)
+	}
+
+	srv.Do(alice, http.MethodDelete, "/messages/"+msgID, nil).AssertStatus(http.StatusNoContent)
+	name, data = next()
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		t.Fatalf("event data %q: %v", data, err)
+	}
+	if name != "messageDeleted" || ev.Message.ID != msgID || !ev.Message.Deleted || ev.Message.Content != "" {
+		t.Fatalf("event %s = %+v", name, ev)
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	direct := srv.StartConversation(alice, bob.ID)
+	group := srv.CreateGroup(bob, "friends", carol.ID)
+
+	first := srv.SendMessage(alice, direct, "Ciao! Come stai?")
+	srv.Clock.Advance(time.Minute)
+	srv.SendMessage(bob, direct, "Sto bene, <b>tu</b> come stai?")
+	srv.SendMessage(bob, group, "stai attento")
+
+	type result struct {
+		ConversationID string  `json:"conversationId"`
+		Message        message `json:"message"`
+		Snippet        string  `json:"snippet"`
+	}
+	search := func(user *apitest.User, query string) []result {
+		t.Helper()
+		var results []result
+		srv.Do(user, http.MethodGet, "/messages/search?"+query, nil).AssertStatus(http.StatusOK).DecodeJSON(&results)
+		return results
+	}
+
+	// Only the conversations of the user, newest first, with escaped snippets
+	results := search(alice, "q=STAI")
+	if len(results) != 2 || results[0].ConversationID != direct || results[1].Message.ID != first ||
+		results[0].Snippet != "Sto bene, &lt;b&gt;tu&lt;/b&gt; come <mark>stai</mark>?" ||
+		results[1].Snippet != "Ciao! Come <mark>stai</mark>?" {
+		t.Fatalf("searchMessages = %+v", results)
+	}
+	if results := search(alice, "q=come+stai&senderId="+alice.ID); len(results) != 1 || results[0].Message.ID != first {
+		t.Fatalf("searchMessages by sender = %+v, want the first message", results)
+	}
+	if results := search(bob, "q=stai&conversationId="+group); len(results) != 1 || results[0].ConversationID != group {
+		t.Fatalf("searchMessages in a conversation = %+v, want the group message", results)
+	}
+	if results := search(alice, "q=stai&from="+apitest.Epoch.Add(time.Minute).Format(time.RFC3339)); len(results) != 1 {
+		t.Fatalf("searchMessages from a time = %+v, want the second message", results)
+	}
+	if results := search(alice, "q=stai&to="+apitest.Epoch.Format(time.RFC3339)); len(results) != 0 {
+		t.Fatalf("searchMessages before the first message = %+v, want none", results)
+	}
+
+	// Tombstones are not found
+	srv.Do(alice, http.MethodDelete, "/messages/"+first, nil).AssertStatus(http.StatusNoContent)
+	if results := search(alice, "q=ciao"); len(results) != 0 {
+		t.Fatalf("searchMessages of a deleted message = %+v, want none", results)
+	}
+
+	srv.Do(alice, http.MethodGet, "/messages/"+first, nil).AssertStatus(http.StatusNotFound)
+	for _, query := range []string{"", "q=", "q=!!", "q=" + strings.Repeat("a", 101), "q=a&conversationId=x",
+		"q=a&senderId=!", "q=a&from=yesterday", "q=a&to=2024-01-01"} {
+		srv.Do(alice, http.MethodGet, "/messages/search?"+query, nil).AssertStatus(http.StatusBadRequest)
+	}
+}
+
+func TestMentions(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	srv.LoginAs("dave")
+	group := srv.CreateGroup(alice, "friends", bob.ID, carol.ID)
+	direct := srv.StartConversation(alice, bob.ID)
+	// listed returns the conversation `id` from the list of `user`, as getting it would mark it read
+	listed := func(user *apitest.User, id string) conversation {
+		t.Helper()
+		var list []conversation
+		srv.Do(user, http.MethodGet, "/conversations?archived=true", nil).AssertStatus(http.StatusOK).DecodeJSON(&list)
+		for _, c := range list {
+			if c.ID == id {
+				return c
+			}
+		}
+		t.Fatalf("conversation %s not in the list of %s", id, user.Name)
+		return conversation{}
+	}
+
+	// Bob muted and archived the group: a mention brings it back anyway
+	srv.Do(bob, http.MethodPut, "/conversations/"+group+"/settings", map[string]interface{}{
+		"archived": true, "pinned": false, "mutedUntil": srv.Clock.Now().Add(time.Hour)}).AssertStatus(http.StatusOK)
+	srv.Do(carol, http.MethodPut, "/conversations/"+group+"/settings", map[string]interface{}{
+		"archived": true, "pinned": false, "mutedUntil": srv.Clock.Now().Add(time.Hour)}).AssertStatus(http.StatusOK)
+
+	// Offsets and lengths count code points; non-members and e-mail addresses are not mentions
+	first := srv.SendMessage(alice, group, "è @bob, @dave and a@carol @carol_ @bob")
+	c := listed(bob, group)
+	want := []mention{{UserID: bob.ID, Offset: 2, Length: 4}, {UserID: bob.ID, Offset: 34, Length: 4}}
+	if !reflect.DeepEqual(c.LastMessage.Mentions, want) {
+		t.Fatalf("mentions = %+v, want %+v", c.LastMessage.Mentions, want)
+	}
+	if c.MentionCount != 1 || c.Settings.Archived || c.Settings.MutedUntil == nil {
+		t.Fatalf("conversation of bob = %+v, want one mention, unarchived and still muted", c)
+	}
+	if c := listed(carol, group); c.MentionCount != 0 || !c.Settings.Archived {
+		t.Fatalf("conversation of carol = %+v, want no mentions and still archived", c)
+	}
+	if c := listed(alice, group); c.MentionCount != 0 {
+		t.Fatalf("mentionCount of the sender = %d, want 0", c.MentionCount)
+	}
+
+	// Edits parse the mentions again
+	var m message
+	srv.Do(alice, http.MethodPatch, "/messages/"+first, map[string]string{"content": "@carol!"}).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&m)
+	if want := []mention{{UserID: carol.ID, Offset: 0, Length: 6}}; !reflect.DeepEqual(m.Mentions, want) {
+		t.Fatalf("mentions after the edit = %+v, want %+v", m.Mentions, want)
+	}
+	if c := listed(bob, group); c.MentionCount != 0 {
+		t.Fatalf("mentionCount of bob after the edit = %d, want 0", c.MentionCount)
+	}
+	if c := listed(carol, group); c.MentionCount != 1 {
+		t.Fatalf("mentionCount of carol after the edit = %d, want 1", c.MentionCount)
+	}
+
+	// Reading the conversation resets the counter
+	srv.Do(carol, http.MethodPost, "/conversations/"+group+"/read", map[string]string{"messageId": first}).
+		AssertStatus(http.StatusNoContent)
+	if c := listed(carol, group); c.MentionCount != 0 {
+		t.Fatalf("mentionCount after reading = %d, want 0", c.MentionCount)
+	}
+
+	// One-to-one conversations have no mentions
+	srv.SendMessage(alice, direct, "hi @bob")
+	if c := getConversation(srv, bob, direct); len(c.Messages[0].Mentions) != 0 || c.MentionCount != 0 {
+		t.Fatalf("direct conversation = %+v, want no mentions", c)
+	}
+}
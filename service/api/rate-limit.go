@@ -0,0 +1,234 @@
+package api
+
+import (
+	"container/list"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"github.com/julienschmidt/httprouter"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit describes a token bucket: each client can issue up to Requests requests in a burst, and the bucket is
+// refilled at Requests tokens per Period. A zero value disables the limit.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// RateLimitConfig contains the rate limits for each class of write endpoints, and the limits for the memory used to
+// track clients.
+type RateLimitConfig struct {
+	// Login is applied to the login endpoint, keyed by client IP
+	Login RateLimit
+
+	// Messages is applied to message sending, editing, forwarding and deletion
+	Messages RateLimit
+
+	// Reactions is applied to adding and removing reactions
+	Reactions RateLimit
+
+	// Groups is applied to group creation and management
+	Groups RateLimit
+
+	// MaxKeys is the maximum number of clients tracked for each class. When full, the least recently seen client is
+	// evicted. Zero means no limit.
+	MaxKeys int
+
+	// IdleTimeout is the time after which a client with no requests is forgotten
+	IdleTimeout time.Duration
+}
+
+// rateLimitClass identifies a group of endpoints sharing the same rate limit.
+type rateLimitClass int
+
+const (
+	rateLimitLogin rateLimitClass = iota
+	rateLimitMessages
+	rateLimitReactions
+	rateLimitGroups
+)
+
+// rateLimited wraps a handler so that requests exceeding the rate limit for the given class are rejected with HTTP 429
+// (Too Many Requests). Requests are keyed by the authenticated user, or by the client IP for anonymous requests.
+//
+// Example:
+//
+//	rt.router.POST("/session", rt.wrap(rt.rateLimited(rateLimitLogin, rt.doLogin)))
+func (rt *_router) rateLimited(class rateLimitClass, fn httpRouterHandler) httpRouterHandler {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+		var key = ctx.UserID
+		if key == "" {
+			key = "ip:" + clientIP(r)
+		}
+
+		if wait, ok := rt.limiter.allow(class, key); !ok {
+			ctx.Logger.WithField("class", class).Debug("rate limit exceeded")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			sendError(w, ctx, http.StatusTooManyRequests, "too many requests")
+			return
+		}
+
+		fn(w, r, ps, ctx)
+	}
+}
+
+// clientIP returns the IP address of the client, without the port number.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimiter holds the token buckets for every class. Buckets not used for more than IdleTimeout are removed by a
+// background goroutine, which is stopped by close().
+type rateLimiter struct {
+	mu      sync.Mutex
+	clock   globaltime.Clock
+	classes map[rateLimitClass]*bucketSet
+	maxKeys int
+	idle    time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// bucketSet contains the buckets for a single class. Buckets are kept in a list ordered by last use (most recent at
+// front), so that eviction of the least recently used bucket is O(1).
+type bucketSet struct {
+	limit   RateLimit
+	buckets map[string]*list.Element
+	lru     *list.List
+}
+
+type bucket struct {
+	key      string
+	tokens   float64
+	lastSeen time.Time
+}
+
+func newRateLimiter(cfg RateLimitConfig, clock globaltime.Clock) *rateLimiter {
+	l := &rateLimiter{
+		clock:   clock,
+		classes: map[rateLimitClass]*bucketSet{},
+		maxKeys: cfg.MaxKeys,
+		idle:    cfg.IdleTimeout,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for class, limit := range map[rateLimitClass]RateLimit{
+		rateLimitLogin:     cfg.Login,
+		rateLimitMessages:  cfg.Messages,
+		rateLimitReactions: cfg.Reactions,
+		rateLimitGroups:    cfg.Groups,
+	} {
+		if limit.Requests <= 0 || limit.Period <= 0 {
+			continue
+		}
+		l.classes[class] = &bucketSet{
+			limit:   limit,
+			buckets: map[string]*list.Element{},
+			lru:     list.New(),
+		}
+	}
+
+	if l.idle > 0 {
+		// The ticker is created here, and not in the goroutine, so that it's already scheduled when this returns
+		go l.evictLoop(l.clock.NewTicker(l.idle / 2))
+	} else {
+		close(l.done)
+	}
+	return l
+}
+
+// allow consumes a token from the bucket of `key` in `class`. If no token is available, it returns false and the time
+// to wait before a new token is available.
+func (l *rateLimiter) allow(class rateLimitClass, key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	set, ok := l.classes[class]
+	if !ok {
+		return 0, true
+	}
+
+	now := l.clock.Now()
+	rate := float64(set.limit.Requests) / float64(set.limit.Period)
+
+	var b *bucket
+	if elem, found := set.buckets[key]; found {
+		b = elem.Value.(*bucket)
+		b.tokens = math.Min(float64(set.limit.Requests), b.tokens+float64(now.Sub(b.lastSeen))*rate)
+		set.lru.MoveToFront(elem)
+	} else {
+		if l.maxKeys > 0 && set.lru.Len() >= l.maxKeys {
+			oldest := set.lru.Back()
+			set.lru.Remove(oldest)
+			delete(set.buckets, oldest.Value.(*bucket).key)
+		}
+		b = &bucket{key: key, tokens: float64(set.limit.Requests)}
+		set.buckets[key] = set.lru.PushFront(b)
+	}
+	b.lastSeen = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// evictLoop periodically removes idle buckets until close() is called.
+func (l *rateLimiter) evictLoop(ticker globaltime.Ticker) {
+	defer close(l.done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C():
+			l.evictIdle()
+		}
+	}
+}
+
+// evictIdle removes buckets not used for more than the idle timeout.
+func (l *rateLimiter) evictIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	for _, set := range l.classes {
+		for elem := set.lru.Back(); elem != nil; elem = set.lru.Back() {
+			b := elem.Value.(*bucket)
+			if now.Sub(b.lastSeen) < l.idle {
+				break
+			}
+			set.lru.Remove(elem)
+			delete(set.buckets, b.key)
+		}
+	}
+}
+
+// close stops the eviction goroutine and releases all buckets.
+func (l *rateLimiter) close() {
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, set := range l.classes {
+		set.buckets = map[string]*list.Element{}
+		set.lru.Init()
+	}
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"github.com/julienschmidt/httprouter"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testEpoch = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestLimiter returns a rateLimiter with a fake clock, closed when the test ends.
+func newTestLimiter(t *testing.T, cfg RateLimitConfig) (*rateLimiter, *globaltime.Fake) {
+	clock := globaltime.NewFake(testEpoch)
+	l := newRateLimiter(cfg, clock)
+	t.Cleanup(l.close)
+	return l, clock
+}
+
+// assertAllow fails the test if allow() does not return `want` for the key.
+func assertAllow(t *testing.T, l *rateLimiter, class rateLimitClass, key string, want bool) {
+	t.Helper()
+	if _, ok := l.allow(class, key); ok != want {
+		t.Fatalf("allow(%d, %q) = %v, want %v", class, key, ok, want)
+	}
+}
+
+// bucketCount returns the number of clients tracked for the class.
+func (l *rateLimiter) bucketCount(class rateLimitClass) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.classes[class].lru.Len()
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l, clock := newTestLimiter(t, RateLimitConfig{Messages: RateLimit{Requests: 2, Period: time.Minute}})
+
+	assertAllow(t, l, rateLimitMessages, "alice", true)
+	assertAllow(t, l, rateLimitMessages, "alice", true)
+	assertAllow(t, l, rateLimitMessages, "alice", false)
+	// Buckets are per client, and classes without limits allow everything
+	assertAllow(t, l, rateLimitMessages, "bob", true)
+	assertAllow(t, l, rateLimitGroups, "alice", true)
+
+	// One token every 30 seconds
+	clock.Advance(29 * time.Second)
+	assertAllow(t, l, rateLimitMessages, "alice", false)
+	clock.Advance(time.Second)
+	assertAllow(t, l, rateLimitMessages, "alice", true)
+	assertAllow(t, l, rateLimitMessages, "alice", false)
+
+	// The bucket never holds more than Requests tokens
+	clock.Advance(time.Hour)
+	assertAllow(t, l, rateLimitMessages, "alice", true)
+	assertAllow(t, l, rateLimitMessages, "alice", true)
+	assertAllow(t, l, rateLimitMessages, "alice", false)
+}
+
+func TestRateLimitedRetryAfter(t *testing.T) {
+	clock := globaltime.NewFake(testEpoch)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	rt := &_router{
+		baseLogger: logger,
+		clock:      clock,
+		limiter:    newRateLimiter(RateLimitConfig{Messages: RateLimit{Requests: 2, Period: time.Minute}}, clock),
+	}
+	t.Cleanup(rt.limiter.close)
+
+	noContent := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	handler := rt.rateLimited(rateLimitMessages, noContent)
+	send := func(userID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		handler(w, r, nil, reqcontext.RequestContext{UserID: userID, Logger: logger})
+		return w
+	}
+
+	send("alice")
+	send("alice")
+	for _, tc := range []struct {
+		advance time.Duration
+		want    string
+	}{
+		{0, "30"},
+		// 19.5 seconds are rounded up, so that the client doesn't retry too early
+		{10500 * time.Millisecond, "20"},
+		{19 * time.Second, "1"},
+	} {
+		clock.Advance(tc.advance)
+		w := send("alice")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != tc.want {
+			t.Fatalf("after %v: status = %d, Retry-After = %q; want %d, %q", tc.advance, w.Code,
+				w.Header().Get("Retry-After"), http.StatusTooManyRequests, tc.want)
+		}
+	}
+
+	// Anonymous requests are keyed by IP, and don't use the bucket of the user
+	if w := send(""); w.Code != http.StatusNoContent {
+		t.Fatalf("anonymous request: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
+
+func TestRateLimiterMaxKeys(t *testing.T) {
+	l, _ := newTestLimiter(t, RateLimitConfig{
+		Reactions: RateLimit{Requests: 1, Period: time.Hour},
+		MaxKeys:   2,
+	})
+
+	assertAllow(t, l, rateLimitReactions, "alice", true)
+	assertAllow(t, l, rateLimitReactions, "bob", true)
+	// alice is now the most recently seen, so bob is evicted to make room for carol
+	assertAllow(t, l, rateLimitReactions, "alice", false)
+	assertAllow(t, l, rateLimitReactions, "carol", true)
+	if n := l.bucketCount(rateLimitReactions); n != 2 {
+		t.Fatalf("%d buckets, want 2", n)
+	}
+	assertAllow(t, l, rateLimitReactions, "alice", false)
+	assertAllow(t, l, rateLimitReactions, "bob", true)
+}
+
+func TestRateLimiterIdleEviction(t *testing.T) {
+	l, clock := newTestLimiter(t, RateLimitConfig{
+		Groups:      RateLimit{Requests: 1, Period: time.Hour},
+		IdleTimeout: 10 * time.Minute,
+	})
+
+	assertAllow(t, l, rateLimitGroups, "alice", true)
+	clock.Advance(5 * time.Minute)
+	assertAllow(t, l, rateLimitGroups, "bob", true)
+
+	// The eviction goroutine runs on its own: wait for it to remove alice
+	clock.Advance(5 * time.Minute)
+	deadline := time.Now().Add(5 * time.Second)
+	for l.bucketCount(rateLimitGroups) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d buckets after the idle timeout, want 1", l.bucketCount(rateLimitGroups))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// alice starts again with a full bucket, while bob (seen 5 minutes ago) is still limited
+	assertAllow(t, l, rateLimitGroups, "alice", true)
+	assertAllow(t, l, rateLimitGroups, "bob", false)
+}
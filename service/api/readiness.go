@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+// readinessCheckTimeout is the maximum duration of each readiness check. A check that takes longer fails, so that a
+// stuck database makes the probe fail instead of hanging.
+const readinessCheckTimeout = 2 * time.Second
+
+// builtinReadinessChecks are the names of the checks of the package, which can't be used in Config.ReadinessChecks.
+var builtinReadinessChecks = []string{"database", "migrations", "workers"}
+
+// readinessReply is the body of the readiness endpoint. Checks maps each check name to "ok" or to the error message.
+type readinessReply struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// readiness is an HTTP handler that checks whether the API server can serve requests: the database must be reachable
+// and up-to-date, background workers must be running, and the checks in Config.ReadinessChecks must pass. If any check
+// fails, or if the shutdown has started, it replies with HTTP Status 503 and the list of checks. Otherwise, with HTTP
+// Status 200.
+//
+// Unlike liveness, this handler queries the database: it should be probed less frequently.
+func (rt *_router) readiness(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("content-type", "application/json")
+
+	select {
+	case <-rt.shutdown:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(readinessReply{Status: "shutting down"})
+		return
+	default:
+	}
+
+	var checks = map[string]func(context.Context) error{
+		"database":   rt.db.Ping,
+		"migrations": rt.checkMigrations,
+		"workers":    rt.limiter.check,
+	}
+	for name, check := range rt.readinessChecks {
+		checks[name] = check
+	}
+
+	var reply = readinessReply{Status: "ok", Checks: map[string]string{}}
+	for name, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		err := check(ctx)
+		cancel()
+		if err != nil {
+			rt.baseLogger.WithError(err).WithField("check", name).Warning("readiness check failed")
+			reply.Status = "unavailable"
+			reply.Checks[name] = err.Error()
+		} else {
+			reply.Checks[name] = "ok"
+		}
+	}
+
+	if reply.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+// checkMigrations returns an error if the database schema is not at the version expected by this executable.
+func (rt *_router) checkMigrations(ctx context.Context) error {
+	version, err := rt.db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	} else if version != database.LatestSchemaVersion() {
+		return fmt.Errorf("schema version is %d, expected %d", version, database.LatestSchemaVersion())
+	}
+	return nil
+}
+
+// check returns an error if the eviction goroutine of the rate limiter is expected to run, but it stopped.
+func (l *rateLimiter) check(context.Context) error {
+	if l.idle <= 0 {
+		return nil
+	}
+	select {
+	case <-l.done:
+		return errors.New("rate limiter eviction worker stopped")
+	default:
+		return nil
+	}
+}
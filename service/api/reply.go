@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"net/http"
+)
+
+// errorReply is the body of error responses. The message is meant for developers: clients should rely on the status
+// code (see the `client` package).
+type errorReply struct {
+	Error string `json:"error"`
+}
+
+// sendJSON replies with `status` and `v` encoded as JSON.
+func sendJSON(w http.ResponseWriter, ctx reqcontext.RequestContext, status int, v interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		ctx.Logger.WithError(err).Debug("can't send the response")
+	}
+}
+
+// sendError replies with `status` and an errorReply containing `message`.
+func sendError(w http.ResponseWriter, ctx reqcontext.RequestContext, status int, message string) {
+	sendJSON(w, ctx, status, errorReply{Error: message})
+}
+
+// sendInternalError logs `err` with `message`, and replies with HTTP 500 without details.
+func sendInternalError(w http.ResponseWriter, ctx reqcontext.RequestContext, err error, message string) {
+	ctx.Logger.WithError(err).Error(message)
+	sendError(w, ctx, http.StatusInternalServerError, "internal server error")
+}
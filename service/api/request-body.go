@@ -0,0 +1,216 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"os"
+)
+
+// BodyLimitConfig contains the maximum size (in bytes) of request bodies for each class of endpoints, and the memory
+// threshold for multipart parts.
+type BodyLimitConfig struct {
+	// Default is the limit applied to JSON and other small request bodies
+	Default int64
+
+	// Uploads is the limit applied to endpoints receiving images, like photo updates, message sending and group
+	// creation
+	Uploads int64
+
+	// MultipartMemory is the size after which a multipart part is written to a temporary file instead of being kept
+	// in memory. Zero means defaultMultipartMemory
+	MultipartMemory int64
+}
+
+// defaultMultipartMemory is the memory threshold for multipart parts when BodyLimitConfig.MultipartMemory is not set.
+const defaultMultipartMemory = 1 << 20
+
+// bodyLimitClass identifies a group of endpoints sharing the same request body size limit.
+type bodyLimitClass int
+
+const (
+	bodyLimitDefault bodyLimitClass = iota
+	bodyLimitUploads
+)
+
+// limitBody wraps a handler so that the request body cannot be larger than the limit for the given class. Reading past
+// the limit returns an error that can be checked with isBodyTooLarge.
+//
+// Example:
+//
+//	rt.router.POST("/conversations/:conversationId", rt.wrap(rt.limitBody(bodyLimitUploads, rt.sendMessage)))
+func (rt *_router) limitBody(class bodyLimitClass, fn httpRouterHandler) httpRouterHandler {
+	limit := rt.bodyLimits.Default
+	if class == bodyLimitUploads {
+		limit = rt.bodyLimits.Uploads
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+		if limit > 0 {
+			if r.ContentLength > limit {
+				sendError(w, ctx, http.StatusRequestEntityTooLarge, "request body too large")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		fn(w, r, ps, ctx)
+	}
+}
+
+// isBodyTooLarge returns true if err has been caused by a request body exceeding the limit set by limitBody. Handlers
+// should reply with HTTP 413 (Request Entity Too Large) in this case.
+func isBodyTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// multipartForm is a multipart/form-data request body parsed by readMultipartForm. Each part is kept in memory if
+// small enough, otherwise it's stored in a temporary file. RemoveAll must be called to delete temporary files.
+type multipartForm struct {
+	parts map[string]*multipartPart
+}
+
+type multipartPart struct {
+	data []byte
+	file string
+}
+
+// readMultipartForm reads the multipart/form-data body of the request part by part, without loading the whole body in
+// memory. Parts larger than BodyLimitConfig.MultipartMemory are written to temporary files. If the body exceeds the
+// limit set by limitBody, the returned error satisfies isBodyTooLarge.
+//
+// When the same field is sent more than once, the last value is kept.
+func (rt *_router) readMultipartForm(r *http.Request) (*multipartForm, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &multipartForm{parts: map[string]*multipartPart{}}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return form, nil
+		} else if err != nil {
+			_ = form.RemoveAll()
+			return nil, err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			_ = part.Close()
+			continue
+		}
+
+		value, err := rt.readMultipartPart(part)
+		_ = part.Close()
+		if err != nil {
+			_ = form.RemoveAll()
+			return nil, err
+		}
+		if old, ok := form.parts[name]; ok {
+			_ = old.remove()
+		}
+		form.parts[name] = value
+	}
+}
+
+// readMultipartPart reads a single part, spilling to a temporary file when it's larger than the memory threshold.
+func (rt *_router) readMultipartPart(src io.Reader) (*multipartPart, error) {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, src, rt.bodyLimits.MultipartMemory+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	} else if n <= rt.bodyLimits.MultipartMemory {
+		return &multipartPart{data: buf.Bytes()}, nil
+	}
+
+	fp, err := os.CreateTemp("", "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fp.Close() }()
+
+	value := &multipartPart{file: fp.Name()}
+	if _, err = io.Copy(fp, io.MultiReader(&buf, src)); err != nil {
+		_ = value.remove()
+		return nil, err
+	}
+	return value, nil
+}
+
+// Has returns true if the field is present in the form.
+func (f *multipartForm) Has(name string) bool {
+	_, ok := f.parts[name]
+	return ok
+}
+
+// Open returns a reader for the content of the field. The caller must close the reader.
+func (f *multipartForm) Open(name string) (io.ReadCloser, error) {
+	part, ok := f.parts[name]
+	if !ok {
+		return nil, http.ErrMissingFile
+	}
+	if part.file == "" {
+		return io.NopCloser(bytes.NewReader(part.data)), nil
+	}
+	return os.Open(part.file)
+}
+
+// Value returns the content of the field as a string. Use Open for fields that can be large, like images.
+func (f *multipartForm) Value(name string) (string, error) {
+	rd, err := f.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = rd.Close() }()
+
+	data, err := io.ReadAll(rd)
+	return string(data), err
+}
+
+// RemoveAll deletes all temporary files of the form.
+func (f *multipartForm) RemoveAll() error {
+	var err error
+	for _, part := range f.parts {
+		if e := part.remove(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (p *multipartPart) remove() error {
+	if p.file == "" {
+		return nil
+	}
+	return os.Remove(p.file)
+}
+
+// decodeJSONBody decodes the JSON request body in `v`. If the body is too large or not valid, it replies with HTTP 413
+// or 400 and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext, v interface{}) bool {
+	return decodeBody(w, r, ctx, v, false)
+}
+
+// decodeOptionalJSONBody is like decodeJSONBody, but an empty body is valid and leaves `v` unchanged.
+func decodeOptionalJSONBody(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext, v interface{}) bool {
+	return decodeBody(w, r, ctx, v, true)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext, v interface{}, optional bool) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if optional && errors.Is(err, io.EOF) {
+		return true
+	} else if isBodyTooLarge(err) {
+		sendError(w, ctx, http.StatusRequestEntityTooLarge, "request body too large")
+		return false
+	} else if err != nil {
+		sendError(w, ctx, http.StatusBadRequest, "invalid JSON body")
+		return false
+	}
+	return true
+}
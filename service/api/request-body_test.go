@@ -0,0 +1,116 @@
+package api_test
+
+import (
+	"bytes"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// newLimitedServer returns a Server with small body limits, where multipart parts larger than 16 bytes are written to
+// temporary files in a directory that is returned too.
+func newLimitedServer(t *testing.T) (*apitest.Server, string) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	srv := apitest.NewWithConfig(t, func(cfg *api.Config) {
+		cfg.BodyLimits = api.BodyLimitConfig{Default: 256, Uploads: 2048, MultipartMemory: 16}
+	})
+	return srv, tmp
+}
+
+// multipartBody encodes the fields as multipart/form-data. The returned reader has no known length, so the request is
+// sent with the chunked transfer encoding and the limit is hit while reading the body.
+func multipartBody(t *testing.T, fields map[string]string) (io.Reader, string) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := mw.WriteField(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return io.MultiReader(&buf), mw.FormDataContentType()
+}
+
+// assertNoTempFiles fails the test if `dir` is not empty.
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) > 0 {
+		t.Fatalf("%d temporary files left in %s, first: %s", len(entries), dir, entries[0].Name())
+	}
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	srv, _ := newLimitedServer(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+
+	// Rejected by Content-Length, before reading the body
+	srv.Do(alice, http.MethodPut, "/me/name", map[string]string{"name": strings.Repeat("a", 300)}).
+		AssertStatus(http.StatusRequestEntityTooLarge)
+
+	// Rejected while reading a body of unknown length
+	body := io.MultiReader(strings.NewReader(`{"userId": "` + bob.ID + `", "padding": "` + strings.Repeat("a", 300) + `"}`))
+	srv.Do(alice, http.MethodPost, "/conversations", body).AssertStatus(http.StatusRequestEntityTooLarge)
+
+	// Uploads have a higher limit
+	id := srv.StartConversation(alice, bob.ID)
+	srv.SendMessage(alice, id, strings.Repeat("a", 500))
+	srv.DoMultipart(alice, http.MethodPost, "/conversations/"+id, map[string]string{
+		"content": strings.Repeat("a", 3000),
+	}).AssertStatus(http.StatusRequestEntityTooLarge)
+}
+
+func TestMultipartTempFiles(t *testing.T) {
+	srv, tmp := newLimitedServer(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	id := srv.StartConversation(alice, bob.ID)
+
+	// Parts larger than MultipartMemory are spilled to disk, and removed after the request
+	var msg struct {
+		Content string `json:"content"`
+	}
+	srv.DoMultipart(alice, http.MethodPost, "/conversations/"+id, map[string]string{
+		"content":    strings.Repeat("a", 500),
+		"attachment": strings.Repeat("aGVsbG8g", 100),
+	}).AssertStatus(http.StatusOK).DecodeJSON(&msg)
+	if msg.Content != strings.Repeat("a", 500) {
+		t.Fatalf("content = %q", msg.Content)
+	}
+	assertNoTempFiles(t, tmp)
+
+	// Files are removed also when the request fails, after or while reading the form
+	srv.DoMultipart(alice, http.MethodPost, "/conversations/"+id, map[string]string{
+		"content":    strings.Repeat("a", 500),
+		"attachment": strings.Repeat("!", 500),
+	}).AssertStatus(http.StatusBadRequest)
+	assertNoTempFiles(t, tmp)
+
+	body, contentType := multipartBody(t, map[string]string{"content": strings.Repeat("a", 3000)})
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/conversations/"+id, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+alice.Token)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+	assertNoTempFiles(t, tmp)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// revokeGroupInvite deletes an invite of a group. Only the owner and the admins can revoke invites.
+func (rt *_router) revokeGroupInvite(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if !isGroupAdmin(c, ctx.UserID) {
+			return errStatus(http.StatusForbidden, "only the owner and the admins can revoke invites")
+		}
+		err = tx.DeleteInvite(r.Context(), id, ps.ByName("inviteToken"))
+		if errors.Is(err, database.ErrNotFound) {
+			return errStatus(http.StatusNotFound, "invite not found")
+		}
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't revoke the invite")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
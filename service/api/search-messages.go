@@ -0,0 +1,161 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// searchMessagesLimit is the maximum number of messages returned by searchMessages.
+	searchMessagesLimit = 100
+
+	// maxSearchLength is the maximum length of the search query, in characters.
+	maxSearchLength = 100
+
+	// snippetLength is the maximum length of a snippet before escaping, in characters, and snippetContext how many
+	// characters are kept before the first match.
+	snippetLength  = 160
+	snippetContext = 40
+)
+
+// searchMessagesRoute serves GET /messages/:messageId, where the only valid path is GET /messages/search.
+func (rt *_router) searchMessagesRoute(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	if ps.ByName("messageId") != "search" {
+		sendError(w, ctx, http.StatusNotFound, "not found")
+		return
+	}
+	rt.searchMessages(w, r, ps, ctx)
+}
+
+// searchMessages replies with the messages of the conversations of the authenticated user containing all the words of
+// the `q` query parameter, newest first. The results can be filtered by conversation, sender and date range.
+func (rt *_router) searchMessages(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	query := r.URL.Query()
+	q := query.Get("q")
+	s := database.MessageSearch{UserID: ctx.UserID, Words: database.SearchWords(q), Limit: searchMessagesLimit}
+	if !utf8.ValidString(q) || utf8.RuneCountInString(q) > maxSearchLength || len(s.Words) == 0 {
+		sendError(w, ctx, http.StatusBadRequest, "invalid q")
+		return
+	}
+	if value := query.Get("conversationId"); value != "" {
+		var ok bool
+		if s.ConversationID, ok = parseID(value); !ok {
+			sendError(w, ctx, http.StatusBadRequest, "invalid conversationId")
+			return
+		}
+	}
+	if value := query.Get("senderId"); value != "" {
+		if !idRx.MatchString(value) {
+			sendError(w, ctx, http.StatusBadRequest, "invalid senderId")
+			return
+		}
+		s.SenderID = value
+	}
+	for name, bound := range map[string]*time.Time{"from": &s.From, "to": &s.To} {
+		if value := query.Get(name); value != "" {
+			var err error
+			if *bound, err = time.Parse(time.RFC3339, value); err != nil {
+				sendError(w, ctx, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+		}
+	}
+
+	var results = []searchResultJSON{}
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		list, err := tx.SearchMessages(r.Context(), s)
+		if err != nil {
+			return err
+		}
+		var conversations = map[int64]database.Conversation{}
+		for _, m := range list {
+			c, ok := conversations[m.ConversationID]
+			if !ok {
+				if c, err = tx.GetConversation(r.Context(), m.ConversationID); err != nil {
+					return err
+				}
+				conversations[m.ConversationID] = c
+			}
+			results = append(results, searchResultJSON{
+				ConversationID: formatID(m.ConversationID),
+				Message:        newMessageJSON(m, c),
+				Snippet:        searchSnippet(m.Content, s.Words),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		sendInternalError(w, ctx, err, "can't search the messages")
+		return
+	}
+	sendJSON(w, ctx, http.StatusOK, results)
+}
+
+// searchSnippet returns up to snippetLength characters of `content`, starting shortly before the first of the lowercase
+// `words`. The text is HTML-escaped, and the words are wrapped in <mark>. Cut ends are replaced by an ellipsis.
+func searchSnippet(content string, words []string) string {
+	var wanted = make(map[string]bool, len(words))
+	for _, w := range words {
+		wanted[w] = true
+	}
+
+	// Split the content into words and separators, finding the first match
+	runes := []rune(content)
+	type span struct {
+		start, end int
+		match      bool
+	}
+	var spans []span
+	start := -1
+	for i := 0; i < len(runes); {
+		j, word := i, database.IsWordRune(runes[i])
+		for j < len(runes) && database.IsWordRune(runes[j]) == word {
+			j++
+		}
+		match := word && wanted[strings.ToLower(string(runes[i:j]))]
+		if match && start < 0 {
+			start = i - snippetContext
+		}
+		spans = append(spans, span{start: i, end: j, match: match})
+		i = j
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for _, sp := range spans {
+		if sp.end <= start || sp.start >= end {
+			continue
+		}
+		if sp.match && sp.start >= start && sp.end <= end {
+			b.WriteString("<mark>" + html.EscapeString(string(runes[sp.start:sp.end])) + "</mark>")
+			continue
+		}
+		from, to := sp.start, sp.end
+		if from < start {
+			from = start
+		}
+		if to > end {
+			to = end
+		}
+		b.WriteString(html.EscapeString(string(runes[from:to])))
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 20)
+	for _, tc := range []struct {
+		content string
+		words   []string
+		want    string
+	}{
+		{"Ciao! Come STAI?", []string{"stai"}, "Ciao! Come <mark>STAI</mark>?"},
+		{"stai & stairs, <stai>", []string{"stai"}, "<mark>stai</mark> &amp; stairs, &lt;<mark>stai</mark>&gt;"},
+		{"è già qui", []string{"già", "è"}, "<mark>è</mark> <mark>già</mark> qui"},
+		// The snippet starts shortly before the first match, and cut ends are marked
+		{long + "target " + long + long, []string{"target"},
+			"…" + long[60:] + "<mark>target</mark> " + (long + long)[:113] + "…"},
+		{long + long + long, []string{"missing"}, (long + long)[:160] + "…"},
+	} {
+		if got := searchSnippet(tc.content, tc.words); got != tc.want {
+			t.Errorf("searchSnippet(%q, %q) = %q, want %q", tc.content, tc.words, got, tc.want)
+		}
+	}
+}
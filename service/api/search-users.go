@@ -0,0 +1,27 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// searchUsersLimit is the maximum number of users returned by searchUsers.
+const searchUsersLimit = 100
+
+// searchUsers replies with the users whose name contains the `name` query parameter, except the users blocked by the
+// caller.
+func (rt *_router) searchUsers(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	query := r.URL.Query().Get("name")
+	if !searchRx.MatchString(query) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid name query")
+		return
+	}
+
+	users, err := rt.db.SearchUsers(r.Context(), query, ctx.UserID, searchUsersLimit)
+	if err != nil {
+		sendInternalError(w, ctx, err, "can't search users")
+		return
+	}
+	sendJSON(w, ctx, http.StatusOK, newUsersJSON(users))
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxContentLength is the maximum length of the text of a message, in characters.
+const maxContentLength = 1000
+
+// isValidContent returns true if `content` is a valid message text. The empty string is valid: check it separately
+// where a text is required.
+func isValidContent(content string) bool {
+	return utf8.ValidString(content) && utf8.RuneCountInString(content) <= maxContentLength
+}
+
+// readNewMessage reads the multipart form of sendMessage. On errors, it replies with HTTP 400 or 413 and returns
+// false.
+func (rt *_router) readNewMessage(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext) (database.NewMessage, bool) {
+	var nm database.NewMessage
+	form, err := rt.readMultipartForm(r)
+	if isBodyTooLarge(err) {
+		sendError(w, ctx, http.StatusRequestEntityTooLarge, "message too large")
+		return nm, false
+	} else if err != nil {
+		sendError(w, ctx, http.StatusBadRequest, "invalid multipart form")
+		return nm, false
+	}
+	defer func() { _ = form.RemoveAll() }()
+
+	var fields = map[string]string{}
+	for _, name := range []string{"content", "attachment", "replyTo", "forwarded"} {
+		if !form.Has(name) {
+			continue
+		}
+		if fields[name], err = form.Value(name); err != nil {
+			sendError(w, ctx, http.StatusBadRequest, "can't read the field "+name)
+			return nm, false
+		}
+	}
+
+	nm.Content, nm.Attachment = fields["content"], fields["attachment"]
+	if nm.Content == "" && nm.Attachment == "" {
+		sendError(w, ctx, http.StatusBadRequest, "the message needs a content or an attachment")
+		return nm, false
+	} else if !isValidContent(nm.Content) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid content")
+		return nm, false
+	} else if !isValidImage(nm.Attachment) {
+		sendError(w, ctx, http.StatusBadRequest, "the attachment must be a Base64 image")
+		return nm, false
+	}
+
+	if replyTo := fields["replyTo"]; replyTo != "" {
+		var ok bool
+		if nm.ReplyTo, ok = parseID(replyTo); !ok {
+			sendError(w, ctx, http.StatusBadRequest, "invalid replyTo")
+			return nm, false
+		}
+	}
+	if forwarded := fields["forwarded"]; forwarded != "" {
+		if nm.Forwarded, err = strconv.ParseBool(forwarded); err != nil {
+			sendError(w, ctx, http.StatusBadRequest, "invalid forwarded")
+			return nm, false
+		}
+	}
+	return nm, true
+}
+
+// sendMessage adds a message to the conversation, and replies with the new message. Users cannot send messages to a
+// one-to-one conversation if the other member blocked them.
+func (rt *_router) sendMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("conversationId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "conversation not found")
+		return
+	}
+	nm, ok := rt.readNewMessage(w, r, ctx)
+	if !ok {
+		return
+	}
+	nm.ConversationID = id
+	nm.SenderID = ctx.UserID
+
+	var msg messageJSON
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberConversation(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if err := checkNotBlocked(r.Context(), tx, c, ctx.UserID); err != nil {
+			return err
+		}
+		if nm.ReplyTo != 0 {
+			original, err := tx.GetMessage(r.Context(), nm.ReplyTo)
+			if errors.Is(err, database.ErrNotFound) || (err == nil && (original.ConversationID != id ||
+				!original.DeletedAt.IsZero())) {
+				return errStatus(http.StatusBadRequest, "replyTo is not a message of the conversation")
+			} else if err != nil {
+				return err
+			}
+		}
+
+		msg, err = createMessage(r.Context(), tx, c, nm)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't send the message")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, msg)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"time"
+)
+
+// settingsRequest is the ConversationSettingsRequest schema.
+type settingsRequest struct {
+	MutedUntil *time.Time `json:"mutedUntil"`
+	Archived   *bool      `json:"archived"`
+	Pinned     *bool      `json:"pinned"`
+}
+
+// setConversationSettings changes the settings of a conversation for the authenticated user, and replies with the new
+// settings. A conversation that is already pinned keeps its pin time.
+func (rt *_router) setConversationSettings(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("conversationId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "conversation not found")
+		return
+	}
+	var req settingsRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if req.Archived == nil || req.Pinned == nil {
+		sendError(w, ctx, http.StatusBadRequest, "archived and pinned are required")
+		return
+	}
+	now := rt.clock.Now()
+	s := database.ConversationSettings{Archived: *req.Archived}
+	if req.MutedUntil != nil {
+		if !req.MutedUntil.After(now) {
+			sendError(w, ctx, http.StatusBadRequest, "mute end in the past")
+			return
+		}
+		s.MutedUntil = *req.MutedUntil
+	}
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		c, err := memberConversation(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		}
+		if *req.Pinned {
+			s.PinnedAt = memberSettings(c, ctx.UserID).PinnedAt
+			if s.PinnedAt.IsZero() {
+				s.PinnedAt = now
+			}
+		}
+		if err := tx.SetConversationSettings(r.Context(), id, ctx.UserID, s); err != nil {
+			return err
+		}
+		// Reply with the stored settings, which have the precision of the database
+		c, err = tx.GetConversation(r.Context(), id)
+		s = memberSettings(c, ctx.UserID)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't change the conversation settings")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newSettingsJSON(s, now))
+}
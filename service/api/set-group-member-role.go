@@ -0,0 +1,72 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// roleRequest is the SetGroupMemberRoleRequest schema.
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// setGroupMemberRole changes the role of a group member, and replies with the updated group. The owner and the admins
+// can change roles, but only the owner can change the role of an admin. Giving the owner role transfers the ownership,
+// and the previous owner becomes an admin.
+func (rt *_router) setGroupMemberRole(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	userID := ps.ByName("userId")
+	var req roleRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	}
+	switch req.Role {
+	case database.RoleOwner, database.RoleAdmin, database.RoleMember:
+	default:
+		sendError(w, ctx, http.StatusBadRequest, "invalid role")
+		return
+	}
+
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		g, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if !isMember(g, userID) {
+			return errStatus(http.StatusNotFound, "user not found in the group")
+		}
+
+		actor, target := memberRole(g, ctx.UserID), memberRole(g, userID)
+		switch {
+		case actor != database.RoleOwner && actor != database.RoleAdmin:
+			return errStatus(http.StatusForbidden, "only the owner and the admins can change roles")
+		case target == database.RoleOwner:
+			return errStatus(http.StatusForbidden, "the owner's role changes only by transferring the ownership")
+		case actor != database.RoleOwner && (target == database.RoleAdmin || req.Role == database.RoleOwner):
+			return errStatus(http.StatusForbidden, "only the owner can change this role")
+		}
+
+		if req.Role == database.RoleOwner {
+			if err := tx.SetMemberRole(r.Context(), id, ctx.UserID, database.RoleAdmin); err != nil {
+				return err
+			}
+		}
+		if err := tx.SetMemberRole(r.Context(), id, userID, req.Role); err != nil {
+			return err
+		}
+		c, err = tx.GetConversation(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't change the role")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newGroupJSON(c))
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// setGroupName changes the name of a group, and replies with the updated group.
+func (rt *_router) setGroupName(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	var req nameRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if !groupNameRx.MatchString(req.Name) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid name")
+		return
+	}
+
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		g, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if err := checkPermission(g, ctx.UserID, g.Permissions.Rename); err != nil {
+			return err
+		}
+		if err := tx.SetGroupName(r.Context(), id, req.Name); err != nil {
+			return err
+		}
+		c, err = tx.GetConversation(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't change the group name")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newGroupJSON(c))
+}
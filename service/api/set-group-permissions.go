@@ -0,0 +1,55 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// setGroupPermissions changes which members can manage a group, and replies with the updated group. Only the owner and
+// the admins can change the permissions.
+func (rt *_router) setGroupPermissions(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	var req groupPermissionsJSON
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	}
+	for _, p := range []string{req.Rename, req.AddMembers, req.RemoveMembers} {
+		if p != database.PermissionAdmins && p != database.PermissionMembers {
+			sendError(w, ctx, http.StatusBadRequest, "invalid permissions")
+			return
+		}
+	}
+
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		g, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if !isGroupAdmin(g, ctx.UserID) {
+			return errStatus(http.StatusForbidden, "only the owner and the admins can change the permissions")
+		}
+
+		perms := database.GroupPermissions{
+			Rename:        req.Rename,
+			AddMembers:    req.AddMembers,
+			RemoveMembers: req.RemoveMembers,
+		}
+		if err := tx.SetGroupPermissions(r.Context(), id, perms); err != nil {
+			return err
+		}
+		c, err = tx.GetConversation(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't change the permissions")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newGroupJSON(c))
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// setGroupPhoto changes the photo of a group, and replies with the updated group.
+func (rt *_router) setGroupPhoto(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("groupId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "group not found")
+		return
+	}
+	photo, ok := readPhotoBody(w, r, ctx)
+	if !ok {
+		return
+	}
+
+	var c database.Conversation
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		g, err := memberGroup(r.Context(), tx, id, ctx.UserID)
+		if err != nil {
+			return err
+		} else if err := checkPermission(g, ctx.UserID, g.Permissions.Rename); err != nil {
+			return err
+		}
+		if err := tx.SetGroupPhoto(r.Context(), id, photo); err != nil {
+			return err
+		}
+		c, err = tx.GetConversation(r.Context(), id)
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't change the group photo")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newGroupJSON(c))
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// readPhotoBody reads a Base64 photo sent as raw request body, with an image/png or image/jpeg content type. On errors,
+// it replies with HTTP 400 or 413 and returns false.
+func readPhotoBody(w http.ResponseWriter, r *http.Request, ctx reqcontext.RequestContext) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "image/png" && mediaType != "image/jpeg") {
+		sendError(w, ctx, http.StatusBadRequest, "the content type must be image/png or image/jpeg")
+		return "", false
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if isBodyTooLarge(err) {
+		sendError(w, ctx, http.StatusRequestEntityTooLarge, "photo too large")
+		return "", false
+	} else if err != nil {
+		sendError(w, ctx, http.StatusBadRequest, "can't read the photo")
+		return "", false
+	}
+
+	photo := strings.TrimSpace(string(data))
+	if photo == "" || !isValidImage(photo) {
+		sendError(w, ctx, http.StatusBadRequest, "the photo must be a Base64 image")
+		return "", false
+	}
+	return photo, true
+}
+
+// setMyPhoto changes the profile photo of the authenticated user, and replies with the updated user.
+func (rt *_router) setMyPhoto(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	photo, ok := readPhotoBody(w, r, ctx)
+	if !ok {
+		return
+	}
+
+	var u database.User
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if err := tx.SetUserPhoto(r.Context(), ctx.UserID, photo); err != nil {
+			return err
+		}
+		var err error
+		u, err = tx.GetUser(r.Context(), ctx.UserID)
+		return err
+	})
+	if err != nil {
+		sendInternalError(w, ctx, err, "can't change the user photo")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newUserJSON(u))
+}
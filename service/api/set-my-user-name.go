@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// nameRequest is the UpdateNameRequest schema, used for both user and group names.
+type nameRequest struct {
+	Name string `json:"name"`
+}
+
+// setMyUserName changes the name of the authenticated user, and replies with the updated user.
+func (rt *_router) setMyUserName(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var req nameRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if !userNameRx.MatchString(req.Name) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid name")
+		return
+	}
+
+	var u database.User
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if err := tx.SetUserName(r.Context(), ctx.UserID, req.Name); err != nil {
+			return err
+		}
+		var err error
+		u, err = tx.GetUser(r.Context(), ctx.UserID)
+		return err
+	})
+	if errors.Is(err, database.ErrConflict) {
+		sendError(w, ctx, http.StatusConflict, "name already in use")
+		return
+	} else if err != nil {
+		sendInternalError(w, ctx, err, "can't change the user name")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusOK, newUserJSON(u))
+}
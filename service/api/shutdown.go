@@ -2,5 +2,13 @@ package api
 
 // Close should close everything opened in the lifecycle of the `_router`; for example, background goroutines.
 func (rt *_router) Close() error {
+	select {
+	case <-rt.shutdown:
+		return nil
+	default:
+		close(rt.shutdown)
+	}
+
+	rt.limiter.close()
 	return nil
 }
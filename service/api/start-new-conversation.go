@@ -0,0 +1,59 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+type startConversationRequest struct {
+	UserID string `json:"userId"`
+}
+
+// startNewConversation replies with the one-to-one conversation between the authenticated user and the given user,
+// creating it if needed. It fails if the given user blocked the authenticated user.
+func (rt *_router) startNewConversation(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	var req startConversationRequest
+	if !decodeJSONBody(w, r, ctx, &req) {
+		return
+	} else if !idRx.MatchString(req.UserID) {
+		sendError(w, ctx, http.StatusBadRequest, "invalid user ID")
+		return
+	} else if req.UserID == ctx.UserID {
+		sendError(w, ctx, http.StatusBadRequest, "can't start a conversation with yourself")
+		return
+	}
+
+	var details conversationDetailsJSON
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, err := tx.GetUser(r.Context(), req.UserID); errors.Is(err, database.ErrNotFound) {
+			return errStatus(http.StatusNotFound, "user not found")
+		} else if err != nil {
+			return err
+		}
+		if blocked, err := tx.IsBlocked(r.Context(), req.UserID, ctx.UserID); err != nil {
+			return err
+		} else if blocked {
+			return errStatus(http.StatusForbidden, "the user blocked you")
+		}
+
+		id, err := tx.FindDirectConversation(r.Context(), ctx.UserID, req.UserID)
+		if errors.Is(err, database.ErrNotFound) {
+			id, err = tx.CreateDirectConversation(r.Context(), ctx.UserID, req.UserID)
+		}
+		if err != nil {
+			return err
+		}
+
+		details, err = readConversation(r.Context(), tx, id, ctx.UserID, rt.clock.Now())
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't start the conversation")
+		return
+	}
+
+	sendJSON(w, ctx, http.StatusCreated, details)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"github.com/julienschmidt/httprouter"
+	"net"
+	"net/http"
+	"time"
+)
+
+// eventKeepAlive is the interval between the comments sent on idle streams, so that proxies keep the connection open.
+const eventKeepAlive = 30 * time.Second
+
+// eventRetry is the reconnection delay suggested to clients, in milliseconds. The server closes streams on shutdown.
+const eventRetry = 1000
+
+// connKey is the context key of the connection of a request (see ConnContext).
+type connKey struct{}
+
+// ConnContext returns `ctx` with the connection `c`. It must be used as http.Server.ConnContext, so that event streams
+// can clear the write deadline set by http.Server.WriteTimeout: otherwise, streams are closed when it expires.
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// clearWriteDeadline removes the write deadline of the HTTP/1.x connection of `r`, if known (see ConnContext). HTTP/2
+// connections are shared by many requests, so their deadline is left as is.
+func clearWriteDeadline(r *http.Request) error {
+	conn, ok := r.Context().Value(connKey{}).(net.Conn)
+	if !ok || r.ProtoMajor != 1 {
+		return nil
+	}
+	return conn.SetWriteDeadline(time.Time{})
+}
+
+// streamEvents sends the events of the authenticated user as Server-Sent Events, until the client disconnects or the
+// server shuts down.
+func (rt *_router) streamEvents(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendInternalError(w, ctx, errors.New("the response writer is not a http.Flusher"), "can't stream events")
+		return
+	}
+
+	if err := clearWriteDeadline(r); err != nil {
+		sendInternalError(w, ctx, err, "can't stream events")
+		return
+	}
+
+	events, unsubscribe := rt.events.subscribe(ctx.UserID)
+	defer unsubscribe()
+	ticker := rt.clock.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetry)
+
+	for err == nil {
+		flusher.Flush()
+		select {
+		case <-r.Context().Done():
+			return
+		case <-rt.shutdown:
+			return
+		case <-ticker.C():
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			data, jerr := json.Marshal(ev.data)
+			if jerr != nil {
+				ctx.Logger.WithError(jerr).Error("can't encode the event")
+				return
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data)
+		}
+	}
+	ctx.Logger.WithError(err).Debug("event stream closed")
+}
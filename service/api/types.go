@@ -0,0 +1,265 @@
+package api
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"time"
+)
+
+// This file contains the JSON representation of the schemas in doc/api.yaml, and the conversions from the database
+// types.
+
+// userJSON is the User schema.
+type userJSON struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Photo string `json:"photo"`
+}
+
+func newUserJSON(u database.User) userJSON {
+	return userJSON{ID: u.ID, Name: u.Name, Photo: u.Photo}
+}
+
+func newUsersJSON(users []database.User) []userJSON {
+	var list = make([]userJSON, 0, len(users))
+	for _, u := range users {
+		list = append(list, newUserJSON(u))
+	}
+	return list
+}
+
+// Message states.
+const (
+	messageSent      = "sent"
+	messageDelivered = "delivered"
+	messageRead      = "read"
+)
+
+// reactionJSON is an item of the ReactionsArray schema.
+type reactionJSON struct {
+	Emoji  string `json:"emoji"`
+	UserID string `json:"userId"`
+}
+
+// mentionJSON is the Mention schema.
+type mentionJSON struct {
+	UserID string `json:"userId"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// messageJSON is the Message schema.
+type messageJSON struct {
+	ID          string         `json:"id"`
+	State       string         `json:"state"`
+	SentAt      time.Time      `json:"sentAt"`
+	SenderID    string         `json:"senderId"`
+	SenderName  string         `json:"senderName"`
+	Content     string         `json:"content"`
+	Attachment  string         `json:"attachment"`
+	ReplyTo     *string        `json:"replyTo,omitempty"`
+	IsForwarded bool           `json:"isForwarded"`
+	Mentions    []mentionJSON  `json:"mentions"`
+	Deleted     bool           `json:"deleted"`
+	EditedAt    *time.Time     `json:"editedAt,omitempty"`
+	Reactions   []reactionJSON `json:"reactions"`
+}
+
+// newMessageJSON converts a message of the conversation `c`, which is used to compute the state of the message.
+func newMessageJSON(m database.Message, c database.Conversation) messageJSON {
+	msg := messageJSON{
+		ID:          formatID(m.ID),
+		State:       messageState(m, c),
+		SentAt:      m.SentAt,
+		SenderID:    m.SenderID,
+		SenderName:  m.SenderName,
+		Content:     m.Content,
+		Attachment:  m.Attachment,
+		IsForwarded: m.Forwarded,
+		Mentions:    make([]mentionJSON, 0, len(m.Mentions)),
+		Deleted:     !m.DeletedAt.IsZero(),
+		Reactions:   make([]reactionJSON, 0, len(m.Reactions)),
+	}
+	if m.ReplyTo != 0 {
+		replyTo := formatID(m.ReplyTo)
+		msg.ReplyTo = &replyTo
+	}
+	if !m.EditedAt.IsZero() {
+		editedAt := m.EditedAt
+		msg.EditedAt = &editedAt
+	}
+	for _, mention := range m.Mentions {
+		msg.Mentions = append(msg.Mentions, mentionJSON{UserID: mention.UserID, Offset: mention.Offset,
+			Length: mention.Length})
+	}
+	for _, r := range m.Reactions {
+		msg.Reactions = append(msg.Reactions, reactionJSON{Emoji: r.Emoji, UserID: r.UserID})
+	}
+	return msg
+}
+
+func newMessagesJSON(list []database.Message, c database.Conversation) []messageJSON {
+	var messages = make([]messageJSON, 0, len(list))
+	for _, m := range list {
+		messages = append(messages, newMessageJSON(m, c))
+	}
+	return messages
+}
+
+// revisionJSON is the MessageRevision schema.
+type revisionJSON struct {
+	Content  string    `json:"content"`
+	EditedAt time.Time `json:"editedAt"`
+}
+
+func newRevisionsJSON(revisions []database.Revision) []revisionJSON {
+	var list = make([]revisionJSON, 0, len(revisions))
+	for _, r := range revisions {
+		list = append(list, revisionJSON{Content: r.Content, EditedAt: r.EditedAt})
+	}
+	return list
+}
+
+// searchResultJSON is the MessageSearchResult schema.
+type searchResultJSON struct {
+	ConversationID string      `json:"conversationId"`
+	Message        messageJSON `json:"message"`
+	Snippet        string      `json:"snippet"`
+}
+
+// messageState returns `read` if all the other members of the conversation have read the message, `delivered` if it
+// has been delivered to all of them, and `sent` otherwise.
+func messageState(m database.Message, c database.Conversation) string {
+	state := messageRead
+	others := 0
+	for _, member := range c.Members {
+		if member.UserID == m.SenderID {
+			continue
+		}
+		others++
+		if member.LastDeliveredID < m.ID {
+			return messageSent
+		} else if member.LastReadID < m.ID {
+			state = messageDelivered
+		}
+	}
+	if others == 0 {
+		return messageSent
+	}
+	return state
+}
+
+// conversationJSON is the Conversation schema.
+type conversationJSON struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Members     []string     `json:"members"`
+	Photo       string       `json:"photo,omitempty"`
+	IsGroup     bool         `json:"isGroup"`
+	LastMessage *messageJSON `json:"lastMessage,omitempty"`
+	Settings    settingsJSON `json:"settings"`
+
+	UnreadCount       int     `json:"unreadCount"`
+	MentionCount      int     `json:"mentionCount"`
+	LastReadMessageID *string `json:"lastReadMessageId,omitempty"`
+}
+
+// settingsJSON is the ConversationSettings schema.
+type settingsJSON struct {
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	Archived   bool       `json:"archived"`
+	Pinned     bool       `json:"pinned"`
+	PinnedAt   *time.Time `json:"pinnedAt,omitempty"`
+}
+
+// newSettingsJSON converts the settings of a member. Mute periods ended before `now` are omitted.
+func newSettingsJSON(s database.ConversationSettings, now time.Time) settingsJSON {
+	settings := settingsJSON{Archived: s.Archived, Pinned: !s.PinnedAt.IsZero()}
+	if s.Muted(now) {
+		mutedUntil := s.MutedUntil
+		settings.MutedUntil = &mutedUntil
+	}
+	if settings.Pinned {
+		pinnedAt := s.PinnedAt
+		settings.PinnedAt = &pinnedAt
+	}
+	return settings
+}
+
+// conversationDetailsJSON is the ConversationDetails schema.
+type conversationDetailsJSON struct {
+	conversationJSON
+	Messages []messageJSON `json:"messages"`
+}
+
+// groupJSON is the Group schema.
+type groupJSON struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Members     []string             `json:"members"`
+	Roles       []groupMemberJSON    `json:"roles"`
+	Permissions groupPermissionsJSON `json:"permissions"`
+	Photo       string               `json:"photo,omitempty"`
+}
+
+// groupMemberJSON is the GroupMember schema.
+type groupMemberJSON struct {
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// groupPermissionsJSON is the GroupPermissions schema.
+type groupPermissionsJSON struct {
+	Rename        string `json:"rename"`
+	AddMembers    string `json:"addMembers"`
+	RemoveMembers string `json:"removeMembers"`
+}
+
+func newGroupJSON(c database.Conversation) groupJSON {
+	g := groupJSON{
+		ID:      formatID(c.ID),
+		Name:    c.Name,
+		Photo:   c.Photo,
+		Members: make([]string, 0, len(c.Members)),
+		Roles:   make([]groupMemberJSON, 0, len(c.Members)),
+		Permissions: groupPermissionsJSON{
+			Rename:        c.Permissions.Rename,
+			AddMembers:    c.Permissions.AddMembers,
+			RemoveMembers: c.Permissions.RemoveMembers,
+		},
+	}
+	for _, m := range c.Members {
+		g.Members = append(g.Members, m.UserID)
+		g.Roles = append(g.Roles, groupMemberJSON{UserID: m.UserID, Role: m.Role})
+	}
+	return g
+}
+
+// inviteJSON is the GroupInvite schema.
+type inviteJSON struct {
+	Token     string     `json:"token"`
+	GroupID   string     `json:"groupId"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   *int       `json:"maxUses,omitempty"`
+	Uses      int        `json:"uses"`
+}
+
+func newInviteJSON(inv database.Invite) inviteJSON {
+	invite := inviteJSON{
+		Token:     inv.Token,
+		GroupID:   formatID(inv.ConversationID),
+		CreatedBy: inv.CreatedBy,
+		CreatedAt: inv.CreatedAt,
+		Uses:      inv.Uses,
+	}
+	if !inv.ExpiresAt.IsZero() {
+		expiresAt := inv.ExpiresAt
+		invite.ExpiresAt = &expiresAt
+	}
+	if inv.MaxUses != 0 {
+		maxUses := inv.MaxUses
+		invite.MaxUses = &maxUses
+	}
+	return invite
+}
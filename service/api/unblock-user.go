@@ -0,0 +1,28 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// unblockUser removes the block of the authenticated user on the given user, if any.
+func (rt *_router) unblockUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	userID := ps.ByName("userId")
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, err := tx.GetUser(r.Context(), userID); errors.Is(err, database.ErrNotFound) {
+			return errStatus(http.StatusNotFound, "user not found")
+		} else if err != nil {
+			return err
+		}
+		return tx.UnblockUser(r.Context(), ctx.UserID, userID)
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't unblock the user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/reqcontext"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+// uncommentMessage removes the reaction of the authenticated user to the message.
+func (rt *_router) uncommentMessage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, ctx reqcontext.RequestContext) {
+	id, ok := parseID(ps.ByName("messageId"))
+	if !ok {
+		sendError(w, ctx, http.StatusNotFound, "message not found")
+		return
+	}
+
+	err := rt.db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, _, err := memberMessage(r.Context(), tx, id, ctx.UserID); err != nil {
+			return err
+		}
+		err := tx.DeleteReaction(r.Context(), id, ctx.UserID)
+		if errors.Is(err, database.ErrNotFound) {
+			return errStatus(http.StatusNotFound, "reaction not found")
+		}
+		return err
+	})
+	if err != nil {
+		sendErrorFor(w, ctx, err, "can't remove the reaction")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,198 @@
+package api_test
+
+import (
+	"context"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLogin(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	if alice.ID == "" {
+		t.Fatal("doLogin returned an empty identifier")
+	}
+
+	// Logging in again returns the same user
+	if again := srv.LoginAs("alice"); again.ID != alice.ID {
+		t.Fatalf("second login identifier = %q, want %q", again.ID, alice.ID)
+	}
+	if bob := srv.LoginAs("bob"); bob.ID == alice.ID {
+		t.Fatal("different users have the same identifier")
+	}
+}
+
+func TestLoginInvalid(t *testing.T) {
+	srv := apitest.New(t)
+	for _, body := range []interface{}{
+		map[string]string{"name": "al", "photo": apitest.Photo},
+		map[string]string{"name": "alice!", "photo": apitest.Photo},
+		map[string]string{"name": "alice", "photo": ""},
+		map[string]string{"name": "alice", "photo": "not base64"},
+		strings.NewReader("{"),
+	} {
+		srv.Do(nil, http.MethodPost, "/session", body).AssertStatus(http.StatusBadRequest)
+	}
+}
+
+func TestLoginBanned(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	if err := srv.DB.SetUserBanned(context.Background(), alice.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.DB.DeleteSession(context.Background(), alice.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	login := map[string]string{"name": "alice", "photo": apitest.Photo}
+	srv.Do(nil, http.MethodPost, "/session", login).AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodPut, "/me/name", map[string]string{"name": "alice2"}).
+		AssertStatus(http.StatusUnauthorized)
+
+	if err := srv.DB.SetUserBanned(context.Background(), alice.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if again := srv.LoginAs("alice"); again.ID != alice.ID {
+		t.Fatalf("login after unban identifier = %q, want %q", again.ID, alice.ID)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	srv := apitest.New(t)
+	srv.Do(nil, http.MethodPut, "/me/name", map[string]string{"name": "alice"}).
+		AssertStatus(http.StatusUnauthorized)
+
+	// A token that is not the identifier of a logged in user
+	srv.Do(&apitest.User{Token: "unknown"}, http.MethodPut, "/me/name", map[string]string{"name": "alice"}).
+		AssertStatus(http.StatusUnauthorized)
+}
+
+func TestSetMyUserName(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	srv.LoginAs("bob")
+
+	srv.Do(alice, http.MethodPut, "/me/name", map[string]string{"name": "alice2"}).
+		AssertStatus(http.StatusOK).
+		AssertJSON(`{"id": "` + alice.ID + `", "name": "alice2", "photo": "` + apitest.Photo + `"}`)
+	srv.Do(alice, http.MethodPut, "/me/name", map[string]string{"name": "bob"}).
+		AssertStatus(http.StatusConflict)
+	srv.Do(alice, http.MethodPut, "/me/name", map[string]string{"name": "x"}).
+		AssertStatus(http.StatusBadRequest)
+
+	// The new name is used to log in
+	if again := srv.LoginAs("alice2"); again.ID != alice.ID {
+		t.Fatalf("login with the new name returned %q, want %q", again.ID, alice.ID)
+	}
+}
+
+func TestSetMyPhoto(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+
+	res := srv.Do(alice, http.MethodPut, "/me/photo", strings.NewReader("d29ybGQ="))
+	res.AssertStatus(http.StatusBadRequest)
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/me/photo", strings.NewReader("d29ybGQ="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+alice.Token)
+	req.Header.Set("Content-Type", "image/png")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("setMyPhoto status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	users, err := srv.DB.SearchUsers(context.Background(), "alice", "", 1)
+	if err != nil || len(users) != 1 || users[0].Photo != "d29ybGQ=" {
+		t.Fatalf("users after setMyPhoto = %+v, %v", users, err)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	srv.LoginAs("bobby")
+
+	var users []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	srv.Do(alice, http.MethodGet, "/users/search?name=BOB", nil).
+		AssertStatus(http.StatusOK).
+		DecodeJSON(&users)
+	if len(users) != 2 || users[0].ID != bob.ID || users[1].Name != "bobby" {
+		t.Fatalf("searchUsers = %+v, want bob and bobby", users)
+	}
+
+	srv.Do(alice, http.MethodGet, "/users/search?name=nobody", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+	srv.Do(alice, http.MethodGet, "/users/search", nil).AssertStatus(http.StatusBadRequest)
+}
+
+func TestBlockUser(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	carol := srv.LoginAs("carol")
+	direct := srv.StartConversation(alice, bob.ID)
+	group := srv.CreateGroup(alice, "friends")
+
+	srv.Do(bob, http.MethodGet, "/me/blocked", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+	srv.Do(bob, http.MethodPut, "/me/blocked/"+bob.ID, nil).AssertStatus(http.StatusBadRequest)
+	srv.Do(bob, http.MethodPut, "/me/blocked/missing", nil).AssertStatus(http.StatusNotFound)
+	// Blocking twice has no effect
+	srv.Do(bob, http.MethodPut, "/me/blocked/"+alice.ID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodPut, "/me/blocked/"+alice.ID, nil).AssertStatus(http.StatusNoContent)
+
+	var users []struct {
+		ID string `json:"id"`
+	}
+	srv.Do(bob, http.MethodGet, "/me/blocked", nil).AssertStatus(http.StatusOK).DecodeJSON(&users)
+	if len(users) != 1 || users[0].ID != alice.ID {
+		t.Fatalf("getBlockedUsers = %+v, want alice", users)
+	}
+
+	// alice can't reach bob anymore, but bob can still write to alice
+	srv.Do(bob, http.MethodGet, "/users/search?name=alice", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+	srv.Do(alice, http.MethodPost, "/conversations", map[string]string{"userId": bob.ID}).
+		AssertStatus(http.StatusForbidden)
+	srv.DoMultipart(alice, http.MethodPost, "/conversations/"+direct, map[string]string{"content": "hi"}).
+		AssertStatus(http.StatusForbidden)
+	srv.Do(alice, http.MethodPost, "/groups/"+group+"/members", map[string][]string{"userIds": {carol.ID, bob.ID}}).
+		AssertStatus(http.StatusForbidden)
+	srv.DoMultipart(alice, http.MethodPost, "/groups", map[string]string{
+		"name": "with bob", "membersJson": `["` + carol.ID + `","` + bob.ID + `"]`, "image": apitest.Photo,
+	}).AssertStatus(http.StatusForbidden)
+	srv.Do(carol, http.MethodGet, "/conversations", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+	srv.SendMessage(bob, direct, "hi")
+	srv.Do(alice, http.MethodGet, "/users/search?name=bob", nil).AssertStatus(http.StatusOK).DecodeJSON(&users)
+	if len(users) != 1 {
+		t.Fatalf("searchUsers by the blocked user = %+v, want bob", users)
+	}
+
+	// The failed addToGroup added nobody
+	var g struct {
+		Members []string `json:"members"`
+	}
+	srv.Do(alice, http.MethodGet, "/conversations/"+group, nil).AssertStatus(http.StatusOK).DecodeJSON(&g)
+	if len(g.Members) != 1 {
+		t.Fatalf("group members after a failed addToGroup = %+v, want only alice", g.Members)
+	}
+
+	srv.Do(bob, http.MethodDelete, "/me/blocked/missing", nil).AssertStatus(http.StatusNotFound)
+	srv.Do(bob, http.MethodDelete, "/me/blocked/"+alice.ID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodDelete, "/me/blocked/"+alice.ID, nil).AssertStatus(http.StatusNoContent)
+	srv.Do(bob, http.MethodGet, "/me/blocked", nil).AssertStatus(http.StatusOK).AssertJSON(`[]`)
+	srv.SendMessage(alice, direct, "hi again")
+	srv.Do(alice, http.MethodPost, "/groups/"+group+"/members", map[string][]string{"userIds": {bob.ID}}).
+		AssertStatus(http.StatusOK)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"encoding/base64"
+	"regexp"
+)
+
+// Patterns and limits from doc/api.yaml.
+var (
+	idRx        = regexp.MustCompile(`^[a-zA-Z0-9_]{1,50}$`)
+	userNameRx  = regexp.MustCompile(`^[a-zA-Z0-9_]{3,16}$`)
+	searchRx    = regexp.MustCompile(`^[a-zA-Z0-9_]{1,50}$`)
+	groupNameRx = regexp.MustCompile(`^[a-zA-Z0-9_ ]{3,50}$`)
+)
+
+// maxImageLength is the maximum length of a Base64 image.
+const maxImageLength = 10 * 1024 * 1024
+
+// isValidImage returns true if `s` is a valid Base64 string, not longer than maxImageLength. The empty string is valid:
+// check it separately where an image is required.
+func isValidImage(s string) bool {
+	if len(s) > maxImageLength {
+		return false
+	}
+	_, err := base64.StdEncoding.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,229 @@
+/*
+Package backup takes periodic online backups of the SQLite database into a directory, keeping only the most recent
+ones. Backups are taken while the database is in use (see database.Backup).
+
+To use this package, create a Scheduler with New, and stop it with Close when the program terminates:
+
+	scheduler, err := backup.New(backup.Config{
+		Logger:    logger,
+		Clock:     globaltime.Real(),
+		DB:        dbconn,
+		Directory: "/var/backups/wasatext",
+		Interval:  24 * time.Hour,
+		Retention: 7,
+	})
+	if err != nil {
+		return err
+	}
+	defer scheduler.Close()
+
+Backups can also be triggered at any time with Scheduler.BackupNow.
+*/
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filePrefix and fileSuffix surround the timestamp in backup file names. Only files with this name format are removed
+// by the retention policy.
+const (
+	filePrefix = "backup-"
+	fileSuffix = ".db"
+	timeFormat = "20060102T150405Z"
+)
+
+// Config is used to provide dependencies and configuration to the New function.
+type Config struct {
+	// Logger where log entries are sent
+	Logger logrus.FieldLogger
+
+	// Clock is the source of time, for backup names and scheduling
+	Clock globaltime.Clock
+
+	// DB is the database to back up
+	DB *sql.DB
+
+	// Directory is where backup files are written. It's created if it doesn't exist
+	Directory string
+
+	// Interval is the time between scheduled backups. Zero disables scheduled backups (BackupNow still works)
+	Interval time.Duration
+
+	// Retention is the number of backups to keep. Older backups are removed after each new backup. Zero keeps all
+	// backups
+	Retention int
+}
+
+// Scheduler takes backups periodically, and on demand.
+type Scheduler struct {
+	cfg Config
+
+	// mu serializes backups, so that scheduled and on-demand backups never run together
+	mu sync.Mutex
+
+	// errMu protects lastErr, the error of the last scheduled backup
+	errMu   sync.Mutex
+	lastErr error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates the backup directory and starts the scheduler.
+func New(cfg Config) (*Scheduler, error) {
+	if cfg.Logger == nil {
+		return nil, errors.New("logger is required")
+	}
+	if cfg.Clock == nil {
+		return nil, errors.New("clock is required")
+	}
+	if cfg.DB == nil {
+		return nil, errors.New("database is required")
+	}
+	if cfg.Directory == "" {
+		return nil, errors.New("backup directory is required")
+	}
+	if cfg.Retention < 0 {
+		return nil, errors.New("retention must not be negative")
+	}
+	if err := os.MkdirAll(cfg.Directory, 0o750); err != nil {
+		return nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if cfg.Interval > 0 {
+		go s.loop(cfg.Clock.NewTicker(cfg.Interval))
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+func (s *Scheduler) loop(ticker globaltime.Ticker) {
+	defer close(s.done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C():
+			_, err := s.BackupNow(s.ctx)
+			if errors.Is(err, context.Canceled) {
+				continue
+			} else if err != nil {
+				s.cfg.Logger.WithError(err).Error("scheduled backup failed")
+			}
+			s.errMu.Lock()
+			s.lastErr = err
+			s.errMu.Unlock()
+		}
+	}
+}
+
+// BackupNow takes a backup immediately, applies the retention policy, and returns the path of the new backup file.
+func (s *Scheduler) BackupNow(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := s.cfg.Clock.Now()
+	dest := filepath.Join(s.cfg.Directory, filePrefix+started.UTC().Format(timeFormat)+fileSuffix)
+	if err := database.Backup(ctx, s.cfg.DB, dest); err != nil {
+		return "", fmt.Errorf("backing up to %s: %w", dest, err)
+	}
+	s.cfg.Logger.WithField("file", dest).WithField("duration", s.cfg.Clock.Since(started)).Info("backup completed")
+
+	if err := s.prune(); err != nil {
+		s.cfg.Logger.WithError(err).Warning("removing old backups")
+	}
+	return dest, nil
+}
+
+// List returns the backup files in the backup directory, oldest first.
+func (s *Scheduler) List() ([]string, error) {
+	return List(s.cfg.Directory)
+}
+
+// prune removes the oldest backups exceeding the retention.
+func (s *Scheduler) prune() error {
+	if s.cfg.Retention == 0 {
+		return nil
+	}
+	files, err := s.List()
+	if err != nil {
+		return err
+	}
+	for len(files) > s.cfg.Retention {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// Check returns an error if scheduled backups are enabled but stopped, or if the last one failed. It can be used as a
+// readiness check.
+func (s *Scheduler) Check(context.Context) error {
+	if s.cfg.Interval > 0 {
+		select {
+		case <-s.done:
+			return errors.New("backup scheduler stopped")
+		default:
+		}
+	}
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.lastErr != nil {
+		return fmt.Errorf("last backup failed: %w", s.lastErr)
+	}
+	return nil
+}
+
+// Close stops scheduled backups, waiting for a running backup to be canceled.
+func (s *Scheduler) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// List returns the backup files in `dir`, oldest first. Files not named like backups are ignored.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		if _, err := time.Parse(timeFormat, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	// The timestamp format sorts lexicographically
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,185 @@
+package backup_test
+
+import (
+	"context"
+	"database/sql"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/backup"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newScheduler(t *testing.T, clock globaltime.Clock, dir string, interval time.Duration, retention int) *backup.Scheduler {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec("CREATE TABLE t (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s, err := backup.New(backup.Config{
+		Logger:    logger,
+		Clock:     clock,
+		DB:        db,
+		Directory: dir,
+		Interval:  interval,
+		Retention: retention,
+	})
+	if err != nil {
+		t.Fatalf("creating scheduler: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// waitForBackups waits until the last backup in `dir` is `want`, as scheduled backups run in another goroutine.
+func waitForBackups(t *testing.T, dir string, want string) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files, err := backup.List(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) > 0 && files[len(files)-1] == want {
+			return files
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("backup %s not taken, backups: %v", want, files)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduledBackupsAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	clock := globaltime.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newScheduler(t, clock, dir, time.Hour, 2)
+
+	var files []string
+	for _, want := range []string{"backup-20240101T010000Z.db", "backup-20240101T020000Z.db", "backup-20240101T030000Z.db"} {
+		clock.Advance(time.Hour)
+		files = waitForBackups(t, dir, filepath.Join(dir, want))
+	}
+
+	// Only the two most recent backups are kept
+	want := []string{
+		filepath.Join(dir, "backup-20240101T020000Z.db"),
+		filepath.Join(dir, "backup-20240101T030000Z.db"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("backups = %v, want %v", files, want)
+	}
+}
+
+func TestBackupNowWithoutSchedule(t *testing.T) {
+	dir := t.TempDir()
+	clock := globaltime.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := newScheduler(t, clock, dir, 0, 0)
+
+	if n := clock.Pending(); n != 0 {
+		t.Fatalf("%d timers pending with scheduled backups disabled, want 0", n)
+	}
+
+	var want []string
+	for i := 0; i < 3; i++ {
+		file, err := s.BackupNow(context.Background())
+		if err != nil {
+			t.Fatalf("BackupNow: %v", err)
+		}
+		want = append(want, file)
+		clock.Advance(time.Minute)
+	}
+
+	// Zero retention keeps all backups
+	files, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("backups = %v, want %v", files, want)
+	}
+}
+
+func TestListIgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"backup-20240102T000000Z.db",
+		"backup-20240101T000000Z.db",
+		"backup-notatime.db",
+		"backup-20240103T000000Z.db.tmp",
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "backup-20240104T000000Z.db"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := backup.List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "backup-20240101T000000Z.db"),
+		filepath.Join(dir, "backup-20240102T000000Z.db"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Fatalf("List = %v, want %v", files, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	clock := globaltime.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := newScheduler(t, clock, dir, time.Hour, 0)
+	ctx := context.Background()
+
+	if err := s.Check(ctx); err != nil {
+		t.Fatalf("Check() before any backup = %v, want nil", err)
+	}
+
+	// Backups fail while the directory is missing
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Check(ctx) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Check() succeeded after a failed backup")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A successful backup clears the error
+	if err := os.Mkdir(dir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	waitForBackups(t, dir, filepath.Join(dir, "backup-20240101T020000Z.db"))
+	for s.Check(ctx) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Check() after a successful backup = %v, want nil", s.Check(ctx))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_ = s.Close()
+	if err := s.Check(ctx); err == nil {
+		t.Fatal("Check() after Close succeeded")
+	}
+}
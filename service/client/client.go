@@ -0,0 +1,213 @@
+/*
+Package client is a typed Go client for the WASAText API described in doc/api.yaml. Each operation of the API has a
+method named after its operationId (e.g., DoLogin, SendMessage, ForwardMessage, CreateGroup).
+
+Example:
+
+	c, err := client.New(client.Config{BaseURL: "http://localhost:3000"})
+	if err != nil {
+		return err
+	}
+
+	// Log in: the returned identifier is the bearer token for the other operations
+	id, err := c.DoLogin(ctx, "gopher", photo)
+	if err != nil {
+		return err
+	}
+	c = c.WithToken(id)
+
+	conversations, err := c.GetMyConversations(ctx, false)
+
+Live events are read from the stream returned by StreamEvents, until the context is canceled:
+
+	stream, err := c.StreamEvents(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for {
+		ev, err := stream.Next()
+		if err != nil {
+			return err
+		}
+		if ev.Name == client.EventMessageEdited {
+			fmt.Println("edited:", ev.Message.Message.Content)
+		}
+	}
+
+Errors for unexpected status codes are of type *APIError, and can be checked with errors.Is against the sentinel
+errors (ErrNotFound, ErrForbidden, etc.).
+
+A Client is safe for concurrent use.
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Config is used to provide the configuration to the New function.
+type Config struct {
+	// BaseURL is the URL of the API server, e.g. http://localhost:3000
+	BaseURL string
+
+	// HTTPClient is the HTTP client used for requests. If nil, http.DefaultClient is used
+	HTTPClient *http.Client
+
+	// Token is the bearer token sent in the Authorization header. It can be set later with Client.WithToken
+	Token string
+}
+
+// Client is the API client. Create it with New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+}
+
+// New returns a new Client instance.
+func New(cfg Config) (*Client, error) {
+	if cfg.BaseURL == "" {
+		return nil, errors.New("base URL is required")
+	}
+	if _, err := url.Parse(cfg.BaseURL); err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(cfg.BaseURL, "/"),
+		httpClient: cfg.HTTPClient,
+		token:      cfg.Token,
+	}, nil
+}
+
+// WithToken returns a copy of the client that authenticates with `token` (usually, the identifier returned by
+// DoLogin).
+func (c *Client) WithToken(token string) *Client {
+	cp := *c
+	cp.token = token
+	return &cp
+}
+
+// request describes a single API call.
+type request struct {
+	operation string
+	method    string
+	path      string
+	query     url.Values
+
+	// body is the request body, and contentType its type. Use jsonBody or multipartBody to fill them
+	body        io.Reader
+	contentType string
+
+	// status is the expected status code of the response
+	status int
+}
+
+// send sends the request, accepting responses of type `accept` (if not empty). The caller must close the body of the
+// response.
+func (c *Client) send(ctx context.Context, req request, accept string) (*http.Response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, req.body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", req.operation, err)
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if accept != "" {
+		httpReq.Header.Set("Accept", accept)
+	}
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", req.operation, err)
+	}
+	return res, nil
+}
+
+// do sends the request and decodes the JSON response in `out` (if not nil). Responses with a status code different
+// from req.status are returned as *APIError.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var accept string
+	if out != nil {
+		accept = "application/json"
+	}
+	res, err := c.send(ctx, req, accept)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("%s: reading response: %w", req.operation, err)
+	}
+	if res.StatusCode != req.status {
+		return newAPIError(req.operation, res, body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("%s: decoding response: %w", req.operation, err)
+		}
+	}
+	return nil
+}
+
+// jsonBody encodes `v` as the JSON body of the request.
+func (r *request) jsonBody(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s: encoding request: %w", r.operation, err)
+	}
+	r.body = bytes.NewReader(data)
+	r.contentType = "application/json"
+	return nil
+}
+
+// multipartBody encodes `fields` as the multipart/form-data body of the request. Empty fields are omitted.
+func (r *request) multipartBody(fields map[string]string) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := mw.WriteField(name, value); err != nil {
+			return fmt.Errorf("%s: encoding request: %w", r.operation, err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("%s: encoding request: %w", r.operation, err)
+	}
+	r.body = &buf
+	r.contentType = mw.FormDataContentType()
+	return nil
+}
+
+// pathf formats a path, escaping each argument as a path segment.
+func pathf(format string, args ...string) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		escaped[i] = url.PathEscape(arg)
+	}
+	return fmt.Sprintf(format, escaped...)
+}
@@ -0,0 +1,196 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/client"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a Client sending requests to `handler`, with the bearer token `token`.
+func newTestClient(t *testing.T, token string, handler http.HandlerFunc) *client.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c, err := client.New(client.Config{BaseURL: srv.URL + "/", HTTPClient: srv.Client(), Token: token})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	return c
+}
+
+func TestBearerToken(t *testing.T) {
+	var got []string
+	c := newTestClient(t, "", func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ctx := context.Background()
+
+	if err := c.UncommentMessage(ctx, "1"); err != nil {
+		t.Fatalf("UncommentMessage() error: %v", err)
+	}
+	// WithToken returns a copy, without changing the original client
+	if err := c.WithToken("abc").UncommentMessage(ctx, "1"); err != nil {
+		t.Fatalf("UncommentMessage() error: %v", err)
+	}
+	if err := c.UncommentMessage(ctx, "1"); err != nil {
+		t.Fatalf("UncommentMessage() error: %v", err)
+	}
+
+	want := []string{"", "Bearer abc", ""}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("Authorization headers = %q, want %q", got, want)
+	}
+}
+
+func TestRequestEncoding(t *testing.T) {
+	c := newTestClient(t, "abc", func(w http.ResponseWriter, r *http.Request) {
+		// Path arguments are escaped
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/conversations/a%2Fb" {
+			t.Errorf("request = %s %s, want POST /conversations/a%%2Fb", r.Method, r.URL.EscapedPath())
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parsing the multipart form: %v", err)
+		}
+		// Empty fields are omitted
+		for name, want := range map[string]string{"content": "hello", "replyTo": "7", "forwarded": "true"} {
+			if got := r.MultipartForm.Value[name]; len(got) != 1 || got[0] != want {
+				t.Errorf("field %s = %q, want %q", name, got, want)
+			}
+		}
+		if _, ok := r.MultipartForm.Value["attachment"]; ok {
+			t.Error("empty attachment sent")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id": "8", "content": "hello", "replyTo": "7", "isForwarded": true}`)
+	})
+
+	msg, err := c.SendMessage(context.Background(), "a/b", client.SendMessageRequest{
+		Content:   "hello",
+		ReplyTo:   "7",
+		Forwarded: true,
+	})
+	if err != nil {
+		t.Fatalf("SendMessage() error: %v", err)
+	}
+	if msg.ID != "8" || msg.ReplyTo == nil || *msg.ReplyTo != "7" || !msg.IsForwarded {
+		t.Fatalf("SendMessage() = %+v", msg)
+	}
+}
+
+func TestDeleteMessageScope(t *testing.T) {
+	var queries []string
+	c := newTestClient(t, "abc", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/messages/7" {
+			t.Errorf("request = %s %s, want DELETE /messages/7", r.Method, r.URL.Path)
+		}
+		queries = append(queries, r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// The scope is sent only when set
+	for _, scope := range []string{"", client.DeleteForMe, client.DeleteForEveryone} {
+		if err := c.DeleteMessage(context.Background(), "7", scope); err != nil {
+			t.Fatalf("DeleteMessage(%q) error: %v", scope, err)
+		}
+	}
+	if want := []string{"", "scope=me", "scope=everyone"}; !reflect.DeepEqual(queries, want) {
+		t.Fatalf("queries = %q, want %q", queries, want)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		sentinel    error
+		message     string
+	}{
+		{"JSON", http.StatusNotFound, "application/json", `{"error": "message not found"}`, client.ErrNotFound,
+			"message not found"},
+		{"PlainText", http.StatusForbidden, "text/plain", "not allowed\n", client.ErrForbidden, "not allowed"},
+		{"JSONWithoutError", http.StatusConflict, "application/json", `{"other": 1}`, client.ErrConflict,
+			`{"other": 1}`},
+		{"EmptyBody", http.StatusGone, "", "", client.ErrGone, ""},
+		{"UnexpectedSuccess", http.StatusOK, "", "", nil, ""},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestClient(t, "abc", func(w http.ResponseWriter, r *http.Request) {
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				w.WriteHeader(tc.status)
+				_, _ = io.WriteString(w, tc.body)
+			})
+
+			err := c.UncommentMessage(context.Background(), "1")
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *client.APIError", err)
+			}
+			if apiErr.Operation != "uncommentMessage" || apiErr.StatusCode != tc.status || apiErr.Message != tc.message {
+				t.Fatalf("error = %+v", apiErr)
+			}
+			if tc.sentinel != nil && !errors.Is(err, tc.sentinel) {
+				t.Fatalf("errors.Is(%v, %v) = false", err, tc.sentinel)
+			}
+			for _, other := range []error{client.ErrBadRequest, client.ErrUnauthorized, client.ErrTooManyRequests} {
+				if errors.Is(err, other) {
+					t.Fatalf("errors.Is(%v, %v) = true", err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	c := newTestClient(t, "abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "20")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = io.WriteString(w, `{"error": "too many requests"}`)
+	})
+
+	err := c.CommentMessage(context.Background(), "1", "👍")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrTooManyRequests) {
+		t.Fatalf("error = %v, want an *APIError matching ErrTooManyRequests", err)
+	}
+	if apiErr.RetryAfter != "20" {
+		t.Fatalf("RetryAfter = %q, want 20", apiErr.RetryAfter)
+	}
+	if want := "commentMessage: 429 Too Many Requests: too many requests"; err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	spec, err := os.ReadFile(filepath.Join("..", "..", "doc", "api.yaml"))
+	if err != nil {
+		t.Fatalf("reading the spec: %v", err)
+	}
+	ids := regexp.MustCompile(`(?m)^\s+operationId: (\w+)$`).FindAllStringSubmatch(string(spec), -1)
+	if len(ids) == 0 {
+		t.Fatal("no operations in the spec")
+	}
+	// Each operation has a method named after its operationId
+	typ := reflect.TypeOf(&client.Client{})
+	for _, id := range ids {
+		if _, ok := typ.MethodByName(strings.ToUpper(id[1][:1]) + id[1][1:]); !ok {
+			t.Errorf("no method for the operation %s", id[1])
+		}
+	}
+}
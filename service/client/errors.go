@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors for the status codes declared in the API. Use errors.Is on errors returned by the client, e.g.:
+//
+//	if errors.Is(err, client.ErrNotFound) { ... }
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrGone            = errors.New("gone")
+	ErrTooLarge        = errors.New("request entity too large")
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// APIError is returned when the server replies with an unexpected status code.
+type APIError struct {
+	// Operation is the operationId of the request, as in doc/api.yaml
+	Operation string
+
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+
+	// Message is the error message sent by the server, if any
+	Message string
+
+	// RetryAfter is the value of the Retry-After header (in seconds), sent with HTTP 429
+	RetryAfter string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %d %s", e.Operation, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Is makes errors.Is match the sentinel error corresponding to the status code.
+func (e *APIError) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusGone:
+		return target == ErrGone
+	case http.StatusRequestEntityTooLarge:
+		return target == ErrTooLarge
+	case http.StatusTooManyRequests:
+		return target == ErrTooManyRequests
+	}
+	return false
+}
+
+// newAPIError builds the error for a response with an unexpected status code. The message is read from the `error`
+// field if the body is a JSON object, otherwise the body is used as plain text.
+func newAPIError(operation string, res *http.Response, body []byte) *APIError {
+	e := &APIError{
+		Operation:  operation,
+		StatusCode: res.StatusCode,
+		RetryAfter: res.Header.Get("Retry-After"),
+	}
+
+	var envelope struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Error != "" {
+		e.Message = envelope.Error
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Names of the events sent by streamEvents.
+const (
+	EventMessageEdited  = "messageEdited"
+	EventMessageDeleted = "messageDeleted"
+)
+
+// MessageEvent is the data of the events about a message, with the message after the change.
+type MessageEvent struct {
+	ConversationID string  `json:"conversationId"`
+	Message        Message `json:"message"`
+}
+
+// Event is an event received from an EventStream.
+type Event struct {
+	// Name is the name of the event (e.g., EventMessageEdited)
+	Name string
+
+	// Message is the decoded data of the message events, nil for the other events
+	Message *MessageEvent
+
+	// Data is the JSON data of the event
+	Data json.RawMessage
+}
+
+// EventStream reads the events sent by streamEvents. Create it with Client.StreamEvents.
+type EventStream struct {
+	ctx  context.Context
+	body io.ReadCloser
+	r    *bufio.Reader
+}
+
+// StreamEvents opens the stream of live events of the authenticated user. The stream ends when `ctx` is canceled, or
+// when the server closes it: clients should then open a new one, and reload the conversations.
+func (c *Client) StreamEvents(ctx context.Context) (*EventStream, error) {
+	req := request{operation: "streamEvents", method: http.MethodGet, path: "/events", status: http.StatusOK}
+	res, err := c.send(ctx, req, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != req.status {
+		defer func() { _ = res.Body.Close() }()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: reading response: %w", req.operation, err)
+		}
+		return nil, newAPIError(req.operation, res, body)
+	}
+	return &EventStream{ctx: ctx, body: res.Body, r: bufio.NewReader(res.Body)}, nil
+}
+
+// Next waits for the next event. It returns io.EOF when the server closes the stream, and the error of the context
+// when it's canceled. Comments (like keep-alives) and the other fields of the stream are skipped.
+func (s *EventStream) Next() (Event, error) {
+	var ev Event
+	var data []string
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			if ctxErr := s.ctx.Err(); ctxErr != nil {
+				return Event{}, ctxErr
+			} else if errors.Is(err, io.EOF) {
+				return Event{}, io.EOF
+			}
+			return Event{}, fmt.Errorf("streamEvents: reading events: %w", err)
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			// A blank line ends the event, which is sent only if it has some data
+			if len(data) > 0 {
+				break
+			}
+			ev = Event{}
+			continue
+		} else if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Name = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+
+	if ev.Name == "" {
+		ev.Name = "message"
+	}
+	ev.Data = json.RawMessage(strings.Join(data, "\n"))
+	if ev.Name == EventMessageEdited || ev.Name == EventMessageDeleted {
+		ev.Message = new(MessageEvent)
+		if err := json.Unmarshal(ev.Data, ev.Message); err != nil {
+			return Event{}, fmt.Errorf("streamEvents: decoding %s event: %w", ev.Name, err)
+		}
+	}
+	return ev, nil
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
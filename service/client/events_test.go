@@ -0,0 +1,92 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/api/apitest"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/client"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	c := newTestClient(t, "abc", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/events" || r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("request = %s %s, Accept %q", r.Method, r.URL.Path, r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "retry: 1000\n\n: keep-alive\n\n"+
+			"event: messageEdited\r\ndata: {\"conversationId\": \"1\",\r\ndata:\"message\": {\"id\": \"2\"}}\r\n\r\n"+
+			"event: other\ndata: [1]\n\ndata: 2\n\n")
+	})
+
+	stream, err := c.StreamEvents(context.Background())
+	if err != nil {
+		t.Fatalf("StreamEvents() error: %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	// Data lines are joined, and only the message events are decoded
+	ev, err := stream.Next()
+	if err != nil || ev.Name != client.EventMessageEdited || ev.Message == nil || ev.Message.ConversationID != "1" ||
+		ev.Message.Message.ID != "2" {
+		t.Fatalf("Next() = %+v, %v; want the messageEdited event", ev, err)
+	}
+	if ev, err := stream.Next(); err != nil || ev.Name != "other" || ev.Message != nil || string(ev.Data) != "[1]" {
+		t.Fatalf("Next() = %+v, %v; want the other event", ev, err)
+	}
+	// Events without a name are named `message`
+	if ev, err := stream.Next(); err != nil || ev.Name != "message" || string(ev.Data) != "2" {
+		t.Fatalf("Next() = %+v, %v; want an event named message", ev, err)
+	}
+	if _, err := stream.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Next() at the end of the stream: error = %v, want io.EOF", err)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv := apitest.New(t)
+	alice := srv.LoginAs("alice")
+	bob := srv.LoginAs("bob")
+	id := srv.StartConversation(alice, bob.ID)
+	msgID := srv.SendMessage(alice, id, "helo")
+	newClient := func(token string) *client.Client {
+		c, err := client.New(client.Config{BaseURL: srv.URL, HTTPClient: srv.Client(), Token: token})
+		if err != nil {
+			t.Fatalf("New() error: %v", err)
+		}
+		return c
+	}
+
+	if _, err := newClient("").StreamEvents(context.Background()); !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("StreamEvents() without a token: error = %v, want client.ErrUnauthorized", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := newClient(bob.Token).StreamEvents(ctx)
+	if err != nil {
+		t.Fatalf("StreamEvents() error: %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	if _, err := newClient(alice.Token).EditMessage(context.Background(), msgID, "hello"); err != nil {
+		t.Fatalf("EditMessage() error: %v", err)
+	}
+	ev, err := stream.Next()
+	if err != nil || ev.Name != client.EventMessageEdited || ev.Message.ConversationID != id ||
+		ev.Message.Message.Content != "hello" {
+		t.Fatalf("Next() = %+v, %v; want the edit", ev, err)
+	}
+
+	// Canceling the context ends a pending Next
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := stream.Next(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Next() after cancel: error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,388 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Photo content types accepted by SetMyPhoto and SetGroupPhoto.
+const (
+	PhotoPNG  = "image/png"
+	PhotoJPEG = "image/jpeg"
+)
+
+// DoLogin logs in the user with the given name, creating it if it doesn't exist. `photo` is the Base64 profile photo.
+// It returns the user identifier, to be used as token with WithToken.
+func (c *Client) DoLogin(ctx context.Context, name string, photo string) (string, error) {
+	req := request{operation: "doLogin", method: http.MethodPost, path: "/session", status: http.StatusCreated}
+	if err := req.jsonBody(map[string]string{"name": name, "photo": photo}); err != nil {
+		return "", err
+	}
+
+	var reply struct {
+		Identifier string `json:"identifier"`
+	}
+	err := c.do(ctx, req, &reply)
+	return reply.Identifier, err
+}
+
+// SetMyUserName changes the name of the authenticated user. It returns ErrConflict if the name is already in use.
+func (c *Client) SetMyUserName(ctx context.Context, name string) (*User, error) {
+	req := request{operation: "setMyUserName", method: http.MethodPut, path: "/me/name", status: http.StatusOK}
+	if err := req.jsonBody(map[string]string{"name": name}); err != nil {
+		return nil, err
+	}
+
+	var user User
+	return &user, c.do(ctx, req, &user)
+}
+
+// SetMyPhoto changes the profile photo of the authenticated user. `contentType` is PhotoPNG or PhotoJPEG, and `photo`
+// is the Base64 image.
+func (c *Client) SetMyPhoto(ctx context.Context, contentType string, photo string) (*User, error) {
+	req := request{operation: "setMyPhoto", method: http.MethodPut, path: "/me/photo", status: http.StatusOK,
+		body: strings.NewReader(photo), contentType: contentType}
+
+	var user User
+	return &user, c.do(ctx, req, &user)
+}
+
+// GetBlockedUsers returns the users blocked by the authenticated user.
+func (c *Client) GetBlockedUsers(ctx context.Context) ([]User, error) {
+	req := request{operation: "getBlockedUsers", method: http.MethodGet, path: "/me/blocked", status: http.StatusOK}
+
+	var users []User
+	return users, c.do(ctx, req, &users)
+}
+
+// BlockUser blocks the user `userID`: they cannot start conversations or send messages to the authenticated user, nor
+// add them to groups.
+func (c *Client) BlockUser(ctx context.Context, userID string) error {
+	req := request{operation: "blockUser", method: http.MethodPut, path: pathf("/me/blocked/%s", userID),
+		status: http.StatusNoContent}
+	return c.do(ctx, req, nil)
+}
+
+// UnblockUser removes the block on the user `userID`.
+func (c *Client) UnblockUser(ctx context.Context, userID string) error {
+	req := request{operation: "unblockUser", method: http.MethodDelete, path: pathf("/me/blocked/%s", userID),
+		status: http.StatusNoContent}
+	return c.do(ctx, req, nil)
+}
+
+// SearchUsers returns the users whose name contains `name`.
+func (c *Client) SearchUsers(ctx context.Context, name string) ([]User, error) {
+	req := request{operation: "searchUsers", method: http.MethodGet, path: "/users/search", status: http.StatusOK,
+		query: url.Values{"name": {name}}}
+
+	var users []User
+	return users, c.do(ctx, req, &users)
+}
+
+// GetMyConversations returns the conversations of the authenticated user: pinned conversations first, then the others
+// sorted by newest message. Archived conversations are included only if `archived` is true.
+func (c *Client) GetMyConversations(ctx context.Context, archived bool) ([]Conversation, error) {
+	req := request{operation: "getMyConversations", method: http.MethodGet, path: "/conversations",
+		status: http.StatusOK}
+	if archived {
+		req.query = url.Values{"archived": {"true"}}
+	}
+
+	var conversations []Conversation
+	return conversations, c.do(ctx, req, &conversations)
+}
+
+// StartNewConversation starts a one-to-one conversation with the user `userID`.
+func (c *Client) StartNewConversation(ctx context.Context, userID string) (*ConversationDetails, error) {
+	req := request{operation: "startNewConversation", method: http.MethodPost, path: "/conversations",
+		status: http.StatusCreated}
+	if err := req.jsonBody(map[string]string{"userId": userID}); err != nil {
+		return nil, err
+	}
+
+	var details ConversationDetails
+	return &details, c.do(ctx, req, &details)
+}
+
+// SetConversationSettings mutes, archives or pins a conversation for the authenticated user.
+func (c *Client) SetConversationSettings(ctx context.Context, conversationID string,
+	settings ConversationSettings) (*ConversationSettings, error) {
+	req := request{operation: "setConversationSettings", method: http.MethodPut,
+		path: pathf("/conversations/%s/settings", conversationID), status: http.StatusOK}
+	if err := req.jsonBody(map[string]interface{}{
+		"mutedUntil": settings.MutedUntil,
+		"archived":   settings.Archived,
+		"pinned":     settings.Pinned,
+	}); err != nil {
+		return nil, err
+	}
+
+	var updated ConversationSettings
+	return &updated, c.do(ctx, req, &updated)
+}
+
+// MarkConversationRead moves the read marker of the authenticated user to the message `messageID`, or to the newest
+// message if `messageID` is empty.
+func (c *Client) MarkConversationRead(ctx context.Context, conversationID string, messageID string) error {
+	req := request{operation: "markConversationRead", method: http.MethodPost,
+		path: pathf("/conversations/%s/read", conversationID), status: http.StatusNoContent}
+	if messageID != "" {
+		if err := req.jsonBody(map[string]string{"messageId": messageID}); err != nil {
+			return err
+		}
+	}
+	return c.do(ctx, req, nil)
+}
+
+// GetConversation returns a conversation with all its messages.
+func (c *Client) GetConversation(ctx context.Context, conversationID string) (*ConversationDetails, error) {
+	req := request{operation: "getConversation", method: http.MethodGet,
+		path: pathf("/conversations/%s", conversationID), status: http.StatusOK}
+
+	var details ConversationDetails
+	return &details, c.do(ctx, req, &details)
+}
+
+// SendMessage sends a new message to a conversation.
+func (c *Client) SendMessage(ctx context.Context, conversationID string, msg SendMessageRequest) (*Message, error) {
+	req := request{operation: "sendMessage", method: http.MethodPost,
+		path: pathf("/conversations/%s", conversationID), status: http.StatusOK}
+	fields := map[string]string{
+		"content":    msg.Content,
+		"attachment": msg.Attachment,
+		"replyTo":    msg.ReplyTo,
+	}
+	if msg.Forwarded {
+		fields["forwarded"] = strconv.FormatBool(msg.Forwarded)
+	}
+	if err := req.multipartBody(fields); err != nil {
+		return nil, err
+	}
+
+	var message Message
+	return &message, c.do(ctx, req, &message)
+}
+
+// Scopes for DeleteMessage.
+const (
+	// DeleteForMe hides the message only from the authenticated user
+	DeleteForMe = "me"
+
+	// DeleteForEveryone replaces the message with a tombstone for all members. Only the sender can use it
+	DeleteForEveryone = "everyone"
+)
+
+// SearchMessages returns the messages matching the query in the conversations of the authenticated user, newest
+// first.
+func (c *Client) SearchMessages(ctx context.Context, search SearchMessagesRequest) ([]MessageSearchResult, error) {
+	query := url.Values{"q": {search.Query}}
+	if search.ConversationID != "" {
+		query.Set("conversationId", search.ConversationID)
+	}
+	if search.SenderID != "" {
+		query.Set("senderId", search.SenderID)
+	}
+	if !search.From.IsZero() {
+		query.Set("from", search.From.Format(time.RFC3339))
+	}
+	if !search.To.IsZero() {
+		query.Set("to", search.To.Format(time.RFC3339))
+	}
+	req := request{operation: "searchMessages", method: http.MethodGet, path: "/messages/search",
+		status: http.StatusOK, query: query}
+
+	var results []MessageSearchResult
+	return results, c.do(ctx, req, &results)
+}
+
+// DeleteMessage deletes a message for the authenticated user (DeleteForMe) or for all members of the conversation
+// (DeleteForEveryone). In the latter case the message is replaced by a tombstone (see Message.Deleted). An empty scope
+// uses the server default (DeleteForEveryone).
+func (c *Client) DeleteMessage(ctx context.Context, messageID string, scope string) error {
+	req := request{operation: "deleteMessage", method: http.MethodDelete, path: pathf("/messages/%s", messageID),
+		status: http.StatusNoContent}
+	if scope != "" {
+		req.query = url.Values{"scope": {scope}}
+	}
+	return c.do(ctx, req, nil)
+}
+
+// EditMessage changes the content of a message sent by the authenticated user. It returns ErrForbidden if the user is
+// not the sender, or if the edit window has expired.
+func (c *Client) EditMessage(ctx context.Context, messageID string, content string) (*Message, error) {
+	req := request{operation: "editMessage", method: http.MethodPatch, path: pathf("/messages/%s", messageID),
+		status: http.StatusOK}
+	if err := req.jsonBody(map[string]string{"content": content}); err != nil {
+		return nil, err
+	}
+
+	var message Message
+	return &message, c.do(ctx, req, &message)
+}
+
+// GetMessageHistory returns the previous versions of the content of a message, from oldest to newest.
+func (c *Client) GetMessageHistory(ctx context.Context, messageID string) ([]MessageRevision, error) {
+	req := request{operation: "getMessageHistory", method: http.MethodGet,
+		path: pathf("/messages/%s/history", messageID), status: http.StatusOK}
+
+	var revisions []MessageRevision
+	return revisions, c.do(ctx, req, &revisions)
+}
+
+// ForwardMessage sends a copy of a message to the given conversations.
+func (c *Client) ForwardMessage(ctx context.Context, messageID string, conversationIDs []string) (*Message, error) {
+	req := request{operation: "forwardMessage", method: http.MethodPost,
+		path: pathf("/messages/%s/forward", messageID), status: http.StatusOK}
+	if err := req.jsonBody(map[string][]string{"conversationIds": conversationIDs}); err != nil {
+		return nil, err
+	}
+
+	var message Message
+	return &message, c.do(ctx, req, &message)
+}
+
+// CommentMessage adds an emoji reaction to a message.
+func (c *Client) CommentMessage(ctx context.Context, messageID string, emoji string) error {
+	req := request{operation: "commentMessage", method: http.MethodPost,
+		path: pathf("/messages/%s/reactions", messageID), status: http.StatusNoContent}
+	if err := req.jsonBody(map[string]string{"emoji": emoji}); err != nil {
+		return err
+	}
+	return c.do(ctx, req, nil)
+}
+
+// UncommentMessage removes the reaction of the authenticated user from a message.
+func (c *Client) UncommentMessage(ctx context.Context, messageID string) error {
+	req := request{operation: "uncommentMessage", method: http.MethodDelete,
+		path: pathf("/messages/%s/reactions", messageID), status: http.StatusNoContent}
+	return c.do(ctx, req, nil)
+}
+
+// CreateGroup creates a new group.
+func (c *Client) CreateGroup(ctx context.Context, group CreateGroupRequest) (*Group, error) {
+	members, err := json.Marshal(group.Members)
+	if err != nil {
+		return nil, fmt.Errorf("createGroup: encoding members: %w", err)
+	}
+
+	req := request{operation: "createGroup", method: http.MethodPost, path: "/groups", status: http.StatusCreated}
+	if err := req.multipartBody(map[string]string{
+		"name":        group.Name,
+		"membersJson": string(members),
+		"image":       group.Image,
+	}); err != nil {
+		return nil, err
+	}
+
+	var created Group
+	return &created, c.do(ctx, req, &created)
+}
+
+// SetGroupName changes the name of a group.
+func (c *Client) SetGroupName(ctx context.Context, groupID string, name string) (*Group, error) {
+	req := request{operation: "setGroupName", method: http.MethodPut, path: pathf("/groups/%s/name", groupID),
+		status: http.StatusOK}
+	if err := req.jsonBody(map[string]string{"name": name}); err != nil {
+		return nil, err
+	}
+
+	var group Group
+	return &group, c.do(ctx, req, &group)
+}
+
+// SetGroupPhoto changes the photo of a group. `contentType` is PhotoPNG or PhotoJPEG, and `photo` is the Base64
+// image.
+func (c *Client) SetGroupPhoto(ctx context.Context, groupID string, contentType string, photo string) (*Group, error) {
+	req := request{operation: "setGroupPhoto", method: http.MethodPut, path: pathf("/groups/%s/photo", groupID),
+		status: http.StatusOK, body: strings.NewReader(photo), contentType: contentType}
+
+	var group Group
+	return &group, c.do(ctx, req, &group)
+}
+
+// AddToGroup adds users to a group.
+func (c *Client) AddToGroup(ctx context.Context, groupID string, userIDs []string) error {
+	req := request{operation: "addToGroup", method: http.MethodPost, path: pathf("/groups/%s/members", groupID),
+		status: http.StatusOK}
+	if err := req.jsonBody(map[string][]string{"userIds": userIDs}); err != nil {
+		return err
+	}
+	return c.do(ctx, req, nil)
+}
+
+// LeaveGroup removes the user `userID` from a group. Removing another user requires the RemoveMembers permission.
+func (c *Client) LeaveGroup(ctx context.Context, groupID string, userID string) error {
+	req := request{operation: "leaveGroup", method: http.MethodDelete,
+		path: pathf("/groups/%s/members/%s", groupID, userID), status: http.StatusNoContent}
+	return c.do(ctx, req, nil)
+}
+
+// SetGroupMemberRole changes the role of the member `userID` of a group. Setting RoleOwner transfers the ownership
+// from the authenticated user, who becomes an admin.
+func (c *Client) SetGroupMemberRole(ctx context.Context, groupID string, userID string, role string) (*Group, error) {
+	req := request{operation: "setGroupMemberRole", method: http.MethodPut,
+		path: pathf("/groups/%s/members/%s/role", groupID, userID), status: http.StatusOK}
+	if err := req.jsonBody(map[string]string{"role": role}); err != nil {
+		return nil, err
+	}
+
+	var group Group
+	return &group, c.do(ctx, req, &group)
+}
+
+// SetGroupPermissions changes which members can manage a group.
+func (c *Client) SetGroupPermissions(ctx context.Context, groupID string, perms GroupPermissions) (*Group, error) {
+	req := request{operation: "setGroupPermissions", method: http.MethodPut,
+		path: pathf("/groups/%s/permissions", groupID), status: http.StatusOK}
+	if err := req.jsonBody(perms); err != nil {
+		return nil, err
+	}
+
+	var group Group
+	return &group, c.do(ctx, req, &group)
+}
+
+// CreateGroupInvite creates an invite to join a group.
+func (c *Client) CreateGroupInvite(ctx context.Context, groupID string,
+	opts CreateGroupInviteRequest) (*GroupInvite, error) {
+	req := request{operation: "createGroupInvite", method: http.MethodPost, path: pathf("/groups/%s/invites", groupID),
+		status: http.StatusCreated}
+	if err := req.jsonBody(opts); err != nil {
+		return nil, err
+	}
+
+	var created GroupInvite
+	return &created, c.do(ctx, req, &created)
+}
+
+// ListGroupInvites returns the invites of a group that are not revoked, expired or used up.
+func (c *Client) ListGroupInvites(ctx context.Context, groupID string) ([]GroupInvite, error) {
+	req := request{operation: "listGroupInvites", method: http.MethodGet, path: pathf("/groups/%s/invites", groupID),
+		status: http.StatusOK}
+
+	var invites []GroupInvite
+	return invites, c.do(ctx, req, &invites)
+}
+
+// RevokeGroupInvite revokes an invite, so it cannot be used anymore.
+func (c *Client) RevokeGroupInvite(ctx context.Context, groupID string, token string) error {
+	req := request{operation: "revokeGroupInvite", method: http.MethodDelete,
+		path: pathf("/groups/%s/invites/%s", groupID, token), status: http.StatusNoContent}
+	return c.do(ctx, req, nil)
+}
+
+// JoinGroupByInvite adds the authenticated user to the group of the invite. It returns ErrGone if the invite is
+// expired or used up, and ErrConflict if the user is already a member.
+func (c *Client) JoinGroupByInvite(ctx context.Context, token string) (*Group, error) {
+	req := request{operation: "joinGroupByInvite", method: http.MethodPost, path: pathf("/invites/%s/join", token),
+		status: http.StatusOK}
+
+	var group Group
+	return &group, c.do(ctx, req, &group)
+}
@@ -0,0 +1,216 @@
+package client
+
+import "time"
+
+// User is a user with basic profile information.
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Photo string `json:"photo"`
+}
+
+// Reaction is an emoji reaction to a message.
+type Reaction struct {
+	Emoji  string `json:"emoji"`
+	UserID string `json:"userId"`
+}
+
+// Message is a single message in a conversation.
+type Message struct {
+	ID          string     `json:"id"`
+	State       string     `json:"state"`
+	SentAt      time.Time  `json:"sentAt"`
+	SenderID    string     `json:"senderId"`
+	SenderName  string     `json:"senderName"`
+	Content     string     `json:"content"`
+	Attachment  string     `json:"attachment"`
+	ReplyTo     *string    `json:"replyTo"`
+	IsForwarded bool       `json:"isForwarded"`
+	Reactions   []Reaction `json:"reactions"`
+
+	// Mentions are the group members mentioned in Content, in order of appearance
+	Mentions []Mention `json:"mentions"`
+
+	// Deleted is true if the message has been deleted. Content and Attachment of deleted messages are empty
+	Deleted bool `json:"deleted"`
+
+	// EditedAt is the time of the last edit, nil if the message has never been edited
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+}
+
+// Mention is a `@name` token in the content of a message that refers to a group member. Offset and Length are counted
+// in runes, and include the `@` character.
+type Mention struct {
+	UserID string `json:"userId"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// MessageRevision is a previous version of the content of an edited message.
+type MessageRevision struct {
+	Content string `json:"content"`
+
+	// EditedAt is the time when this content was replaced
+	EditedAt time.Time `json:"editedAt"`
+}
+
+// Message states.
+const (
+	MessageSent      = "sent"
+	MessageDelivered = "delivered"
+	MessageRead      = "read"
+)
+
+// Conversation is the summary of a conversation, as returned in the conversation list.
+type Conversation struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Members     []string `json:"members"`
+	Photo       string   `json:"photo,omitempty"`
+	IsGroup     bool     `json:"isGroup"`
+	LastMessage *Message `json:"lastMessage"`
+
+	// Settings are the settings of the conversation for the authenticated user
+	Settings ConversationSettings `json:"settings"`
+
+	// UnreadCount is the number of messages from other members after LastReadMessageID
+	UnreadCount int `json:"unreadCount"`
+
+	// LastReadMessageID is the last message read by the authenticated user, empty if none
+	LastReadMessageID string `json:"lastReadMessageId,omitempty"`
+
+	// MentionCount is the number of unread messages mentioning the authenticated user, counted even when muted
+	MentionCount int `json:"mentionCount"`
+}
+
+// ConversationSettings are the settings of a conversation for a single user.
+type ConversationSettings struct {
+	// MutedUntil is the end of the mute period, nil if the conversation is not muted
+	MutedUntil *time.Time `json:"mutedUntil,omitempty"`
+	Archived   bool       `json:"archived"`
+	Pinned     bool       `json:"pinned"`
+
+	// PinnedAt is the time when the conversation was pinned. It's set by the server, and ignored by
+	// SetConversationSettings
+	PinnedAt *time.Time `json:"pinnedAt,omitempty"`
+}
+
+// ConversationDetails is a conversation with all its messages, sorted from newest to oldest.
+type ConversationDetails struct {
+	Conversation
+	Messages []Message `json:"messages"`
+}
+
+// Group is a group of users.
+type Group struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Members     []string         `json:"members"`
+	Roles       []GroupMember    `json:"roles"`
+	Permissions GroupPermissions `json:"permissions"`
+	Photo       string           `json:"photo,omitempty"`
+}
+
+// GroupMember is a member of a group with its role.
+type GroupMember struct {
+	UserID string `json:"userId"`
+	Role   string `json:"role"`
+}
+
+// Roles of group members.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// GroupPermissions contains which members can manage a group. Each field is PermissionAdmins or PermissionMembers.
+type GroupPermissions struct {
+	// Rename is about changing the name and the photo of the group
+	Rename        string `json:"rename"`
+	AddMembers    string `json:"addMembers"`
+	RemoveMembers string `json:"removeMembers"`
+}
+
+// Values of GroupPermissions fields.
+const (
+	// PermissionAdmins allows the action only to the owner and the admins
+	PermissionAdmins = "admins"
+
+	// PermissionMembers allows the action to every member
+	PermissionMembers = "members"
+)
+
+// SendMessageRequest contains the fields of a new message. At least one of Content and Attachment is required.
+type SendMessageRequest struct {
+	// Content is the text of the message
+	Content string
+
+	// Attachment is an optional image, as Base64 string
+	Attachment string
+
+	// ReplyTo is the ID of the message being replied to (optional)
+	ReplyTo string
+
+	// Forwarded marks the message as forwarded
+	Forwarded bool
+}
+
+// CreateGroupRequest contains the fields of a new group.
+type CreateGroupRequest struct {
+	// Name is the name of the group
+	Name string
+
+	// Members contains the IDs of the initial members
+	Members []string
+
+	// Image is the group photo, as Base64 string
+	Image string
+}
+
+// GroupInvite is an invite to join a group.
+type GroupInvite struct {
+	Token     string    `json:"token"`
+	GroupID   string    `json:"groupId"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// ExpiresAt is nil for invites that never expire
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// MaxUses is zero for invites with unlimited uses
+	MaxUses int `json:"maxUses,omitempty"`
+
+	// Uses is the number of users that joined with the invite
+	Uses int `json:"uses"`
+}
+
+// CreateGroupInviteRequest contains the limits of a new invite. The zero value creates an invite that never expires,
+// with unlimited uses.
+type CreateGroupInviteRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	MaxUses   int        `json:"maxUses,omitempty"`
+}
+
+// MessageSearchResult is a message matching a search query.
+type MessageSearchResult struct {
+	ConversationID string  `json:"conversationId"`
+	Message        Message `json:"message"`
+
+	// Snippet is the HTML-escaped content around the matches, with matching words wrapped in <mark> and </mark>
+	Snippet string `json:"snippet"`
+}
+
+// SearchMessagesRequest contains the query and the optional filters of SearchMessages.
+type SearchMessagesRequest struct {
+	// Query contains the words to search for
+	Query string
+
+	// ConversationID and SenderID, if not empty, restrict the results to a conversation and to a sender
+	ConversationID string
+	SenderID       string
+
+	// From and To, if not zero, restrict the results to messages sent in [From, To)
+	From time.Time
+	To   time.Time
+}
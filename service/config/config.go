@@ -0,0 +1,74 @@
+/*
+Package config loads the configuration shared by the executables in `cmd/`. The configuration is a struct with `conf`
+tags (see github.com/ardanlabs/conf): values are read from environment variables (with the CFG prefix), then from
+command line flags, and finally from the YAML configuration file, which overrides everything.
+
+The DB struct contains the database settings: `webapi` and `wasactl` embed it, so they open the same database in the
+same way.
+
+Example:
+
+	var cfg struct {
+		Config struct {
+			Path string `conf:"default:/conf/config.yml"`
+		}
+		DB config.DB
+	}
+	if err := config.Load(os.Args[1:], &cfg, &cfg.Config.Path); errors.Is(err, conf.ErrHelpWanted) {
+		usage, _ := config.Usage(&cfg)
+		fmt.Println(usage)
+	}
+*/
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ardanlabs/conf"
+	"gopkg.in/yaml.v2"
+	"io"
+	"os"
+)
+
+// Prefix is the prefix of the environment variables.
+const Prefix = "CFG"
+
+// Load fills `cfg`, a pointer to a struct with `conf` tags, from the environment variables and the command line flags
+// in `args`. Then, if the file at `*path` exists, its YAML content overrides the values. `path` must point to a field
+// of `cfg`, so the configuration file can be specified only via flags or environment variables.
+//
+// If the help flag is in `args`, it returns conf.ErrHelpWanted: use Usage to print the help message.
+func Load(args []string, cfg interface{}, path *string) error {
+	if err := conf.Parse(args, Prefix, cfg); err != nil {
+		if errors.Is(err, conf.ErrHelpWanted) {
+			return err
+		}
+		return fmt.Errorf("parsing config: %w", err)
+	}
+
+	fp, err := os.Open(*path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't read the config file, while it exists: %w", err)
+	} else if err != nil {
+		return nil
+	}
+	defer func() { _ = fp.Close() }()
+
+	yamlFile, err := io.ReadAll(fp)
+	if err != nil {
+		return fmt.Errorf("can't read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(yamlFile, cfg); err != nil {
+		return fmt.Errorf("can't unmarshal config file: %w", err)
+	}
+	return nil
+}
+
+// Usage returns the help message for the flags and environment variables of `cfg`.
+func Usage(cfg interface{}) (string, error) {
+	usage, err := conf.Usage(Prefix, cfg)
+	if err != nil {
+		return "", fmt.Errorf("generating config usage: %w", err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"github.com/ardanlabs/conf"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testConfig struct {
+	Config struct {
+		Path string `conf:"default:/nonexistent/config.yml"`
+	}
+	DB DB
+}
+
+func TestLoadDefaults(t *testing.T) {
+	var cfg testConfig
+	if err := Load(nil, &cfg, &cfg.Config.Path); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Filename != "/tmp/decaf.db" || cfg.DB.JournalMode != "WAL" {
+		t.Fatalf("defaults not applied: %+v", cfg.DB)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("db:\n  filename: /yaml.db\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CFG_DB_FILENAME", "/env.db")
+	t.Setenv("CFG_DB_BUSY_TIMEOUT", "7s")
+
+	var cfg testConfig
+	args := []string{"--config-path", path, "--db-max-open-conns", "3"}
+	if err := Load(args, &cfg, &cfg.Config.Path); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Filename != "/yaml.db" {
+		t.Errorf("Filename = %q, want the YAML value", cfg.DB.Filename)
+	}
+	if cfg.DB.BusyTimeout.String() != "7s" {
+		t.Errorf("BusyTimeout = %v, want the environment value", cfg.DB.BusyTimeout)
+	}
+	if cfg.DB.MaxOpenConns != 3 {
+		t.Errorf("MaxOpenConns = %d, want the flag value", cfg.DB.MaxOpenConns)
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	var cfg testConfig
+	if err := Load([]string{"--help"}, &cfg, &cfg.Config.Path); !errors.Is(err, conf.ErrHelpWanted) {
+		t.Fatalf("Load(--help) = %v, want conf.ErrHelpWanted", err)
+	}
+	if usage, err := Usage(&cfg); err != nil || usage == "" {
+		t.Fatalf("Usage() = %q, %v", usage, err)
+	}
+}
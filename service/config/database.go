@@ -0,0 +1,29 @@
+package config
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"time"
+)
+
+// DB contains the SQLite database settings.
+type DB struct {
+	Filename     string        `conf:"default:/tmp/decaf.db"`
+	JournalMode  string        `conf:"default:WAL"`
+	Synchronous  string        `conf:"default:NORMAL"`
+	BusyTimeout  time.Duration `conf:"default:5s"`
+	ForeignKeys  bool          `conf:"default:true"`
+	MaxOpenConns int           `conf:"default:8"`
+	MaxIdleConns int           `conf:"default:4"`
+}
+
+// ConnOptions returns the SQLite connection options, for database.Open and database.OpenWriter.
+func (c DB) ConnOptions() database.ConnOptions {
+	return database.ConnOptions{
+		JournalMode:  c.JournalMode,
+		Synchronous:  c.Synchronous,
+		BusyTimeout:  c.BusyTimeout,
+		ForeignKeys:  c.ForeignKeys,
+		MaxOpenConns: c.MaxOpenConns,
+		MaxIdleConns: c.MaxIdleConns,
+	}
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupPagesPerStep is the number of pages copied by each step of an online backup. Between steps the source
+// database is unlocked, so writers are not blocked for the whole backup.
+const backupPagesPerStep = 256
+
+// Backup copies the database `db` to the file `dest` using the SQLite online backup API, while the database is in
+// use. The copy is written to a temporary file first, and then renamed to `dest`, so `dest` is never a partial backup.
+func Backup(ctx context.Context, db *sql.DB, dest string) error {
+	tmp := dest + ".tmp"
+	_ = os.Remove(tmp)
+	err := backupTo(ctx, db, tmp)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("renaming backup: %w", err)
+	}
+	return nil
+}
+
+func backupTo(ctx context.Context, db *sql.DB, dest string) error {
+	destDB, err := sql.Open("sqlite3", dest)
+	if err != nil {
+		return fmt.Errorf("opening backup file: %w", err)
+	}
+	defer func() { _ = destDB.Close() }()
+
+	srcConn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to the database: %w", err)
+	}
+	defer func() { _ = srcConn.Close() }()
+
+	destConn, err := destDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to the backup file: %w", err)
+	}
+	defer func() { _ = destConn.Close() }()
+
+	return destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			destSQLite, ok := destDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("backup destination is not a SQLite connection")
+			}
+			srcSQLite, ok := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok {
+				return errors.New("database is not a SQLite connection")
+			}
+
+			bk, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return fmt.Errorf("starting backup: %w", err)
+			}
+			for {
+				done, err := bk.Step(backupPagesPerStep)
+				if err != nil {
+					_ = bk.Finish()
+					return fmt.Errorf("copying pages: %w", err)
+				} else if done {
+					return bk.Finish()
+				}
+
+				select {
+				case <-ctx.Done():
+					_ = bk.Finish()
+					return ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+			}
+		})
+	})
+}
+
+// CheckIntegrity opens the database file `filename` read-only and runs SQLite integrity check on it. It returns an
+// error if the file is not a valid database.
+func CheckIntegrity(filename string) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+abs+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("checking integrity: %w", err)
+	} else if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+// sidecarSuffixes are the suffixes of the files SQLite keeps next to the database file. They belong to the database
+// file: the WAL file, in particular, may contain committed transactions not yet copied to the database file.
+var sidecarSuffixes = []string{"-wal", "-shm", "-journal"}
+
+// Restore replaces the database file `filename` with the backup file `backup`. The backup is checked with
+// CheckIntegrity before and after being copied. The current database (if any) is kept as `filename.pre-restore`,
+// together with its WAL and journal files, so it can be opened as it was; a previous `.pre-restore` copy is replaced.
+// If Restore fails, the current database is left in place.
+//
+// The database must not be in use: stop every process using it before calling Restore.
+func Restore(backup string, filename string) error {
+	if err := CheckIntegrity(backup); err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	tmp := filename + ".restore-tmp"
+	if err := copyFile(backup, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("copying backup: %w", err)
+	}
+	if err := CheckIntegrity(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("invalid copy of the backup: %w", err)
+	}
+
+	// Move the current database and its sidecar files aside. If anything fails, the files already moved are put
+	// back, so `filename` is never left without its WAL
+	safety := filename + ".pre-restore"
+	for _, suffix := range append([]string{""}, sidecarSuffixes...) {
+		if err := os.Remove(safety + suffix); err != nil && !os.IsNotExist(err) {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("removing the previous %s: %w", safety+suffix, err)
+		}
+	}
+	var moved []string
+	undo := func() {
+		for i := len(moved) - 1; i >= 0; i-- {
+			_ = os.Rename(safety+moved[i], filename+moved[i])
+		}
+		_ = os.Remove(tmp)
+	}
+	for _, suffix := range append([]string{""}, sidecarSuffixes...) {
+		err := os.Rename(filename+suffix, safety+suffix)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			undo()
+			return fmt.Errorf("moving %s: %w", filename+suffix, err)
+		}
+		moved = append(moved, suffix)
+	}
+
+	if err := os.Rename(tmp, filename); err != nil {
+		undo()
+		return fmt.Errorf("moving the restored database: %w", err)
+	}
+	return nil
+}
+
+// copyFile copies src to dest, syncing dest to disk.
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
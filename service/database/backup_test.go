@@ -0,0 +1,179 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	_ "github.com/mattn/go-sqlite3"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openWAL opens `filename` in WAL mode with automatic checkpoints disabled, so committed rows stay in the WAL file.
+func openWAL(t *testing.T, filename string) *sql.DB {
+	t.Helper()
+	db, err := database.Open(filename, database.ConnOptions{JournalMode: "WAL", MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("opening %s: %v", filename, err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if _, err := db.Exec("PRAGMA wal_autocheckpoint = 0"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func exec(t *testing.T, db *sql.DB, query string) {
+	t.Helper()
+	if _, err := db.Exec(query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// countRows returns the number of rows in table `t` of the database file `filename`.
+func countRows(t *testing.T, filename string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM t").Scan(&n); err != nil {
+		t.Fatalf("counting rows in %s: %v", filename, err)
+	}
+	return n
+}
+
+func copyTestFile(t *testing.T, src string, dest string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	db := openWAL(t, filepath.Join(dir, "live.db"))
+	exec(t, db, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, db, "INSERT INTO t (id) VALUES (1), (2)")
+
+	dest := filepath.Join(dir, "backup.db")
+	if err := database.Backup(context.Background(), db, dest); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := database.CheckIntegrity(dest); err != nil {
+		t.Fatalf("CheckIntegrity on the backup: %v", err)
+	}
+	// Rows still in the WAL of the source are part of the backup
+	if n := countRows(t, dest); n != 2 {
+		t.Fatalf("backup has %d rows, want 2", n)
+	}
+	if _, err := os.Stat(dest + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary backup file left behind (stat error: %v)", err)
+	}
+}
+
+func TestBackupCanceled(t *testing.T) {
+	dir := t.TempDir()
+	db := openWAL(t, filepath.Join(dir, "live.db"))
+	exec(t, db, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dest := filepath.Join(dir, "backup.db")
+	if err := database.Backup(ctx, db, dest); err == nil {
+		t.Fatal("Backup with a canceled context succeeded")
+	}
+	for _, name := range []string{dest, dest + ".tmp"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s exists after a failed backup (stat error: %v)", name, err)
+		}
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := database.CheckIntegrity(filepath.Join(dir, "missing.db")); err == nil {
+		t.Fatal("CheckIntegrity on a missing file succeeded")
+	}
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("this is not a SQLite database, even if it is long enough to look like one"),
+		0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.CheckIntegrity(garbage); err == nil {
+		t.Fatal("CheckIntegrity on an invalid file succeeded")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+
+	// The backup contains one row
+	src := openWAL(t, filepath.Join(dir, "src.db"))
+	exec(t, src, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, src, "INSERT INTO t (id) VALUES (1)")
+	backup := filepath.Join(dir, "backup.db")
+	if err := database.Backup(context.Background(), src, backup); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	// The current database has three rows, two of them only in the WAL file, as after an unclean shutdown
+	live := filepath.Join(dir, "live.db")
+	db := openWAL(t, live)
+	exec(t, db, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, db, "INSERT INTO t (id) VALUES (1)")
+	exec(t, db, "PRAGMA wal_checkpoint(TRUNCATE)")
+	exec(t, db, "INSERT INTO t (id) VALUES (2), (3)")
+	current := filepath.Join(dir, "current.db")
+	copyTestFile(t, live, current)
+	copyTestFile(t, live+"-wal", current+"-wal")
+
+	if err := database.Restore(backup, current); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if n := countRows(t, current); n != 1 {
+		t.Fatalf("restored database has %d rows, want 1", n)
+	}
+	// The previous database is kept with its WAL, so no committed row is lost
+	if n := countRows(t, current+".pre-restore"); n != 3 {
+		t.Fatalf("pre-restore copy has %d rows, want 3", n)
+	}
+	if _, err := os.Stat(current + ".restore-tmp"); !os.IsNotExist(err) {
+		t.Fatalf("temporary restore file left behind (stat error: %v)", err)
+	}
+}
+
+func TestRestoreInvalidBackup(t *testing.T) {
+	dir := t.TempDir()
+	current := filepath.Join(dir, "current.db")
+	db := openWAL(t, current)
+	exec(t, db, "CREATE TABLE t (id INTEGER PRIMARY KEY)")
+	exec(t, db, "INSERT INTO t (id) VALUES (1)")
+
+	backup := filepath.Join(dir, "backup.db")
+	if err := os.WriteFile(backup, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Restore(backup, current); err == nil {
+		t.Fatal("Restore of an invalid backup succeeded")
+	}
+
+	// The current database is untouched
+	if n := countRows(t, current); n != 1 {
+		t.Fatalf("database has %d rows after a failed restore, want 1", n)
+	}
+	for _, name := range []string{current + ".pre-restore", current + ".restore-tmp"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s exists after a failed restore (stat error: %v)", name, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+func (db *appdbimpl) BlockUser(ctx context.Context, blockerID string, blockedID string) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		for _, id := range []string{blockerID, blockedID} {
+			if _, err := tdb.GetUser(ctx, id); err != nil {
+				return fmt.Errorf("user %s: %w", id, err)
+			}
+		}
+
+		_, err := tdb.w.ExecContext(ctx, `INSERT INTO blocks (blocker_id, blocked_id, created_at) VALUES (?, ?, ?)
+			ON CONFLICT (blocker_id, blocked_id) DO NOTHING`, blockerID, blockedID, toUnixMilli(tdb.now()))
+		return translateError(err)
+	})
+}
+
+func (db *appdbimpl) UnblockUser(ctx context.Context, blockerID string, blockedID string) error {
+	_, err := db.w.ExecContext(ctx, `DELETE FROM blocks WHERE blocker_id = ? AND blocked_id = ?`, blockerID, blockedID)
+	return err
+}
+
+func (db *appdbimpl) IsBlocked(ctx context.Context, blockerID string, blockedID string) (bool, error) {
+	var blocked bool
+	err := db.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = ? AND blocked_id = ?)`,
+		blockerID, blockedID).Scan(&blocked)
+	return blocked, err
+}
+
+func (db *appdbimpl) ListBlockedUsers(ctx context.Context, blockerID string) ([]User, error) {
+	rows, err := db.c.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+		WHERE id IN (SELECT blocked_id FROM blocks WHERE blocker_id = ?) ORDER BY name`, blockerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var users = []User{}
+	err = eachRow(rows, func(row scanner) error {
+		u, err := scanUser(row)
+		if err != nil {
+			return err
+		}
+		users = append(users, u)
+		return nil
+	})
+	return users, err
+}
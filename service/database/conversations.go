@@ -0,0 +1,261 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Conversation is a one-to-one conversation or a group.
+type Conversation struct {
+	ID      int64
+	IsGroup bool
+
+	// Name and Photo are empty for one-to-one conversations
+	Name  string
+	Photo string
+
+	CreatedAt time.Time
+
+	// Permissions only apply to groups: new groups allow every member to rename the group and add members, and only
+	// admins to remove members
+	Permissions GroupPermissions
+
+	// Members are sorted by join time, then by user ID
+	Members []Member
+}
+
+// Roles of the members of a group. Each group has one owner while it has members; the members of one-to-one
+// conversations have RoleMember.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Values of the group permissions: PermissionAdmins allows the owner and the admins, PermissionMembers allows every
+// member.
+const (
+	PermissionAdmins  = "admins"
+	PermissionMembers = "members"
+)
+
+// GroupPermissions are the roles allowed to manage a group.
+type GroupPermissions struct {
+	// Rename allows changing the name and the photo
+	Rename        string
+	AddMembers    string
+	RemoveMembers string
+}
+
+// Member is a user in a conversation.
+type Member struct {
+	UserID string
+
+	// Name is the current name of the user
+	Name string
+
+	JoinedAt time.Time
+	Role     string
+
+	// LastDeliveredID and LastReadID are the newest message delivered to and read by the member, zero if none
+	LastDeliveredID int64
+	LastReadID      int64
+
+	Settings ConversationSettings
+}
+
+// ConversationSettings are the settings of a conversation for one of its members.
+type ConversationSettings struct {
+	// MutedUntil is the end of the mute period, zero if the conversation has never been muted
+	MutedUntil time.Time
+
+	Archived bool
+
+	// PinnedAt is the time when the conversation was pinned, zero if it's not pinned
+	PinnedAt time.Time
+}
+
+// Muted returns true if the conversation is muted at time `now`.
+func (s ConversationSettings) Muted(now time.Time) bool {
+	return now.Before(s.MutedUntil)
+}
+
+// directKey returns the value of the unique `direct_key` column of the conversation between two users, which is the
+// same regardless of the order of the users.
+func directKey(userID string, otherID string) string {
+	if otherID < userID {
+		userID, otherID = otherID, userID
+	}
+	return userID + ":" + otherID
+}
+
+func (db *appdbimpl) CreateDirectConversation(ctx context.Context, userID string, otherID string) (int64, error) {
+	var id int64
+	err := db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		for _, uid := range []string{userID, otherID} {
+			if _, err := tdb.GetUser(ctx, uid); err != nil {
+				return fmt.Errorf("user %s: %w", uid, err)
+			}
+		}
+
+		now := toUnixMilli(tdb.now())
+		res, err := tdb.w.ExecContext(ctx, `INSERT INTO conversations (is_group, name, photo, direct_key, created_at)
+			VALUES (0, '', '', ?, ?)`, directKey(userID, otherID), now)
+		if err != nil {
+			return translateError(err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		for _, uid := range []string{userID, otherID} {
+			if err := tdb.insertMember(ctx, id, uid, RoleMember, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+// insertMember adds a member to a conversation. Messages sent before joining count as delivered and read.
+func (db *appdbimpl) insertMember(ctx context.Context, conversationID int64, userID string, role string,
+	joinedAt int64) error {
+	_, err := db.w.ExecContext(ctx, `INSERT INTO members (conversation_id, user_id, role, joined_at, last_delivered_id,
+		last_read_id) SELECT ?, ?, ?, ?, latest, latest FROM (SELECT COALESCE(MAX(id), 0) AS latest FROM messages
+		WHERE conversation_id = ?)`, conversationID, userID, role, joinedAt, conversationID)
+	return translateError(err)
+}
+
+func (db *appdbimpl) FindDirectConversation(ctx context.Context, userID string, otherID string) (int64, error) {
+	var id int64
+	err := db.c.QueryRowContext(ctx, `SELECT id FROM conversations WHERE direct_key = ?`,
+		directKey(userID, otherID)).Scan(&id)
+	return id, translateError(err)
+}
+
+func (db *appdbimpl) GetConversation(ctx context.Context, id int64) (Conversation, error) {
+	var c Conversation
+	var createdAt int64
+	err := db.c.QueryRowContext(ctx, `SELECT id, is_group, name, photo, created_at, perm_rename, perm_add_members,
+		perm_remove_members FROM conversations WHERE id = ?`, id).Scan(&c.ID, &c.IsGroup, &c.Name, &c.Photo, &createdAt,
+		&c.Permissions.Rename, &c.Permissions.AddMembers, &c.Permissions.RemoveMembers)
+	if err != nil {
+		return Conversation{}, translateError(err)
+	}
+	c.CreatedAt = fromUnixMilli(createdAt)
+
+	rows, err := db.c.QueryContext(ctx, `SELECT m.user_id, u.name, m.joined_at, m.role, m.last_delivered_id,
+		m.last_read_id, s.muted_until, COALESCE(s.archived, 0), s.pinned_at FROM members m JOIN users u ON u.id = m.user_id
+		LEFT JOIN conversation_settings s ON s.conversation_id = m.conversation_id AND s.user_id = m.user_id
+		WHERE m.conversation_id = ? ORDER BY m.joined_at, m.user_id`, id)
+	if err != nil {
+		return Conversation{}, err
+	}
+	c.Members = []Member{}
+	err = eachRow(rows, func(row scanner) error {
+		var m Member
+		var joinedAt int64
+		var mutedUntil, pinnedAt sql.NullInt64
+		if err := row.Scan(&m.UserID, &m.Name, &joinedAt, &m.Role, &m.LastDeliveredID, &m.LastReadID, &mutedUntil,
+			&m.Settings.Archived, &pinnedAt); err != nil {
+			return err
+		}
+		m.JoinedAt = fromUnixMilli(joinedAt)
+		if mutedUntil.Valid {
+			m.Settings.MutedUntil = fromUnixMilli(mutedUntil.Int64)
+		}
+		if pinnedAt.Valid {
+			m.Settings.PinnedAt = fromUnixMilli(pinnedAt.Int64)
+		}
+		c.Members = append(c.Members, m)
+		return nil
+	})
+	return c, err
+}
+
+func (db *appdbimpl) ListUserConversations(ctx context.Context, userID string) ([]int64, error) {
+	rows, err := db.c.QueryContext(ctx, `SELECT conversation_id FROM members WHERE user_id = ?
+		ORDER BY conversation_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids = []int64{}
+	err = eachRow(rows, func(row scanner) error {
+		var id int64
+		if err := row.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+func (db *appdbimpl) ListConversations(ctx context.Context, limit int) ([]int64, error) {
+	rows, err := db.c.QueryContext(ctx, `SELECT id FROM conversations ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids = []int64{}
+	err = eachRow(rows, func(row scanner) error {
+		var id int64
+		if err := row.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+func (db *appdbimpl) MarkDelivered(ctx context.Context, conversationID int64, userID string, messageID int64) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE members SET last_delivered_id = MAX(last_delivered_id, ?)
+		WHERE conversation_id = ? AND user_id = ?`, messageID, conversationID, userID)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) MarkRead(ctx context.Context, conversationID int64, userID string, messageID int64) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE members SET last_read_id = MAX(last_read_id, ?),
+		last_delivered_id = MAX(last_delivered_id, ?) WHERE conversation_id = ? AND user_id = ?`,
+		messageID, messageID, conversationID, userID)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SetConversationSettings(ctx context.Context, conversationID int64, userID string,
+	s ConversationSettings) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		var exists bool
+		err := tdb.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM members WHERE conversation_id = ?
+			AND user_id = ?)`, conversationID, userID).Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return ErrNotFound
+		}
+
+		var mutedUntil = sql.NullInt64{Int64: toUnixMilli(s.MutedUntil), Valid: !s.MutedUntil.IsZero()}
+		var pinnedAt = sql.NullInt64{Int64: toUnixMilli(s.PinnedAt), Valid: !s.PinnedAt.IsZero()}
+		_, err = tdb.w.ExecContext(ctx, `INSERT INTO conversation_settings (conversation_id, user_id, muted_until,
+			archived, pinned_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT (conversation_id, user_id) DO UPDATE SET
+			muted_until = excluded.muted_until, archived = excluded.archived, pinned_at = excluded.pinned_at`,
+			conversationID, userID, mutedUntil, s.Archived, pinnedAt)
+		return translateError(err)
+	})
+}
+
+func (db *appdbimpl) UnarchiveConversation(ctx context.Context, conversationID int64, mentionedIDs []string) error {
+	var args = []interface{}{conversationID, toUnixMilli(db.now())}
+	for _, id := range mentionedIDs {
+		args = append(args, id)
+	}
+	// `user_id IN ()` is valid in SQLite, and matches nothing
+	_, err := db.w.ExecContext(ctx, `UPDATE conversation_settings SET archived = 0 WHERE conversation_id = ?
+		AND archived = 1 AND (muted_until IS NULL OR muted_until <= ? OR user_id IN (`+
+		strings.TrimSuffix(strings.Repeat("?, ", len(mentionedIDs)), ", ")+`))`, args...)
+	return translateError(err)
+}
@@ -27,50 +27,302 @@ This is an example on how to migrate the DB and connect to it:
 	}()
 
 Then you can initialize the AppDatabase and pass it to the api package.
+
+Operations made of more than one query should use WithTx, so that they are applied atomically:
+
+	err := db.WithTx(r.Context(), func(tx database.AppDatabase) error {
+		if _, err := tx.GetName(r.Context()); err != nil {
+			return err
+		}
+		return tx.SetName(r.Context(), "new name")
+	})
+
+To tune SQLite (journal mode, busy timeout, foreign keys, pool size), open the database with Open and OpenWriter
+instead of sql.Open, and initialize the AppDatabase with NewWithWriter.
 */
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"time"
 )
 
-// AppDatabase is the high level interface for the DB
+// AppDatabase is the high level interface for the DB. Every method takes a context, usually the one of the HTTP request
+// (r.Context()): when the context is canceled (client disconnected, shutdown timeout), the query is interrupted and
+// the context error is returned.
+// Methods never return database/sql or SQLite errors for expected outcomes: see ErrNotFound, ErrConflict and
+// ErrInvalid.
 type AppDatabase interface {
-	GetName() (string, error)
-	SetName(name string) error
+	GetName(ctx context.Context) (string, error)
+	SetName(ctx context.Context, name string) error
+
+	// CreateUser creates the user `id` with the given name and Base64 photo. It returns ErrConflict if the ID or the
+	// name are already in use.
+	CreateUser(ctx context.Context, id string, name string, photo string) (User, error)
+
+	// GetUser returns the user `id`, or ErrNotFound.
+	GetUser(ctx context.Context, id string) (User, error)
+
+	// GetUserByName returns the user named `name`, or ErrNotFound. Names are case-sensitive.
+	GetUserByName(ctx context.Context, name string) (User, error)
+
+	// SetUserName changes the name of the user `id`. It returns ErrNotFound if the user does not exist, and
+	// ErrConflict if the name is used by another user.
+	SetUserName(ctx context.Context, id string, name string) error
+
+	// SetUserPhoto changes the photo of the user `id`, or returns ErrNotFound.
+	SetUserPhoto(ctx context.Context, id string, photo string) error
+
+	// SetUserBanned bans or unbans the user `id`, or returns ErrNotFound. Banning does not end the session of the user:
+	// use DeleteSession.
+	SetUserBanned(ctx context.Context, id string, banned bool) error
+
+	// SearchUsers returns up to `limit` users whose name contains `query`, ignoring case, sorted by name. Users
+	// blocked by `viewerID` are excluded; an empty `viewerID` excludes nobody.
+	SearchUsers(ctx context.Context, query string, viewerID string, limit int) ([]User, error)
+
+	// BlockUser makes `blockerID` block `blockedID`. It returns ErrNotFound if a user does not exist. Blocking a user
+	// twice keeps the time of the first block.
+	BlockUser(ctx context.Context, blockerID string, blockedID string) error
+
+	// UnblockUser removes the block of `blockerID` on `blockedID`, if any.
+	UnblockUser(ctx context.Context, blockerID string, blockedID string) error
+
+	// IsBlocked reports whether `blockerID` blocked `blockedID`.
+	IsBlocked(ctx context.Context, blockerID string, blockedID string) (bool, error)
+
+	// ListBlockedUsers returns the users blocked by `blockerID`, sorted by name.
+	ListBlockedUsers(ctx context.Context, blockerID string) ([]User, error)
+
+	// CreateSession starts a new session for the user, replacing the previous one (if any). It returns ErrNotFound if
+	// the user does not exist.
+	CreateSession(ctx context.Context, userID string) (Session, error)
+
+	// GetSession returns the session of the user, or ErrNotFound if the user has no session.
+	GetSession(ctx context.Context, userID string) (Session, error)
+
+	// DeleteSession ends the session of the user, or returns ErrNotFound if the user has no session.
+	DeleteSession(ctx context.Context, userID string) error
+
+	// CreateDirectConversation creates the one-to-one conversation between two users, returning its ID. It returns
+	// ErrNotFound if a user does not exist, and ErrConflict if the conversation already exists.
+	CreateDirectConversation(ctx context.Context, userID string, otherID string) (int64, error)
+
+	// FindDirectConversation returns the ID of the one-to-one conversation between two users, or ErrNotFound.
+	FindDirectConversation(ctx context.Context, userID string, otherID string) (int64, error)
+
+	// GetConversation returns the conversation `id` with its members, or ErrNotFound.
+	GetConversation(ctx context.Context, id int64) (Conversation, error)
+
+	// ListUserConversations returns the IDs of the conversations of the user, in ascending order.
+	ListUserConversations(ctx context.Context, userID string) ([]int64, error)
+
+	// ListConversations returns the IDs of up to `limit` conversations, in ascending order.
+	ListConversations(ctx context.Context, limit int) ([]int64, error)
+
+	// MarkDelivered moves the delivered marker of the member forward to `messageID`; it never moves backwards. It
+	// returns ErrNotFound if the user is not a member of the conversation.
+	MarkDelivered(ctx context.Context, conversationID int64, userID string, messageID int64) error
+
+	// MarkRead moves both the read and the delivered markers of the member forward to `messageID`, like
+	// MarkDelivered.
+	MarkRead(ctx context.Context, conversationID int64, userID string, messageID int64) error
+
+	// SetConversationSettings replaces the settings of the conversation for one of its members. It returns ErrNotFound
+	// if the user is not a member.
+	SetConversationSettings(ctx context.Context, conversationID int64, userID string, s ConversationSettings) error
+
+	// UnarchiveConversation unarchives the conversation for the members that archived it, except those who muted it
+	// until a time after now. Mentions override the mute: the users in `mentionedIDs` are always unarchived.
+	UnarchiveConversation(ctx context.Context, conversationID int64, mentionedIDs []string) error
+
+	// CreateGroup creates a group with the given members, returning its ID. The first member is the owner, the others
+	// have RoleMember. It returns ErrNotFound if a user does not exist.
+	CreateGroup(ctx context.Context, name string, photo string, memberIDs []string) (int64, error)
+
+	// SetGroupName changes the name of a group, or returns ErrNotFound.
+	SetGroupName(ctx context.Context, id int64, name string) error
+
+	// SetGroupPhoto changes the photo of a group, or returns ErrNotFound.
+	SetGroupPhoto(ctx context.Context, id int64, photo string) error
+
+	// SetGroupPermissions changes the permissions of a group, or returns ErrNotFound. It returns ErrInvalid if a
+	// permission is not PermissionAdmins or PermissionMembers.
+	SetGroupPermissions(ctx context.Context, id int64, perms GroupPermissions) error
+
+	// SetMemberRole changes the role of a member of a conversation, or returns ErrNotFound if the user is not a member.
+	// It returns ErrInvalid if the role is unknown. The caller keeps a single owner per group.
+	SetMemberRole(ctx context.Context, conversationID int64, userID string, role string) error
+
+	// AddMember adds a user to a group with RoleMember. It returns ErrNotFound if the group or the user do not exist, and ErrConflict
+	// if the user is already a member.
+	AddMember(ctx context.Context, conversationID int64, userID string) error
+
+	// RemoveMember removes a user from a conversation with their settings, or returns ErrNotFound if the user is not a
+	// member.
+	RemoveMember(ctx context.Context, conversationID int64, userID string) error
+
+	// CreateInvite creates an invite to join a group, and returns it. It returns ErrNotFound if the group or the
+	// creator do not exist, and ErrConflict if the token is already used by another invite.
+	CreateInvite(ctx context.Context, ni NewInvite) (Invite, error)
 
-	Ping() error
+	// GetInvite returns the invite with the given token, or ErrNotFound. Expired and used up invites are returned too.
+	GetInvite(ctx context.Context, token string) (Invite, error)
+
+	// ListInvites returns all invites of a group (including expired and used up ones), oldest first.
+	ListInvites(ctx context.Context, conversationID int64) ([]Invite, error)
+
+	// UseInvite counts a user who joined with the invite. It returns ErrNotFound if the invite does not exist, and
+	// ErrConflict if it's used up. Expiration is not checked.
+	UseInvite(ctx context.Context, token string) error
+
+	// DeleteInvite deletes an invite of a group, or returns ErrNotFound.
+	DeleteInvite(ctx context.Context, conversationID int64, token string) error
+
+	// DeleteConversation deletes a conversation with its members, messages and invites, or returns ErrNotFound.
+	DeleteConversation(ctx context.Context, id int64) error
+
+	// CreateMessage adds a message to a conversation, and returns it. It returns ErrNotFound if the conversation or the
+	// sender do not exist. Membership is not checked.
+	CreateMessage(ctx context.Context, m NewMessage) (Message, error)
+
+	// GetMessage returns the message `id` with its reactions, or ErrNotFound.
+	GetMessage(ctx context.Context, id int64) (Message, error)
+
+	// ListMessages returns up to `limit` messages of the conversation with their reactions, newest first. The messages
+	// hidden by `userID` (see HideMessage) are excluded; pass an empty user ID to list all messages.
+	ListMessages(ctx context.Context, conversationID int64, userID string, limit int) ([]Message, error)
+
+	// SearchMessages returns up to `s.Limit` messages matching the search, with their reactions, newest first.
+	// Tombstones are never returned. It returns ErrInvalid if there are no words to search. Without the full-text index
+	// (see FullTextSearch), only the content of the newest messages of the user is searched.
+	SearchMessages(ctx context.Context, s MessageSearch) ([]Message, error)
+
+	// CountUnread returns the number of messages of the conversation newer than the read marker of the user, excluding
+	// the messages sent by the user, tombstones and the messages hidden by the user. It returns zero if the user is not
+	// a member.
+	CountUnread(ctx context.Context, conversationID int64, userID string) (int, error)
+
+	// CountMentions returns the number of the messages counted by CountUnread that mention the user.
+	CountMentions(ctx context.Context, conversationID int64, userID string) (int, error)
+
+	// EditMessage replaces the content and the mentions of a message, saving the previous content as a revision, and
+	// returns the updated message. It returns ErrNotFound if the message does not exist.
+	EditMessage(ctx context.Context, id int64, content string, mentions []Mention) (Message, error)
+
+	// ListRevisions returns the previous contents of the message, oldest first. The list is empty if the message has
+	// never been edited or does not exist.
+	ListRevisions(ctx context.Context, messageID int64) ([]Revision, error)
+
+	// DeleteMessage replaces a message with a tombstone: content and attachment are emptied, reactions, revisions and
+	// mentions are removed, and DeletedAt is set. Sender, time and replies are kept. Deleting a tombstone has no
+	// effect. It returns ErrNotFound if the message does not exist.
+	DeleteMessage(ctx context.Context, id int64) error
+
+	// HideMessage hides a message from the messages of the user listed by ListMessages. Hiding a message twice has no
+	// effect. It returns ErrNotFound if the message does not exist.
+	HideMessage(ctx context.Context, messageID int64, userID string) error
+
+	// PurgeMessages permanently removes the tombstones deleted before `deletedBefore`, and returns how many messages
+	// have been removed. Replies to the removed messages lose their ReplyTo.
+	PurgeMessages(ctx context.Context, deletedBefore time.Time) (int64, error)
+
+	// SetReaction sets the reaction of the user to a message, replacing the previous one. It returns ErrNotFound if
+	// the message does not exist.
+	SetReaction(ctx context.Context, messageID int64, userID string, emoji string) error
+
+	// DeleteReaction removes the reaction of the user to a message, or returns ErrNotFound if there is none.
+	DeleteReaction(ctx context.Context, messageID int64, userID string) error
+
+	Ping(ctx context.Context) error
+
+	// SchemaVersion returns the current version of the database schema
+	SchemaVersion(ctx context.Context) (int, error)
+
+	// WithTx runs fn inside a transaction. The transaction is committed if fn returns nil, and rolled back otherwise
+	// (the error of fn is returned as-is). If the database is busy, the whole transaction is retried: fn must not
+	// have side effects outside the database.
+	// Calling WithTx on the AppDatabase received by fn runs the nested fn in the same transaction.
+	WithTx(ctx context.Context, fn func(tx AppDatabase) error) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
 }
 
 type appdbimpl struct {
-	c *sql.DB
+	// c is used for reads
+	c querier
+
+	// w is used for writes. It's the same as c, unless the AppDatabase has been created with NewWithWriter
+	w querier
+
+	// reader and writer are the connection pools. Inside a transaction, c and w are the transaction instead
+	reader *sql.DB
+	writer *sql.DB
+
+	// tx is not nil inside WithTx
+	tx *sql.Tx
+
+	clock globaltime.Clock
 }
 
-// New returns a new instance of AppDatabase based on the SQLite connection `db`.
-// `db` is required - an error will be returned if `db` is `nil`.
-func New(db *sql.DB) (AppDatabase, error) {
-	if db == nil {
+// New returns a new instance of AppDatabase based on the SQLite connection `db`, using `clock` as source of time (use
+// globaltime.Real() outside tests). `db` is used for both reads and writes.
+// `db` and `clock` are required - an error will be returned if any of them is `nil`.
+func New(db *sql.DB, clock globaltime.Clock) (AppDatabase, error) {
+	return NewWithWriter(db, db, clock)
+}
+
+// NewWithWriter returns a new instance of AppDatabase that reads from `reader` and writes to `writer`. Both must point
+// to the same database file: usually `reader` is opened with Open, and `writer` with OpenWriter, so that writes are
+// serialized on a single connection.
+// `reader`, `writer` and `clock` are required - an error will be returned if any of them is `nil`.
+func NewWithWriter(reader *sql.DB, writer *sql.DB, clock globaltime.Clock) (AppDatabase, error) {
+	if reader == nil || writer == nil {
 		return nil, errors.New("database is required when building a AppDatabase")
 	}
+	if clock == nil {
+		return nil, errors.New("clock is required when building a AppDatabase")
+	}
 
-	// Check if table exists. If not, the database is empty, and we need to create the structure
-	var tableName string
-	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name='example_table';`).Scan(&tableName)
-	if errors.Is(err, sql.ErrNoRows) {
-		sqlStmt := `CREATE TABLE example_table (id INTEGER NOT NULL PRIMARY KEY, name TEXT);`
-		_, err = db.Exec(sqlStmt)
-		if err != nil {
-			return nil, fmt.Errorf("error creating database structure: %w", err)
-		}
+	// Create the structure if the database is empty, or upgrade it to the latest schema version
+	if _, err := Migrate(writer); err != nil {
+		return nil, fmt.Errorf("error creating database structure: %w", err)
 	}
 
 	return &appdbimpl{
-		c: db,
+		c:      reader,
+		w:      writer,
+		reader: reader,
+		writer: writer,
+		clock:  clock,
 	}, nil
 }
 
-func (db *appdbimpl) Ping() error {
-	return db.c.Ping()
+func (db *appdbimpl) Ping(ctx context.Context) error {
+	if db.tx != nil {
+		// The transaction holds the only writer connection: pinging the writer pool would wait for it forever
+		var one int
+		return db.tx.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+	}
+	if err := db.reader.PingContext(ctx); err != nil {
+		return err
+	}
+	if db.writer == db.reader {
+		return nil
+	}
+	// The writer has a single connection: if it's in use, it's working, and a ping would wait for the running
+	// transaction to end
+	if db.writer.Stats().InUse > 0 {
+		return nil
+	}
+	return db.writer.PingContext(ctx)
 }
@@ -0,0 +1,74 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+)
+
+func testBlocks(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "bobby")
+
+	if blocked, err := db.IsBlocked(ctx, "u2", "u1"); err != nil || blocked {
+		t.Fatalf("IsBlocked() before BlockUser = %v, %v; want false, nil", blocked, err)
+	}
+	// Blocking twice is not an error
+	for i := 0; i < 2; i++ {
+		if err := db.BlockUser(ctx, "u2", "u1"); err != nil {
+			t.Fatalf("BlockUser() error: %v", err)
+		}
+	}
+	if err := db.BlockUser(ctx, "u2", "u3"); err != nil {
+		t.Fatalf("BlockUser() error: %v", err)
+	}
+	for _, ids := range [][2]string{{"u1", "missing"}, {"missing", "u1"}} {
+		if err := db.BlockUser(ctx, ids[0], ids[1]); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("BlockUser(%q, %q): error = %v, want database.ErrNotFound", ids[0], ids[1], err)
+		}
+	}
+
+	if blocked, err := db.IsBlocked(ctx, "u2", "u1"); err != nil || !blocked {
+		t.Fatalf("IsBlocked() = %v, %v; want true, nil", blocked, err)
+	}
+	// Blocks are not symmetric
+	if blocked, err := db.IsBlocked(ctx, "u1", "u2"); err != nil || blocked {
+		t.Fatalf("IsBlocked() of the blocked user = %v, %v; want false, nil", blocked, err)
+	}
+	if users, err := db.ListBlockedUsers(ctx, "u2"); err != nil ||
+		!reflect.DeepEqual(userNames(users), []string{"alice", "bobby"}) {
+		t.Fatalf("ListBlockedUsers() = %v, %v; want alice and bobby", userNames(users), err)
+	}
+	if users, err := db.ListBlockedUsers(ctx, "u1"); err != nil || users == nil || len(users) != 0 {
+		t.Fatalf("ListBlockedUsers() without blocks = %#v, %v; want an empty slice", users, err)
+	}
+
+	// Users blocked by the viewer are not found, but they still find the viewer
+	if users, err := db.SearchUsers(ctx, "", "u2", 10); err != nil ||
+		!reflect.DeepEqual(userNames(users), []string{"bob"}) {
+		t.Fatalf("SearchUsers() by the blocker = %v, %v; want bob", userNames(users), err)
+	}
+	if users, err := db.SearchUsers(ctx, "bob", "u1", 10); err != nil ||
+		!reflect.DeepEqual(userNames(users), []string{"bob", "bobby"}) {
+		t.Fatalf("SearchUsers() by a blocked user = %v, %v; want bob and bobby", userNames(users), err)
+	}
+
+	if err := db.UnblockUser(ctx, "u2", "u1"); err != nil {
+		t.Fatalf("UnblockUser() error: %v", err)
+	}
+	// Unblocking a user who is not blocked is not an error
+	if err := db.UnblockUser(ctx, "u2", "u1"); err != nil {
+		t.Fatalf("second UnblockUser() error: %v", err)
+	}
+	if blocked, err := db.IsBlocked(ctx, "u2", "u1"); err != nil || blocked {
+		t.Fatalf("IsBlocked() after UnblockUser = %v, %v; want false, nil", blocked, err)
+	}
+	if users, err := db.SearchUsers(ctx, "", "u2", 10); err != nil ||
+		!reflect.DeepEqual(userNames(users), []string{"alice", "bob"}) {
+		t.Fatalf("SearchUsers() after UnblockUser = %v, %v; want alice and bob", userNames(users), err)
+	}
+}
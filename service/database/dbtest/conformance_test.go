@@ -0,0 +1,71 @@
+package dbtest_test
+
+import (
+	"database/sql"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database/dbtest"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database/inmemory"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	_ "github.com/mattn/go-sqlite3"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLite(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) database.AppDatabase {
+		dbconn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+		if err != nil {
+			t.Fatalf("opening SQLite: %v", err)
+		}
+		t.Cleanup(func() { _ = dbconn.Close() })
+
+		db, err := database.New(dbconn, globaltime.NewFake(time.Unix(0, 0)))
+		if err != nil {
+			t.Fatalf("creating AppDatabase: %v", err)
+		}
+		return db
+	})
+}
+
+// TestSQLiteWithWriter uses the same setup as the webapi executable: a reader pool and a single writer connection, in
+// WAL mode, with foreign keys enforced.
+func TestSQLiteWithWriter(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) database.AppDatabase {
+		filename := filepath.Join(t.TempDir(), "test.db")
+		opts := database.ConnOptions{
+			JournalMode:  "WAL",
+			Synchronous:  "NORMAL",
+			BusyTimeout:  time.Second,
+			ForeignKeys:  true,
+			MaxOpenConns: 4,
+			MaxIdleConns: 4,
+		}
+		writer, err := database.OpenWriter(filename, opts)
+		if err != nil {
+			t.Fatalf("opening SQLite writer: %v", err)
+		}
+		t.Cleanup(func() { _ = writer.Close() })
+		reader, err := database.Open(filename, opts)
+		if err != nil {
+			t.Fatalf("opening SQLite reader: %v", err)
+		}
+		t.Cleanup(func() { _ = reader.Close() })
+
+		db, err := database.NewWithWriter(reader, writer, globaltime.NewFake(time.Unix(0, 0)))
+		if err != nil {
+			t.Fatalf("creating AppDatabase: %v", err)
+		}
+		return db
+	})
+}
+
+func TestInMemory(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) database.AppDatabase {
+		db, err := inmemory.New(globaltime.NewFake(time.Unix(0, 0)))
+		if err != nil {
+			t.Fatalf("creating AppDatabase: %v", err)
+		}
+		return db
+	})
+}
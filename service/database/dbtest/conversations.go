@@ -0,0 +1,201 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// createDirect creates the one-to-one conversation between two users, failing the test on errors.
+func createDirect(t *testing.T, db database.AppDatabase, userID string, otherID string) int64 {
+	t.Helper()
+	id, err := db.CreateDirectConversation(context.Background(), userID, otherID)
+	if err != nil {
+		t.Fatalf("CreateDirectConversation(%q, %q) error: %v", userID, otherID, err)
+	}
+	return id
+}
+
+func memberIDs(c database.Conversation) []string {
+	var ids = []string{}
+	for _, m := range c.Members {
+		ids = append(ids, m.UserID)
+	}
+	return ids
+}
+
+func testDirectConversation(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+
+	id := createDirect(t, db, "u2", "u1")
+	c, err := db.GetConversation(ctx, id)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if c.ID != id || c.IsGroup || c.Name != "" || c.CreatedAt.IsZero() {
+		t.Fatalf("GetConversation() = %+v", c)
+	}
+	// Members joined at the same time are sorted by ID
+	if got := memberIDs(c); !reflect.DeepEqual(got, []string{"u1", "u2"}) {
+		t.Fatalf("members = %v, want [u1 u2]", got)
+	}
+	if c.Members[0].Name != "alice" || c.Members[0].LastReadID != 0 {
+		t.Fatalf("first member = %+v", c.Members[0])
+	}
+
+	// The conversation is found with the users in any order, and it cannot be created twice
+	for _, pair := range [][2]string{{"u1", "u2"}, {"u2", "u1"}} {
+		if found, err := db.FindDirectConversation(ctx, pair[0], pair[1]); err != nil || found != id {
+			t.Fatalf("FindDirectConversation(%q, %q) = %d, %v; want %d, nil", pair[0], pair[1], found, err, id)
+		}
+		if _, err := db.CreateDirectConversation(ctx, pair[0], pair[1]); !errors.Is(err, database.ErrConflict) {
+			t.Fatalf("CreateDirectConversation() twice: error = %v, want database.ErrConflict", err)
+		}
+	}
+}
+
+func testDirectConversationNotFound(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+
+	if _, err := db.CreateDirectConversation(ctx, "u1", "missing"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("CreateDirectConversation() with a missing user: error = %v, want database.ErrNotFound", err)
+	}
+	if _, err := db.FindDirectConversation(ctx, "u1", "missing"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("FindDirectConversation() after a failed creation: error = %v, want database.ErrNotFound", err)
+	}
+	if _, err := db.GetConversation(ctx, 1000); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetConversation() on a missing conversation: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testListUserConversations(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	first := createDirect(t, db, "u1", "u2")
+	second := createDirect(t, db, "u3", "u1")
+	third := createDirect(t, db, "u2", "u3")
+
+	if ids, err := db.ListUserConversations(ctx, "u1"); err != nil || !reflect.DeepEqual(ids, []int64{first, second}) {
+		t.Fatalf("ListUserConversations() = %v, %v; want %v", ids, err, []int64{first, second})
+	}
+	if ids, err := db.ListConversations(ctx, 10); err != nil || !reflect.DeepEqual(ids, []int64{first, second, third}) {
+		t.Fatalf("ListConversations() = %v, %v; want %v", ids, err, []int64{first, second, third})
+	}
+	if ids, err := db.ListConversations(ctx, 2); err != nil || !reflect.DeepEqual(ids, []int64{first, second}) {
+		t.Fatalf("ListConversations() with a limit = %v, %v; want %v", ids, err, []int64{first, second})
+	}
+	createUser(t, db, "u4", "dave")
+	if ids, err := db.ListUserConversations(ctx, "u4"); err != nil || len(ids) != 0 || ids == nil {
+		t.Fatalf("ListUserConversations() without conversations = %#v, %v; want empty list", ids, err)
+	}
+}
+
+func testMarkers(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	id := createDirect(t, db, "u1", "u2")
+
+	markers := func() (int64, int64) {
+		t.Helper()
+		c, err := db.GetConversation(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.Members[1].LastDeliveredID, c.Members[1].LastReadID
+	}
+
+	if err := db.MarkDelivered(ctx, id, "u2", 5); err != nil {
+		t.Fatalf("MarkDelivered() error: %v", err)
+	}
+	if delivered, read := markers(); delivered != 5 || read != 0 {
+		t.Fatalf("markers = %d, %d; want 5, 0", delivered, read)
+	}
+	// Read implies delivered, and markers never move backwards
+	if err := db.MarkRead(ctx, id, "u2", 7); err != nil {
+		t.Fatalf("MarkRead() error: %v", err)
+	}
+	if err := db.MarkDelivered(ctx, id, "u2", 3); err != nil {
+		t.Fatalf("MarkDelivered() backwards error: %v", err)
+	}
+	if err := db.MarkRead(ctx, id, "u2", 2); err != nil {
+		t.Fatalf("MarkRead() backwards error: %v", err)
+	}
+	if delivered, read := markers(); delivered != 7 || read != 7 {
+		t.Fatalf("markers = %d, %d; want 7, 7", delivered, read)
+	}
+
+	if err := db.MarkRead(ctx, id, "u3", 1); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("MarkRead() for a user not in the conversation: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testConversationSettings(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	id := createGroup(t, db, "friends", "u1", "u2", "u3")
+	c, err := db.GetConversation(ctx, id)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if c.Members[0].Settings != (database.ConversationSettings{}) {
+		t.Fatalf("default settings = %+v", c.Members[0].Settings)
+	}
+
+	// The clock of the suite doesn't move: the conversation was created now
+	now := c.CreatedAt
+	muted := database.ConversationSettings{MutedUntil: now.Add(time.Hour), Archived: true, PinnedAt: now}
+	expired := database.ConversationSettings{MutedUntil: now, Archived: true}
+	for uid, s := range map[string]database.ConversationSettings{"u1": muted, "u2": expired} {
+		if err := db.SetConversationSettings(ctx, id, uid, s); err != nil {
+			t.Fatalf("SetConversationSettings(%s) error: %v", uid, err)
+		}
+	}
+	if err := db.SetConversationSettings(ctx, id, "missing", muted); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("SetConversationSettings() for a non-member: error = %v, want database.ErrNotFound", err)
+	}
+	if c, err = db.GetConversation(ctx, id); err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if s := c.Members[0].Settings; !s.MutedUntil.Equal(muted.MutedUntil) || !s.Archived || !s.PinnedAt.Equal(now) ||
+		!s.Muted(now) {
+		t.Fatalf("settings = %+v, want %+v", s, muted)
+	}
+
+	// Members muting the conversation stay archived
+	if err := db.UnarchiveConversation(ctx, id, nil); err != nil {
+		t.Fatalf("UnarchiveConversation() error: %v", err)
+	}
+	if c, err = db.GetConversation(ctx, id); err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if !c.Members[0].Settings.Archived || c.Members[1].Settings.Archived || c.Members[2].Settings.Archived {
+		t.Fatalf("settings after UnarchiveConversation() = %+v", c.Members)
+	}
+
+	// Settings are removed with the member
+	if err := db.RemoveMember(ctx, id, "u1"); err != nil {
+		t.Fatalf("RemoveMember() error: %v", err)
+	}
+	if err := db.AddMember(ctx, id, "u1"); err != nil {
+		t.Fatalf("AddMember() error: %v", err)
+	}
+	if c, err = db.GetConversation(ctx, id); err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	// Members joined at the same time are sorted by ID
+	if s := c.Members[0].Settings; c.Members[0].UserID != "u1" || s != (database.ConversationSettings{}) {
+		t.Fatalf("settings after joining again = %+v", c.Members[0])
+	}
+}
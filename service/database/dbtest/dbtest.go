@@ -0,0 +1,226 @@
+/*
+Package dbtest contains the conformance test suite for database.AppDatabase implementations. Every implementation must
+pass the suite, so that tests using the in-memory implementation behave like the production (SQLite) one.
+
+Usage, inside a test:
+
+	dbtest.Run(t, func(t *testing.T) database.AppDatabase {
+		db, err := inmemory.New(globaltime.NewFake(time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+*/
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"testing"
+	"time"
+)
+
+// Factory returns a new, empty instance of the implementation under test. Each call must return an independent
+// database.
+type Factory func(t *testing.T) database.AppDatabase
+
+// Run runs the whole conformance suite, each test case with a new database from `newDB`.
+func Run(t *testing.T, newDB Factory) {
+	for _, tc := range []struct {
+		name string
+		fn   func(*testing.T, database.AppDatabase)
+	}{
+		{"Ping", testPing},
+		{"SchemaVersion", testSchemaVersion},
+		{"GetNameNotFound", testGetNameNotFound},
+		{"SetAndGetName", testSetAndGetName},
+		{"SetNameConflict", testSetNameConflict},
+		{"SetNameEmptyString", testSetNameEmptyString},
+		{"CanceledContext", testCanceledContext},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"TxNested", testTxNested},
+		{"TxPing", testTxPing},
+		{"CreateAndGetUser", testCreateAndGetUser},
+		{"CreateUserConflict", testCreateUserConflict},
+		{"SetUserName", testSetUserName},
+		{"SetUserPhoto", testSetUserPhoto},
+		{"SetUserBanned", testSetUserBanned},
+		{"SearchUsers", testSearchUsers},
+		{"Blocks", testBlocks},
+		{"Sessions", testSessions},
+		{"DirectConversation", testDirectConversation},
+		{"DirectConversationNotFound", testDirectConversationNotFound},
+		{"ListUserConversations", testListUserConversations},
+		{"Markers", testMarkers},
+		{"ConversationSettings", testConversationSettings},
+		{"CreateAndGetMessage", testCreateAndGetMessage},
+		{"CreateMessageNotFound", testCreateMessageNotFound},
+		{"ListMessages", testListMessages},
+		{"Reactions", testReactions},
+		{"EditMessage", testEditMessage},
+		{"DeleteMessage", testDeleteMessage},
+		{"PurgeMessages", testPurgeMessages},
+		{"HideMessage", testHideMessage},
+		{"CountUnread", testCountUnread},
+		{"Mentions", testMentions},
+		{"SearchMessages", testSearchMessages},
+		{"CreateGroup", testCreateGroup},
+		{"SetGroupNameAndPhoto", testSetGroupNameAndPhoto},
+		{"GroupRolesAndPermissions", testGroupRolesAndPermissions},
+		{"GroupMembers", testGroupMembers},
+		{"DeleteConversation", testDeleteConversation},
+		{"Invites", testInvites},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newDB(t))
+		})
+	}
+}
+
+func testPing(t *testing.T, db database.AppDatabase) {
+	if err := db.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error: %v", err)
+	}
+}
+
+func testSchemaVersion(t *testing.T, db database.AppDatabase) {
+	version, err := db.SchemaVersion(context.Background())
+	if err != nil {
+		t.Fatalf("SchemaVersion() error: %v", err)
+	} else if version != database.LatestSchemaVersion() {
+		t.Fatalf("SchemaVersion() = %d, want %d", version, database.LatestSchemaVersion())
+	}
+}
+
+func testGetNameNotFound(t *testing.T, db database.AppDatabase) {
+	if _, err := db.GetName(context.Background()); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetName() on empty database: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testSetAndGetName(t *testing.T, db database.AppDatabase) {
+	if err := db.SetName(context.Background(), "gopher"); err != nil {
+		t.Fatalf("SetName() error: %v", err)
+	}
+	name, err := db.GetName(context.Background())
+	if err != nil {
+		t.Fatalf("GetName() error: %v", err)
+	} else if name != "gopher" {
+		t.Fatalf("GetName() = %q, want %q", name, "gopher")
+	}
+}
+
+func testSetNameConflict(t *testing.T, db database.AppDatabase) {
+	if err := db.SetName(context.Background(), "first"); err != nil {
+		t.Fatalf("SetName() error: %v", err)
+	}
+	if err := db.SetName(context.Background(), "second"); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("second SetName(): error = %v, want database.ErrConflict", err)
+	}
+	if name, err := db.GetName(context.Background()); err != nil || name != "first" {
+		t.Fatalf("GetName() after conflict = %q, %v; want %q, nil", name, err, "first")
+	}
+}
+
+func testSetNameEmptyString(t *testing.T, db database.AppDatabase) {
+	if err := db.SetName(context.Background(), ""); err != nil {
+		t.Fatalf("SetName(\"\") error: %v", err)
+	}
+	if name, err := db.GetName(context.Background()); err != nil || name != "" {
+		t.Fatalf("GetName() = %q, %v; want empty string, nil", name, err)
+	}
+}
+
+func testCanceledContext(t *testing.T, db database.AppDatabase) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := db.SetName(ctx, "gopher"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SetName() with canceled context: error = %v, want context.Canceled", err)
+	}
+	if _, err := db.GetName(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetName() with canceled context: error = %v, want context.Canceled", err)
+	}
+	err := db.WithTx(ctx, func(tx database.AppDatabase) error {
+		return tx.SetName(ctx, "gopher")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WithTx() with canceled context: error = %v, want context.Canceled", err)
+	}
+	if _, err := db.GetName(context.Background()); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetName() after canceled writes: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testTxCommit(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	err := db.WithTx(ctx, func(tx database.AppDatabase) error {
+		if err := tx.SetName(ctx, "gopher"); err != nil {
+			return err
+		}
+		// Reads inside the transaction see its own writes
+		if name, err := tx.GetName(ctx); err != nil || name != "gopher" {
+			t.Errorf("GetName() inside transaction = %q, %v; want %q, nil", name, err, "gopher")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error: %v", err)
+	}
+	if name, err := db.GetName(ctx); err != nil || name != "gopher" {
+		t.Fatalf("GetName() after commit = %q, %v; want %q, nil", name, err, "gopher")
+	}
+}
+
+func testTxRollback(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+	err := db.WithTx(ctx, func(tx database.AppDatabase) error {
+		if err := tx.SetName(ctx, "gopher"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx() error = %v, want the error returned by fn", err)
+	}
+	if _, err := db.GetName(ctx); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetName() after rollback: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testTxNested(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+	err := db.WithTx(ctx, func(tx database.AppDatabase) error {
+		err := tx.WithTx(ctx, func(nested database.AppDatabase) error {
+			return nested.SetName(ctx, "gopher")
+		})
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTx() error = %v, want the error returned by fn", err)
+	}
+	// The nested function is part of the outer transaction, so it's rolled back too
+	if _, err := db.GetName(ctx); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetName() after rollback of the outer transaction: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testTxPing(t *testing.T, db database.AppDatabase) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := db.WithTx(ctx, func(tx database.AppDatabase) error {
+		return tx.Ping(ctx)
+	})
+	if err != nil {
+		t.Fatalf("Ping() inside transaction error: %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+)
+
+// createGroup creates a group with the given members, failing the test on errors.
+func createGroup(t *testing.T, db database.AppDatabase, name string, memberIDs ...string) int64 {
+	t.Helper()
+	id, err := db.CreateGroup(context.Background(), name, "aGVsbG8=", memberIDs)
+	if err != nil {
+		t.Fatalf("CreateGroup(%q, %v) error: %v", name, memberIDs, err)
+	}
+	return id
+}
+
+func testCreateGroup(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+
+	id := createGroup(t, db, "friends", "u2", "u1")
+	c, err := db.GetConversation(ctx, id)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if !c.IsGroup || c.Name != "friends" || c.Photo != "aGVsbG8=" {
+		t.Fatalf("GetConversation() = %+v", c)
+	}
+	if got := memberIDs(c); !reflect.DeepEqual(got, []string{"u1", "u2"}) {
+		t.Fatalf("members = %v, want [u1 u2]", got)
+	}
+	// The first member given is the owner
+	if c.Members[0].Role != database.RoleMember || c.Members[1].Role != database.RoleOwner {
+		t.Fatalf("roles = %+v", c.Members)
+	}
+	want := database.GroupPermissions{
+		Rename:        database.PermissionMembers,
+		AddMembers:    database.PermissionMembers,
+		RemoveMembers: database.PermissionAdmins,
+	}
+	if c.Permissions != want {
+		t.Fatalf("permissions = %+v, want %+v", c.Permissions, want)
+	}
+
+	// A group with a missing user is not created
+	if _, err := db.CreateGroup(ctx, "broken", "", []string{"u1", "missing"}); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("CreateGroup() with a missing user: error = %v, want database.ErrNotFound", err)
+	}
+	if ids, err := db.ListUserConversations(ctx, "u1"); err != nil || !reflect.DeepEqual(ids, []int64{id}) {
+		t.Fatalf("ListUserConversations() = %v, %v; want [%d]", ids, err, id)
+	}
+}
+
+func testSetGroupNameAndPhoto(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	group := createGroup(t, db, "friends", "u1")
+	direct := createDirect(t, db, "u1", "u2")
+
+	if err := db.SetGroupName(ctx, group, "family"); err != nil {
+		t.Fatalf("SetGroupName() error: %v", err)
+	}
+	if err := db.SetGroupPhoto(ctx, group, "d29ybGQ="); err != nil {
+		t.Fatalf("SetGroupPhoto() error: %v", err)
+	}
+	if c, err := db.GetConversation(ctx, group); err != nil || c.Name != "family" || c.Photo != "d29ybGQ=" {
+		t.Fatalf("GetConversation() = %+v, %v", c, err)
+	}
+
+	// One-to-one conversations have no name or photo
+	for _, id := range []int64{direct, 1000} {
+		if err := db.SetGroupName(ctx, id, "x"); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("SetGroupName(%d): error = %v, want database.ErrNotFound", id, err)
+		}
+		if err := db.SetGroupPhoto(ctx, id, "x"); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("SetGroupPhoto(%d): error = %v, want database.ErrNotFound", id, err)
+		}
+	}
+}
+
+func testGroupRolesAndPermissions(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	group := createGroup(t, db, "friends", "u1")
+	direct := createDirect(t, db, "u1", "u2")
+	if err := db.AddMember(ctx, group, "u2"); err != nil {
+		t.Fatalf("AddMember() error: %v", err)
+	}
+
+	if err := db.SetMemberRole(ctx, group, "u2", database.RoleAdmin); err != nil {
+		t.Fatalf("SetMemberRole() error: %v", err)
+	}
+	perms := database.GroupPermissions{
+		Rename:        database.PermissionAdmins,
+		AddMembers:    database.PermissionAdmins,
+		RemoveMembers: database.PermissionMembers,
+	}
+	if err := db.SetGroupPermissions(ctx, group, perms); err != nil {
+		t.Fatalf("SetGroupPermissions() error: %v", err)
+	}
+	c, err := db.GetConversation(ctx, group)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if c.Members[0].Role != database.RoleOwner || c.Members[1].Role != database.RoleAdmin || c.Permissions != perms {
+		t.Fatalf("GetConversation() = %+v", c)
+	}
+
+	if err := db.SetMemberRole(ctx, group, "u2", "king"); !errors.Is(err, database.ErrInvalid) {
+		t.Fatalf("SetMemberRole() with an unknown role: error = %v, want database.ErrInvalid", err)
+	}
+	if err := db.SetMemberRole(ctx, group, "missing", database.RoleAdmin); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("SetMemberRole() for a non-member: error = %v, want database.ErrNotFound", err)
+	}
+	perms.Rename = "nobody"
+	if err := db.SetGroupPermissions(ctx, group, perms); !errors.Is(err, database.ErrInvalid) {
+		t.Fatalf("SetGroupPermissions() with an unknown value: error = %v, want database.ErrInvalid", err)
+	}
+	// One-to-one conversations have no permissions to change
+	perms.Rename = database.PermissionAdmins
+	for _, id := range []int64{direct, 1000} {
+		if err := db.SetGroupPermissions(ctx, id, perms); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("SetGroupPermissions(%d): error = %v, want database.ErrNotFound", id, err)
+		}
+	}
+	if c, err := db.GetConversation(ctx, direct); err != nil || c.Members[0].Role != database.RoleMember {
+		t.Fatalf("GetConversation(direct) = %+v, %v", c, err)
+	}
+}
+
+func testGroupMembers(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	group := createGroup(t, db, "friends", "u1")
+	old := sendMessage(t, db, group, "u1", "before bob joined")
+
+	if err := db.AddMember(ctx, group, "u2"); err != nil {
+		t.Fatalf("AddMember() error: %v", err)
+	}
+	c, err := db.GetConversation(ctx, group)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	// Messages sent before joining count as read
+	if got := memberIDs(c); !reflect.DeepEqual(got, []string{"u1", "u2"}) || c.Members[1].LastReadID != old.ID {
+		t.Fatalf("members = %+v", c.Members)
+	}
+
+	if err := db.AddMember(ctx, group, "u2"); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("AddMember() twice: error = %v, want database.ErrConflict", err)
+	}
+	if err := db.AddMember(ctx, group, "missing"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("AddMember() with a missing user: error = %v, want database.ErrNotFound", err)
+	}
+	if err := db.AddMember(ctx, 1000, "u2"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("AddMember() to a missing group: error = %v, want database.ErrNotFound", err)
+	}
+
+	if err := db.RemoveMember(ctx, group, "u1"); err != nil {
+		t.Fatalf("RemoveMember() error: %v", err)
+	}
+	if err := db.RemoveMember(ctx, group, "u1"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("RemoveMember() twice: error = %v, want database.ErrNotFound", err)
+	}
+	if c, err := db.GetConversation(ctx, group); err != nil || !reflect.DeepEqual(memberIDs(c), []string{"u2"}) {
+		t.Fatalf("GetConversation() after RemoveMember() = %+v, %v", c, err)
+	}
+	// The messages of a former member are kept
+	if _, err := db.GetMessage(ctx, old.ID); err != nil {
+		t.Fatalf("GetMessage() after RemoveMember() error: %v", err)
+	}
+}
+
+func testDeleteConversation(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	group := createGroup(t, db, "friends", "u1", "u2")
+	direct := createDirect(t, db, "u1", "u2")
+	m := sendMessage(t, db, group, "u1", "hello")
+	if err := db.SetReaction(ctx, m.ID, "u2", "👍"); err != nil {
+		t.Fatalf("SetReaction() error: %v", err)
+	}
+	if _, err := db.EditMessage(ctx, m.ID, "hello!", nil); err != nil {
+		t.Fatalf("EditMessage() error: %v", err)
+	}
+
+	for _, id := range []int64{group, direct} {
+		if err := db.DeleteConversation(ctx, id); err != nil {
+			t.Fatalf("DeleteConversation(%d) error: %v", id, err)
+		}
+		if _, err := db.GetConversation(ctx, id); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("GetConversation(%d) after delete: error = %v, want database.ErrNotFound", id, err)
+		}
+	}
+	if err := db.DeleteConversation(ctx, group); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("DeleteConversation() twice: error = %v, want database.ErrNotFound", err)
+	}
+	if _, err := db.GetMessage(ctx, m.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetMessage() after delete: error = %v, want database.ErrNotFound", err)
+	}
+	if list, err := db.ListRevisions(ctx, m.ID); err != nil || len(list) != 0 {
+		t.Fatalf("ListRevisions() after delete = %v, %v; want []", list, err)
+	}
+	if ids, err := db.ListUserConversations(ctx, "u1"); err != nil || len(ids) != 0 {
+		t.Fatalf("ListUserConversations() after delete = %v, %v; want []", ids, err)
+	}
+
+	// The one-to-one conversation can be started again
+	if _, err := db.FindDirectConversation(ctx, "u1", "u2"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("FindDirectConversation() after delete: error = %v, want database.ErrNotFound", err)
+	}
+	createDirect(t, db, "u2", "u1")
+}
@@ -0,0 +1,88 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"testing"
+	"time"
+)
+
+func testInvites(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	group := createGroup(t, db, "friends", "u1")
+	direct := createDirect(t, db, "u1", "u2")
+
+	first, err := db.CreateInvite(ctx, database.NewInvite{Token: "first", ConversationID: group, CreatedBy: "u1"})
+	if err != nil {
+		t.Fatalf("CreateInvite() error: %v", err)
+	}
+	if first.Token != "first" || first.CreatedBy != "u1" || first.CreatedAt.IsZero() || !first.ExpiresAt.IsZero() ||
+		first.MaxUses != 0 || first.Uses != 0 {
+		t.Fatalf("CreateInvite() = %+v", first)
+	}
+	expiresAt := first.CreatedAt.Add(time.Hour)
+	second, err := db.CreateInvite(ctx, database.NewInvite{Token: "second", ConversationID: group, CreatedBy: "u1",
+		ExpiresAt: expiresAt, MaxUses: 1})
+	if err != nil {
+		t.Fatalf("CreateInvite() error: %v", err)
+	}
+	if !second.ExpiresAt.Equal(expiresAt) || second.MaxUses != 1 {
+		t.Fatalf("CreateInvite() = %+v", second)
+	}
+	if got, err := db.GetInvite(ctx, "second"); err != nil || got != second {
+		t.Fatalf("GetInvite() = %+v, %v; want %+v", got, err, second)
+	}
+
+	if _, err := db.CreateInvite(ctx, database.NewInvite{Token: "first", ConversationID: group,
+		CreatedBy: "u1"}); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("CreateInvite() with a used token: error = %v, want database.ErrConflict", err)
+	}
+	for _, ni := range []database.NewInvite{
+		{Token: "x", ConversationID: direct, CreatedBy: "u1"},
+		{Token: "x", ConversationID: 1000, CreatedBy: "u1"},
+		{Token: "x", ConversationID: group, CreatedBy: "missing"},
+	} {
+		if _, err := db.CreateInvite(ctx, ni); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("CreateInvite(%+v): error = %v, want database.ErrNotFound", ni, err)
+		}
+	}
+
+	// The use cap is enforced, the expiration is left to Usable
+	if err := db.UseInvite(ctx, "second"); err != nil {
+		t.Fatalf("UseInvite() error: %v", err)
+	}
+	if err := db.UseInvite(ctx, "second"); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("UseInvite() when used up: error = %v, want database.ErrConflict", err)
+	}
+	if err := db.UseInvite(ctx, "missing"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("UseInvite() of a missing invite: error = %v, want database.ErrNotFound", err)
+	}
+	list, err := db.ListInvites(ctx, group)
+	if err != nil || len(list) != 2 || list[0].Token != "first" || list[1].Uses != 1 {
+		t.Fatalf("ListInvites() = %+v, %v", list, err)
+	}
+	if list[1].Usable(first.CreatedAt) || !list[0].Usable(expiresAt) {
+		t.Fatalf("Usable() of %+v", list)
+	}
+
+	if err := db.DeleteInvite(ctx, direct, "first"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("DeleteInvite() from another conversation: error = %v, want database.ErrNotFound", err)
+	}
+	if err := db.DeleteInvite(ctx, group, "first"); err != nil {
+		t.Fatalf("DeleteInvite() error: %v", err)
+	}
+	if _, err := db.GetInvite(ctx, "first"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetInvite() after delete: error = %v, want database.ErrNotFound", err)
+	}
+
+	// Invites are deleted with their group
+	if err := db.DeleteConversation(ctx, group); err != nil {
+		t.Fatalf("DeleteConversation() error: %v", err)
+	}
+	if list, err := db.ListInvites(ctx, group); err != nil || len(list) != 0 {
+		t.Fatalf("ListInvites() after DeleteConversation() = %+v, %v; want []", list, err)
+	}
+}
@@ -0,0 +1,111 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testMentions(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	id := createGroup(t, db, "friends", "u1", "u2", "u3")
+
+	// Mentions are sorted by offset
+	mentions := []database.Mention{{UserID: "u3", Offset: 11, Length: 6}, {UserID: "u2", Offset: 0, Length: 4}}
+	created, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: id, SenderID: "u1",
+		Content: "@bob hello @carol", Mentions: mentions})
+	if err != nil {
+		t.Fatalf("CreateMessage() error: %v", err)
+	}
+	want := []database.Mention{mentions[1], mentions[0]}
+	if !reflect.DeepEqual(created.Mentions, want) {
+		t.Fatalf("CreateMessage() mentions = %+v, want %+v", created.Mentions, want)
+	}
+	if list, err := db.ListMessages(ctx, id, "", 10); err != nil || !reflect.DeepEqual(list[0].Mentions, want) {
+		t.Fatalf("ListMessages() = %+v, %v; want the mentions %+v", list, err, want)
+	}
+	if list, err := db.SearchMessages(ctx, database.MessageSearch{UserID: "u2", Words: []string{"hello"},
+		Limit: 10}); err != nil || len(list) != 1 || !reflect.DeepEqual(list[0].Mentions, want) {
+		t.Fatalf("SearchMessages() = %+v, %v; want the mentions %+v", list, err, want)
+	}
+	for _, bad := range [][]database.Mention{
+		{{UserID: "u2", Offset: -1, Length: 4}},
+		{{UserID: "u2", Offset: 0, Length: 1}},
+	} {
+		_, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: id, SenderID: "u1", Content: "x",
+			Mentions: bad})
+		if !errors.Is(err, database.ErrInvalid) {
+			t.Fatalf("CreateMessage() with mentions %+v: error = %v, want database.ErrInvalid", bad, err)
+		}
+	}
+	_, err = db.CreateMessage(ctx, database.NewMessage{ConversationID: id, SenderID: "u1", Content: "@bob",
+		Mentions: []database.Mention{{UserID: "u2", Offset: 0, Length: 4}, {UserID: "u3", Offset: 0, Length: 4}}})
+	if !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("CreateMessage() with two mentions at the same offset: error = %v, want database.ErrConflict", err)
+	}
+
+	assertMentionCount := func(userID string, want int) {
+		t.Helper()
+		if n, err := db.CountMentions(ctx, id, userID); err != nil || n != want {
+			t.Fatalf("CountMentions(%s) = %d, %v; want %d, nil", userID, n, err, want)
+		}
+	}
+	// Only unread, visible messages of the other members count
+	second := sendMessage(t, db, id, "u3", "no mentions")
+	mentionsBob := []database.Mention{{UserID: "u2", Offset: 3, Length: 4}}
+	third, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: id, SenderID: "u3", Content: "hi @bob",
+		Mentions: mentionsBob})
+	if err != nil {
+		t.Fatalf("CreateMessage() error: %v", err)
+	}
+	assertMentionCount("u2", 2)
+	assertMentionCount("u1", 0)
+	assertMentionCount("missing", 0)
+	if err := db.MarkRead(ctx, id, "u2", second.ID); err != nil {
+		t.Fatalf("MarkRead() error: %v", err)
+	}
+	assertMentionCount("u2", 1)
+	if err := db.HideMessage(ctx, third.ID, "u2"); err != nil {
+		t.Fatalf("HideMessage() error: %v", err)
+	}
+	assertMentionCount("u2", 0)
+
+	// Edits replace the mentions, tombstones have none
+	edited, err := db.EditMessage(ctx, created.ID, "hello @bob", mentionsBob[:1])
+	if err != nil || len(edited.Mentions) != 1 || edited.Mentions[0].Offset != 3 {
+		t.Fatalf("EditMessage() = %+v, %v; want only the mention of bob", edited, err)
+	}
+	if err := db.DeleteMessage(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteMessage() error: %v", err)
+	}
+	if m, err := db.GetMessage(ctx, created.ID); err != nil || len(m.Mentions) != 0 {
+		t.Fatalf("GetMessage() of a tombstone = %+v, %v; want no mentions", m, err)
+	}
+
+	// Mentions override the mute when unarchiving
+	c, err := db.GetConversation(ctx, id)
+	if err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	muted := database.ConversationSettings{MutedUntil: c.CreatedAt.Add(time.Hour), Archived: true}
+	for _, uid := range []string{"u2", "u3"} {
+		if err := db.SetConversationSettings(ctx, id, uid, muted); err != nil {
+			t.Fatalf("SetConversationSettings() error: %v", err)
+		}
+	}
+	if err := db.UnarchiveConversation(ctx, id, []string{"u2"}); err != nil {
+		t.Fatalf("UnarchiveConversation() error: %v", err)
+	}
+	if c, err = db.GetConversation(ctx, id); err != nil {
+		t.Fatalf("GetConversation() error: %v", err)
+	}
+	if c.Members[1].Settings.Archived || !c.Members[2].Settings.Archived {
+		t.Fatalf("settings after UnarchiveConversation() with a mention = %+v", c.Members)
+	}
+}
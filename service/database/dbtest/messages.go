@@ -0,0 +1,382 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sendMessage creates a text message, failing the test on errors.
+func sendMessage(t *testing.T, db database.AppDatabase, conversationID int64, senderID string, content string) database.Message {
+	t.Helper()
+	m, err := db.CreateMessage(context.Background(), database.NewMessage{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Content:        content,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage() error: %v", err)
+	}
+	return m
+}
+
+func messageContents(list []database.Message) []string {
+	var contents = []string{}
+	for _, m := range list {
+		contents = append(contents, m.Content)
+	}
+	return contents
+}
+
+func testCreateAndGetMessage(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+
+	first := sendMessage(t, db, id, "u1", "hello")
+	created, err := db.CreateMessage(ctx, database.NewMessage{
+		ConversationID: id,
+		SenderID:       "u2",
+		Attachment:     "aGVsbG8=",
+		ReplyTo:        first.ID,
+		Forwarded:      true,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage() error: %v", err)
+	}
+	want := database.Message{
+		ID:             created.ID,
+		ConversationID: id,
+		SenderID:       "u2",
+		SenderName:     "bob",
+		SentAt:         created.SentAt,
+		Attachment:     "aGVsbG8=",
+		ReplyTo:        first.ID,
+		Forwarded:      true,
+		Mentions:       []database.Mention{},
+		Reactions:      []database.Reaction{},
+	}
+	if !reflect.DeepEqual(created, want) || created.ID <= first.ID || created.SentAt.IsZero() {
+		t.Fatalf("CreateMessage() = %+v, want %+v", created, want)
+	}
+	if m, err := db.GetMessage(ctx, created.ID); err != nil || !reflect.DeepEqual(m, created) {
+		t.Fatalf("GetMessage() = %+v, %v; want %+v, nil", m, err, created)
+	}
+
+	// The sender name is the current one
+	if err := db.SetUserName(ctx, "u2", "bobby"); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := db.GetMessage(ctx, created.ID); err != nil || m.SenderName != "bobby" {
+		t.Fatalf("GetMessage() after renaming the sender = %+v, %v", m, err)
+	}
+	if _, err := db.GetMessage(ctx, 1000); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetMessage() on a missing message: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testCreateMessageNotFound(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+
+	for _, nm := range []database.NewMessage{
+		{ConversationID: id + 1, SenderID: "u1", Content: "hello"},
+		{ConversationID: id, SenderID: "missing", Content: "hello"},
+	} {
+		if _, err := db.CreateMessage(ctx, nm); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("CreateMessage(%+v): error = %v, want database.ErrNotFound", nm, err)
+		}
+	}
+}
+
+func testListMessages(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	id := createDirect(t, db, "u1", "u2")
+	other := createDirect(t, db, "u1", "u3")
+
+	sendMessage(t, db, id, "u1", "one")
+	sendMessage(t, db, other, "u1", "other")
+	second := sendMessage(t, db, id, "u2", "two")
+	third := sendMessage(t, db, id, "u1", "three")
+	if err := db.SetReaction(ctx, second.ID, "u1", "👍"); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := db.ListMessages(ctx, id, "", 2)
+	if err != nil {
+		t.Fatalf("ListMessages() error: %v", err)
+	}
+	if got := messageContents(list); !reflect.DeepEqual(got, []string{"three", "two"}) {
+		t.Fatalf("ListMessages() = %v, want newest first", got)
+	}
+	if list[0].ID != third.ID || len(list[0].Reactions) != 0 || len(list[1].Reactions) != 1 {
+		t.Fatalf("ListMessages() = %+v", list)
+	}
+
+	if list, err := db.ListMessages(ctx, id, "", 10); err != nil || len(list) != 3 {
+		t.Fatalf("ListMessages() = %v, %v; want 3 messages", messageContents(list), err)
+	}
+	if list, err := db.ListMessages(ctx, 1000, "", 10); err != nil || list == nil || len(list) != 0 {
+		t.Fatalf("ListMessages() on a missing conversation = %#v, %v; want empty list", list, err)
+	}
+}
+
+func testReactions(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+	m := sendMessage(t, db, id, "u1", "hello")
+
+	for _, r := range []struct{ user, emoji string }{{"u2", "👍"}, {"u1", "🎉"}, {"u2", "❤"}} {
+		if err := db.SetReaction(ctx, m.ID, r.user, r.emoji); err != nil {
+			t.Fatalf("SetReaction() error: %v", err)
+		}
+	}
+
+	// The second reaction of u2 replaces the first one
+	got, err := db.GetMessage(ctx, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emojis = map[string]string{}
+	for _, r := range got.Reactions {
+		emojis[r.UserID] = r.Emoji
+	}
+	if !reflect.DeepEqual(emojis, map[string]string{"u1": "🎉", "u2": "❤"}) || len(got.Reactions) != 2 {
+		t.Fatalf("reactions = %+v", got.Reactions)
+	}
+
+	if err := db.DeleteReaction(ctx, m.ID, "u2"); err != nil {
+		t.Fatalf("DeleteReaction() error: %v", err)
+	}
+	if err := db.DeleteReaction(ctx, m.ID, "u2"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("DeleteReaction() twice: error = %v, want database.ErrNotFound", err)
+	}
+	if err := db.SetReaction(ctx, m.ID+1, "u1", "👍"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("SetReaction() on a missing message: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testEditMessage(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+	m := sendMessage(t, db, id, "u1", "helo")
+	if !m.EditedAt.IsZero() {
+		t.Fatalf("EditedAt of a new message = %v, want zero", m.EditedAt)
+	}
+	if list, err := db.ListRevisions(ctx, m.ID); err != nil || list == nil || len(list) != 0 {
+		t.Fatalf("ListRevisions() of a new message = %#v, %v; want empty list", list, err)
+	}
+
+	for _, content := range []string{"hello", "hello!"} {
+		edited, err := db.EditMessage(ctx, m.ID, content, nil)
+		if err != nil {
+			t.Fatalf("EditMessage() error: %v", err)
+		} else if edited.Content != content || edited.EditedAt.IsZero() || !edited.SentAt.Equal(m.SentAt) {
+			t.Fatalf("EditMessage() = %+v", edited)
+		}
+	}
+	if got, err := db.GetMessage(ctx, m.ID); err != nil || got.Content != "hello!" || got.EditedAt.IsZero() {
+		t.Fatalf("GetMessage() after edit = %+v, %v", got, err)
+	}
+
+	list, err := db.ListRevisions(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("ListRevisions() error: %v", err)
+	}
+	var contents []string
+	for _, r := range list {
+		if r.EditedAt.IsZero() {
+			t.Fatalf("revision %+v without EditedAt", r)
+		}
+		contents = append(contents, r.Content)
+	}
+	if !reflect.DeepEqual(contents, []string{"helo", "hello"}) {
+		t.Fatalf("ListRevisions() = %v, want oldest first", contents)
+	}
+
+	if _, err := db.EditMessage(ctx, m.ID+1, "hello", nil); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("EditMessage() on a missing message: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testDeleteMessage(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+	m := sendMessage(t, db, id, "u1", "hello")
+	reply, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: id, SenderID: "u2", Content: "hi",
+		ReplyTo: m.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.EditMessage(ctx, m.ID, "hello!", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetReaction(ctx, m.ID, "u2", "👍"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.DeleteMessage(ctx, m.ID); err != nil {
+		t.Fatalf("DeleteMessage() error: %v", err)
+	}
+	deleted, err := db.GetMessage(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("GetMessage() of a tombstone error: %v", err)
+	}
+	if deleted.Content != "" || deleted.Attachment != "" || len(deleted.Reactions) != 0 || deleted.DeletedAt.IsZero() ||
+		deleted.SenderID != "u1" || !deleted.SentAt.Equal(m.SentAt) {
+		t.Fatalf("tombstone = %+v", deleted)
+	}
+	if list, err := db.ListRevisions(ctx, m.ID); err != nil || len(list) != 0 {
+		t.Fatalf("ListRevisions() of a tombstone = %v, %v; want []", list, err)
+	}
+	if got, err := db.GetMessage(ctx, reply.ID); err != nil || got.ReplyTo != m.ID {
+		t.Fatalf("reply to a tombstone = %+v, %v", got, err)
+	}
+	if list, err := db.ListMessages(ctx, id, "", 10); err != nil || len(list) != 2 {
+		t.Fatalf("ListMessages() = %v, %v; want the tombstone too", messageContents(list), err)
+	}
+
+	// Deleting again keeps the time of the first deletion
+	if err := db.DeleteMessage(ctx, m.ID); err != nil {
+		t.Fatalf("DeleteMessage() twice error: %v", err)
+	}
+	if again, err := db.GetMessage(ctx, m.ID); err != nil || !again.DeletedAt.Equal(deleted.DeletedAt) {
+		t.Fatalf("GetMessage() after the second delete = %+v, %v", again, err)
+	}
+	if err := db.DeleteMessage(ctx, 1000); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("DeleteMessage() on a missing message: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testPurgeMessages(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+	m := sendMessage(t, db, id, "u1", "hello")
+	kept := sendMessage(t, db, id, "u1", "kept")
+	reply, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: id, SenderID: "u2", Content: "hi",
+		ReplyTo: m.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteMessage(ctx, m.ID); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := db.GetMessage(ctx, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only tombstones deleted strictly before the given time are removed
+	if n, err := db.PurgeMessages(ctx, deleted.DeletedAt); err != nil || n != 0 {
+		t.Fatalf("PurgeMessages(deletion time) = %d, %v; want 0, nil", n, err)
+	}
+	if n, err := db.PurgeMessages(ctx, deleted.DeletedAt.Add(time.Millisecond)); err != nil || n != 1 {
+		t.Fatalf("PurgeMessages() = %d, %v; want 1, nil", n, err)
+	}
+	if _, err := db.GetMessage(ctx, m.ID); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetMessage() after purge: error = %v, want database.ErrNotFound", err)
+	}
+	if got, err := db.GetMessage(ctx, reply.ID); err != nil || got.ReplyTo != 0 {
+		t.Fatalf("reply to a purged message = %+v, %v; want no ReplyTo", got, err)
+	}
+	if _, err := db.GetMessage(ctx, kept.ID); err != nil {
+		t.Fatalf("GetMessage() of a message not deleted: %v", err)
+	}
+}
+
+func testCountUnread(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	id := createDirect(t, db, "u1", "u2")
+	first := sendMessage(t, db, id, "u1", "first")
+	second := sendMessage(t, db, id, "u1", "second")
+	third := sendMessage(t, db, id, "u1", "third")
+	sendMessage(t, db, id, "u2", "reply")
+
+	count := func(userID string) int {
+		t.Helper()
+		n, err := db.CountUnread(ctx, id, userID)
+		if err != nil {
+			t.Fatalf("CountUnread(%s) error: %v", userID, err)
+		}
+		return n
+	}
+	if u1, u2, u3 := count("u1"), count("u2"), count("u3"); u1 != 1 || u2 != 3 || u3 != 0 {
+		t.Fatalf("CountUnread() = %d, %d, %d; want 1, 3, 0", u1, u2, u3)
+	}
+
+	// Messages before the read marker, tombstones and hidden messages are not unread
+	if err := db.MarkRead(ctx, id, "u2", first.ID); err != nil {
+		t.Fatalf("MarkRead() error: %v", err)
+	}
+	if err := db.DeleteMessage(ctx, third.ID); err != nil {
+		t.Fatalf("DeleteMessage() error: %v", err)
+	}
+	if n := count("u2"); n != 1 {
+		t.Fatalf("CountUnread() = %d, want 1", n)
+	}
+	if err := db.HideMessage(ctx, second.ID, "u2"); err != nil {
+		t.Fatalf("HideMessage() error: %v", err)
+	}
+	if n := count("u2"); n != 0 {
+		t.Fatalf("CountUnread() after HideMessage() = %d, want 0", n)
+	}
+}
+
+func testHideMessage(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	id := createDirect(t, db, "u1", "u2")
+	first := sendMessage(t, db, id, "u1", "one")
+	second := sendMessage(t, db, id, "u2", "two")
+	if err := db.SetReaction(ctx, first.ID, "u1", "👍"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := db.HideMessage(ctx, second.ID, "u1"); err != nil {
+			t.Fatalf("HideMessage() error: %v", err)
+		}
+	}
+
+	// The newest message not hidden is returned, with its reactions
+	list, err := db.ListMessages(ctx, id, "u1", 1)
+	if err != nil {
+		t.Fatalf("ListMessages() error: %v", err)
+	} else if got := messageContents(list); !reflect.DeepEqual(got, []string{"one"}) || len(list[0].Reactions) != 1 {
+		t.Fatalf("ListMessages() as u1 = %+v, want the message not hidden", list)
+	}
+	for _, userID := range []string{"u2", ""} {
+		list, err := db.ListMessages(ctx, id, userID, 10)
+		if got := messageContents(list); err != nil || !reflect.DeepEqual(got, []string{"two", "one"}) {
+			t.Fatalf("ListMessages() as %q = %v, %v; want all messages", userID, got, err)
+		}
+	}
+	if _, err := db.GetMessage(ctx, second.ID); err != nil {
+		t.Fatalf("GetMessage() of a hidden message: %v", err)
+	}
+
+	if err := db.HideMessage(ctx, 1000, "u1"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("HideMessage() on a missing message: error = %v, want database.ErrNotFound", err)
+	}
+}
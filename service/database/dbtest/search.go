@@ -0,0 +1,109 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testSearchMessages(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+	createUser(t, db, "u3", "carol")
+	direct := createDirect(t, db, "u1", "u2")
+	group := createGroup(t, db, "friends", "u2", "u3")
+
+	first := sendMessage(t, db, direct, "u1", "Ciao, come STAI?")
+	sendMessage(t, db, direct, "u2", "sto bene, grazie")
+	third := sendMessage(t, db, direct, "u2", "stairs: stai attento")
+	sendMessage(t, db, group, "u2", "stai in the group")
+	sendMessage(t, db, direct, "u1", "ÉCOLE")
+	if err := db.SetReaction(ctx, first.ID, "u2", "👍"); err != nil {
+		t.Fatalf("SetReaction() error: %v", err)
+	}
+
+	search := func(s database.MessageSearch) []string {
+		t.Helper()
+		if s.Limit == 0 {
+			s.Limit = 10
+		}
+		list, err := db.SearchMessages(ctx, s)
+		if err != nil {
+			t.Fatalf("SearchMessages(%+v) error: %v", s, err)
+		}
+		return messageContents(list)
+	}
+	for _, tc := range []struct {
+		search database.MessageSearch
+		want   []string
+	}{
+		// Newest first, only in the conversations of the user, ignoring case
+		{database.MessageSearch{UserID: "u1", Words: []string{"stai"}},
+			[]string{"stairs: stai attento", "Ciao, come STAI?"}},
+		{database.MessageSearch{UserID: "u3", Words: []string{"stai"}}, []string{"stai in the group"}},
+		{database.MessageSearch{UserID: "u1", Words: []string{"école"}}, []string{"ÉCOLE"}},
+		// All the words must appear, as whole words
+		{database.MessageSearch{UserID: "u1", Words: []string{"come", "stai"}}, []string{"Ciao, come STAI?"}},
+		{database.MessageSearch{UserID: "u1", Words: []string{"sta"}}, []string{}},
+		// Filters
+		{database.MessageSearch{UserID: "u1", Words: []string{"stai"}, SenderID: "u1"}, []string{"Ciao, come STAI?"}},
+		{database.MessageSearch{UserID: "u2", Words: []string{"stai"}, ConversationID: group},
+			[]string{"stai in the group"}},
+		{database.MessageSearch{UserID: "u1", Words: []string{"stai"}, ConversationID: group}, []string{}},
+		{database.MessageSearch{UserID: "u1", Words: []string{"stai"}, Limit: 1}, []string{"stairs: stai attento"}},
+		{database.MessageSearch{UserID: "u1", Words: []string{"stai"}, From: first.SentAt,
+			To: first.SentAt.Add(time.Millisecond)}, []string{"stairs: stai attento", "Ciao, come STAI?"}},
+		{database.MessageSearch{UserID: "u1", Words: []string{"stai"}, To: first.SentAt}, []string{}},
+	} {
+		if got := search(tc.search); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SearchMessages(%+v) = %q, want %q", tc.search, got, tc.want)
+		}
+	}
+
+	list, err := db.SearchMessages(ctx, database.MessageSearch{UserID: "u1", Words: []string{"ciao"}, Limit: 10})
+	if err != nil || len(list) != 1 || len(list[0].Reactions) != 1 || list[0].SenderName != "alice" {
+		t.Fatalf("SearchMessages() = %+v, %v; want the first message with its reaction", list, err)
+	}
+	if _, err := db.SearchMessages(ctx, database.MessageSearch{UserID: "u1", Limit: 10}); !errors.Is(err,
+		database.ErrInvalid) {
+		t.Fatalf("SearchMessages() without words: error = %v, want database.ErrInvalid", err)
+	}
+
+	// Hidden messages are excluded only for the user who hid them
+	if err := db.HideMessage(ctx, third.ID, "u1"); err != nil {
+		t.Fatalf("HideMessage() error: %v", err)
+	}
+	if got := search(database.MessageSearch{UserID: "u1", Words: []string{"attento"}}); len(got) != 0 {
+		t.Errorf("SearchMessages() of a hidden message = %q, want none", got)
+	}
+	if got := search(database.MessageSearch{UserID: "u2", Words: []string{"attento"}}); len(got) != 1 {
+		t.Errorf("SearchMessages() of a message hidden by another user = %q, want it", got)
+	}
+
+	// Edits replace the indexed words, tombstones and purged messages are not found
+	if _, err := db.EditMessage(ctx, first.ID, "hello there", nil); err != nil {
+		t.Fatalf("EditMessage() error: %v", err)
+	}
+	if got := search(database.MessageSearch{UserID: "u1", Words: []string{"ciao"}}); len(got) != 0 {
+		t.Errorf("SearchMessages() of the content before the edit = %q, want none", got)
+	}
+	if got := search(database.MessageSearch{UserID: "u1", Words: []string{"there"}}); len(got) != 1 {
+		t.Errorf("SearchMessages() of the edited content = %q, want the message", got)
+	}
+	if err := db.DeleteMessage(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteMessage() error: %v", err)
+	}
+	if got := search(database.MessageSearch{UserID: "u1", Words: []string{"there"}}); len(got) != 0 {
+		t.Errorf("SearchMessages() of a tombstone = %q, want none", got)
+	}
+	if _, err := db.PurgeMessages(ctx, first.SentAt.Add(time.Hour)); err != nil {
+		t.Fatalf("PurgeMessages() error: %v", err)
+	}
+	if got := search(database.MessageSearch{UserID: "u2", Words: []string{"grazie"}}); len(got) != 1 {
+		t.Errorf("SearchMessages() after PurgeMessages = %q, want the message", got)
+	}
+}
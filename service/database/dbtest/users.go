@@ -0,0 +1,195 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"reflect"
+	"testing"
+)
+
+// createUser creates a user with a placeholder photo, failing the test on errors.
+func createUser(t *testing.T, db database.AppDatabase, id string, name string) database.User {
+	t.Helper()
+	u, err := db.CreateUser(context.Background(), id, name, "aGVsbG8=")
+	if err != nil {
+		t.Fatalf("CreateUser(%q, %q) error: %v", id, name, err)
+	}
+	return u
+}
+
+func testCreateAndGetUser(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	created := createUser(t, db, "u1", "alice")
+	if created.ID != "u1" || created.Name != "alice" || created.Photo != "aGVsbG8=" || created.CreatedAt.IsZero() {
+		t.Fatalf("CreateUser() = %+v", created)
+	}
+
+	if u, err := db.GetUser(ctx, "u1"); err != nil || !reflect.DeepEqual(u, created) {
+		t.Fatalf("GetUser() = %+v, %v; want %+v, nil", u, err, created)
+	}
+	if u, err := db.GetUserByName(ctx, "alice"); err != nil || !reflect.DeepEqual(u, created) {
+		t.Fatalf("GetUserByName() = %+v, %v; want %+v, nil", u, err, created)
+	}
+
+	if _, err := db.GetUser(ctx, "missing"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetUser() on a missing user: error = %v, want database.ErrNotFound", err)
+	}
+	// Names are case-sensitive
+	if _, err := db.GetUserByName(ctx, "Alice"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetUserByName() with different case: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testCreateUserConflict(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	if _, err := db.CreateUser(ctx, "u1", "bob", ""); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("CreateUser() with a duplicate ID: error = %v, want database.ErrConflict", err)
+	}
+	if _, err := db.CreateUser(ctx, "u2", "alice", ""); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("CreateUser() with a duplicate name: error = %v, want database.ErrConflict", err)
+	}
+}
+
+func testSetUserName(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+	createUser(t, db, "u2", "bob")
+
+	if err := db.SetUserName(ctx, "u1", "alice2"); err != nil {
+		t.Fatalf("SetUserName() error: %v", err)
+	}
+	if u, err := db.GetUser(ctx, "u1"); err != nil || u.Name != "alice2" {
+		t.Fatalf("GetUser() after SetUserName() = %+v, %v", u, err)
+	}
+	// Setting the current name again is not a conflict
+	if err := db.SetUserName(ctx, "u1", "alice2"); err != nil {
+		t.Fatalf("SetUserName() with the current name: error %v", err)
+	}
+	if err := db.SetUserName(ctx, "u1", "bob"); !errors.Is(err, database.ErrConflict) {
+		t.Fatalf("SetUserName() with the name of another user: error = %v, want database.ErrConflict", err)
+	}
+	if err := db.SetUserName(ctx, "missing", "carol"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("SetUserName() on a missing user: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testSetUserPhoto(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+
+	if err := db.SetUserPhoto(ctx, "u1", "d29ybGQ="); err != nil {
+		t.Fatalf("SetUserPhoto() error: %v", err)
+	}
+	if u, err := db.GetUser(ctx, "u1"); err != nil || u.Photo != "d29ybGQ=" {
+		t.Fatalf("GetUser() after SetUserPhoto() = %+v, %v", u, err)
+	}
+	if err := db.SetUserPhoto(ctx, "missing", ""); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("SetUserPhoto() on a missing user: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func userNames(users []database.User) []string {
+	var names = []string{}
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	return names
+}
+
+func testSearchUsers(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "bob_smith")
+	createUser(t, db, "u2", "Bobby")
+	createUser(t, db, "u3", "alice")
+	createUser(t, db, "u4", "xbob")
+	if err := db.BlockUser(ctx, "u3", "u2"); err != nil {
+		t.Fatalf("BlockUser() error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		query  string
+		viewer string
+		limit  int
+		want   []string
+	}{
+		// Sorted by name, uppercase first
+		{"bob", "", 10, []string{"Bobby", "bob_smith", "xbob"}},
+		{"BOB", "", 2, []string{"Bobby", "bob_smith"}},
+		// `_` is not a wildcard
+		{"bob_", "", 10, []string{"bob_smith"}},
+		{"_", "", 10, []string{"bob_smith"}},
+		{"carol", "", 10, []string{}},
+		// Blocked users are excluded before the limit is applied
+		{"bob", "u3", 2, []string{"bob_smith", "xbob"}},
+		{"bob", "u2", 10, []string{"Bobby", "bob_smith", "xbob"}},
+	} {
+		users, err := db.SearchUsers(ctx, tc.query, tc.viewer, tc.limit)
+		if err != nil {
+			t.Fatalf("SearchUsers(%q, %q) error: %v", tc.query, tc.viewer, err)
+		}
+		if got := userNames(users); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SearchUsers(%q, %q, %d) = %v, want %v", tc.query, tc.viewer, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func testSessions(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+
+	if _, err := db.GetSession(ctx, "u1"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetSession() before login: error = %v, want database.ErrNotFound", err)
+	}
+	created, err := db.CreateSession(ctx, "u1")
+	if err != nil {
+		t.Fatalf("CreateSession() error: %v", err)
+	}
+	if s, err := db.GetSession(ctx, "u1"); err != nil || !reflect.DeepEqual(s, created) {
+		t.Fatalf("GetSession() = %+v, %v; want %+v, nil", s, err, created)
+	}
+	// A new login replaces the session
+	if _, err := db.CreateSession(ctx, "u1"); err != nil {
+		t.Fatalf("second CreateSession() error: %v", err)
+	}
+	if _, err := db.CreateSession(ctx, "missing"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("CreateSession() for a missing user: error = %v, want database.ErrNotFound", err)
+	}
+
+	if err := db.DeleteSession(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteSession() error: %v", err)
+	}
+	if _, err := db.GetSession(ctx, "u1"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetSession() after DeleteSession(): error = %v, want database.ErrNotFound", err)
+	}
+	if err := db.DeleteSession(ctx, "u1"); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("DeleteSession() twice: error = %v, want database.ErrNotFound", err)
+	}
+}
+
+func testSetUserBanned(t *testing.T, db database.AppDatabase) {
+	ctx := context.Background()
+	createUser(t, db, "u1", "alice")
+
+	if u, err := db.GetUser(ctx, "u1"); err != nil || !u.BannedAt.IsZero() {
+		t.Fatalf("GetUser() of a new user = %+v, %v; want not banned", u, err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.SetUserBanned(ctx, "u1", true); err != nil {
+			t.Fatalf("SetUserBanned(true) error: %v", err)
+		}
+		if u, err := db.GetUser(ctx, "u1"); err != nil || u.BannedAt.IsZero() {
+			t.Fatalf("GetUser() after SetUserBanned(true) = %+v, %v; want banned", u, err)
+		}
+	}
+	if err := db.SetUserBanned(ctx, "u1", false); err != nil {
+		t.Fatalf("SetUserBanned(false) error: %v", err)
+	}
+	if u, err := db.GetUser(ctx, "u1"); err != nil || !u.BannedAt.IsZero() {
+		t.Fatalf("GetUser() after SetUserBanned(false) = %+v, %v; want not banned", u, err)
+	}
+	if err := db.SetUserBanned(ctx, "missing", true); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("SetUserBanned() for a missing user: error = %v, want database.ErrNotFound", err)
+	}
+}
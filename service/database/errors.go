@@ -0,0 +1,46 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Errors returned by AppDatabase methods. Check them with errors.Is: the returned error may contain more details.
+// Any other error is an unexpected failure (I/O error, canceled context, etc.).
+var (
+	// ErrNotFound is returned when the requested entity (or an entity it refers to) does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is returned when the change conflicts with existing data (e.g., a duplicate unique value)
+	ErrConflict = errors.New("conflict")
+
+	// ErrInvalid is returned when a value is not valid for the entity (e.g., a required value is missing)
+	ErrInvalid = errors.New("invalid value")
+)
+
+// translateError converts errors from database/sql and SQLite to the errors of this package, so that callers do not
+// depend on the database driver. Other errors are returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	} else if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey, sqlite3.ErrConstraintRowID:
+		return fmt.Errorf("%w: %s", ErrConflict, sqliteErr.Error())
+	case sqlite3.ErrConstraintForeignKey:
+		return fmt.Errorf("%w: %s", ErrNotFound, sqliteErr.Error())
+	case sqlite3.ErrConstraintNotNull, sqlite3.ErrConstraintCheck:
+		return fmt.Errorf("%w: %s", ErrInvalid, sqliteErr.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTranslateError(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	for _, query := range []string{
+		`CREATE TABLE parent (id INTEGER NOT NULL PRIMARY KEY)`,
+		`CREATE TABLE child (
+			id INTEGER NOT NULL PRIMARY KEY,
+			parent_id INTEGER REFERENCES parent (id),
+			name TEXT NOT NULL UNIQUE,
+			age INTEGER CHECK (age >= 0)
+		)`,
+		`INSERT INTO parent (id) VALUES (1)`,
+		`INSERT INTO child (id, parent_id, name) VALUES (1, 1, 'first')`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		query string
+		want  error
+	}{
+		{"Unique", `INSERT INTO child (id, name) VALUES (2, 'first')`, ErrConflict},
+		{"PrimaryKey", `INSERT INTO child (id, name) VALUES (1, 'second')`, ErrConflict},
+		{"ForeignKey", `INSERT INTO child (id, parent_id, name) VALUES (2, 42, 'second')`, ErrNotFound},
+		{"NotNull", `INSERT INTO child (id, name) VALUES (2, NULL)`, ErrInvalid},
+		{"Check", `INSERT INTO child (id, name, age) VALUES (2, 'second', -1)`, ErrInvalid},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := db.Exec(tc.query)
+			if err == nil {
+				t.Fatal("query succeeded")
+			}
+			if got := translateError(err); !errors.Is(got, tc.want) {
+				t.Fatalf("translateError(%v) = %v, want %v", err, got, tc.want)
+			}
+		})
+	}
+
+	if err := translateError(db.QueryRow(`SELECT id FROM child WHERE id = 42`).Scan(new(int))); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("translateError(sql.ErrNoRows) = %v, want ErrNotFound", err)
+	}
+	if err := translateError(nil); err != nil {
+		t.Fatalf("translateError(nil) = %v, want nil", err)
+	}
+}
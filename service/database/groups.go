@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+func (db *appdbimpl) CreateGroup(ctx context.Context, name string, photo string, memberIDs []string) (int64, error) {
+	var id int64
+	err := db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		now := toUnixMilli(tdb.now())
+		res, err := tdb.w.ExecContext(ctx, `INSERT INTO conversations (is_group, name, photo, direct_key, created_at)
+			VALUES (1, ?, ?, NULL, ?)`, name, photo, now)
+		if err != nil {
+			return translateError(err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		for i, uid := range memberIDs {
+			if _, err := tdb.GetUser(ctx, uid); err != nil {
+				return fmt.Errorf("user %s: %w", uid, err)
+			}
+			role := RoleMember
+			if i == 0 {
+				role = RoleOwner
+			}
+			if err := tdb.insertMember(ctx, id, uid, role, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return id, err
+}
+
+func (db *appdbimpl) SetGroupName(ctx context.Context, id int64, name string) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE conversations SET name = ? WHERE id = ? AND is_group = 1`, name, id)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SetGroupPhoto(ctx context.Context, id int64, photo string) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE conversations SET photo = ? WHERE id = ? AND is_group = 1`, photo, id)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SetGroupPermissions(ctx context.Context, id int64, perms GroupPermissions) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE conversations SET perm_rename = ?, perm_add_members = ?,
+		perm_remove_members = ? WHERE id = ? AND is_group = 1`, perms.Rename, perms.AddMembers, perms.RemoveMembers, id)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SetMemberRole(ctx context.Context, conversationID int64, userID string, role string) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE members SET role = ? WHERE conversation_id = ? AND user_id = ?`, role,
+		conversationID, userID)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) AddMember(ctx context.Context, conversationID int64, userID string) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		var exists bool
+		err := tdb.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM conversations WHERE id = ? AND is_group = 1)`,
+			conversationID).Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("group %d: %w", conversationID, ErrNotFound)
+		}
+		if _, err := tdb.GetUser(ctx, userID); err != nil {
+			return fmt.Errorf("user %s: %w", userID, err)
+		}
+		return tdb.insertMember(ctx, conversationID, userID, RoleMember, toUnixMilli(tdb.now()))
+	})
+}
+
+func (db *appdbimpl) RemoveMember(ctx context.Context, conversationID int64, userID string) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		res, err := tdb.w.ExecContext(ctx, `DELETE FROM members WHERE conversation_id = ? AND user_id = ?`,
+			conversationID, userID)
+		if err := checkAffected(res, err); err != nil {
+			return err
+		}
+		_, err = tdb.w.ExecContext(ctx, `DELETE FROM conversation_settings WHERE conversation_id = ? AND user_id = ?`,
+			conversationID, userID)
+		return err
+	})
+}
+
+func (db *appdbimpl) DeleteConversation(ctx context.Context, id int64) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		// Foreign keys may be disabled, so the rows referring to the conversation are deleted explicitly
+		for _, query := range []string{
+			`DELETE FROM reactions WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)`,
+			`DELETE FROM message_revisions WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)`,
+			`DELETE FROM hidden_messages WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)`,
+			`DELETE FROM mentions WHERE message_id IN (SELECT id FROM messages WHERE conversation_id = ?)`,
+			`DELETE FROM messages WHERE conversation_id = ?`,
+			`DELETE FROM members WHERE conversation_id = ?`,
+			`DELETE FROM conversation_settings WHERE conversation_id = ?`,
+			`DELETE FROM group_invites WHERE conversation_id = ?`,
+		} {
+			if _, err := tdb.w.ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+		res, err := tdb.w.ExecContext(ctx, `DELETE FROM conversations WHERE id = ?`, id)
+		return checkAffected(res, err)
+	})
+}
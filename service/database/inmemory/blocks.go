@@ -0,0 +1,76 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sort"
+)
+
+func (db *memdb) BlockUser(ctx context.Context, blockerID string, blockedID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.BlockUser(ctx, blockerID, blockedID)
+	})
+}
+
+func (db *memdb) UnblockUser(ctx context.Context, blockerID string, blockedID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.UnblockUser(ctx, blockerID, blockedID)
+	})
+}
+
+func (db *memdb) IsBlocked(ctx context.Context, blockerID string, blockedID string) (blocked bool, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		blocked, err = tx.IsBlocked(ctx, blockerID, blockedID)
+		return err
+	})
+	return blocked, err
+}
+
+func (db *memdb) ListBlockedUsers(ctx context.Context, blockerID string) (users []database.User, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		users, err = tx.ListBlockedUsers(ctx, blockerID)
+		return err
+	})
+	return users, err
+}
+
+func (tx *memtx) BlockUser(ctx context.Context, blockerID string, blockedID string) error {
+	for _, id := range []string{blockerID, blockedID} {
+		if _, err := tx.GetUser(ctx, id); err != nil {
+			return fmt.Errorf("user %s: %w", id, err)
+		}
+	}
+	if tx.data.blocks[blockerID] == nil {
+		tx.data.blocks[blockerID] = map[string]bool{}
+	}
+	tx.data.blocks[blockerID][blockedID] = true
+	return nil
+}
+
+func (tx *memtx) UnblockUser(ctx context.Context, blockerID string, blockedID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	delete(tx.data.blocks[blockerID], blockedID)
+	return nil
+}
+
+func (tx *memtx) IsBlocked(ctx context.Context, blockerID string, blockedID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return tx.data.blocks[blockerID][blockedID], nil
+}
+
+func (tx *memtx) ListBlockedUsers(ctx context.Context, blockerID string) ([]database.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var users = []database.User{}
+	for id := range tx.data.blocks[blockerID] {
+		users = append(users, tx.data.users[id])
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
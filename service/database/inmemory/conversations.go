@@ -0,0 +1,263 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sort"
+	"time"
+)
+
+func (db *memdb) CreateDirectConversation(ctx context.Context, userID string, otherID string) (id int64, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		id, err = tx.CreateDirectConversation(ctx, userID, otherID)
+		return err
+	})
+	return id, err
+}
+
+func (db *memdb) FindDirectConversation(ctx context.Context, userID string, otherID string) (id int64, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		id, err = tx.FindDirectConversation(ctx, userID, otherID)
+		return err
+	})
+	return id, err
+}
+
+func (db *memdb) GetConversation(ctx context.Context, id int64) (c database.Conversation, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		c, err = tx.GetConversation(ctx, id)
+		return err
+	})
+	return c, err
+}
+
+func (db *memdb) ListUserConversations(ctx context.Context, userID string) (ids []int64, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		ids, err = tx.ListUserConversations(ctx, userID)
+		return err
+	})
+	return ids, err
+}
+
+func (db *memdb) ListConversations(ctx context.Context, limit int) (ids []int64, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		ids, err = tx.ListConversations(ctx, limit)
+		return err
+	})
+	return ids, err
+}
+
+func (db *memdb) MarkDelivered(ctx context.Context, conversationID int64, userID string, messageID int64) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.MarkDelivered(ctx, conversationID, userID, messageID)
+	})
+}
+
+func (db *memdb) MarkRead(ctx context.Context, conversationID int64, userID string, messageID int64) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.MarkRead(ctx, conversationID, userID, messageID)
+	})
+}
+
+func (db *memdb) SetConversationSettings(ctx context.Context, conversationID int64, userID string,
+	s database.ConversationSettings) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetConversationSettings(ctx, conversationID, userID, s)
+	})
+}
+
+func (db *memdb) UnarchiveConversation(ctx context.Context, conversationID int64, mentionedIDs []string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.UnarchiveConversation(ctx, conversationID, mentionedIDs)
+	})
+}
+
+func (tx *memtx) CreateDirectConversation(ctx context.Context, userID string, otherID string) (int64, error) {
+	for _, uid := range []string{userID, otherID} {
+		if _, err := tx.GetUser(ctx, uid); err != nil {
+			return 0, fmt.Errorf("user %s: %w", uid, err)
+		}
+	}
+	if _, err := tx.FindDirectConversation(ctx, userID, otherID); err == nil {
+		return 0, fmt.Errorf("%w: conversation already exists", database.ErrConflict)
+	}
+
+	id := tx.data.newConversation(false, "", "", tx.db.now())
+	tx.data.direct[directKey(userID, otherID)] = id
+	for _, uid := range []string{userID, otherID} {
+		tx.data.addMember(id, uid, database.RoleMember, tx.db.now())
+	}
+	return id, nil
+}
+
+// directKey returns the key of the conversation between two users in memdata.direct, which is the same regardless of
+// the order of the users.
+func directKey(userID string, otherID string) string {
+	if otherID < userID {
+		userID, otherID = otherID, userID
+	}
+	return userID + ":" + otherID
+}
+
+func (tx *memtx) FindDirectConversation(ctx context.Context, userID string, otherID string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	id, ok := tx.data.direct[directKey(userID, otherID)]
+	if !ok {
+		return 0, database.ErrNotFound
+	}
+	return id, nil
+}
+
+func (tx *memtx) GetConversation(ctx context.Context, id int64) (database.Conversation, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Conversation{}, err
+	}
+	c, ok := tx.data.conversations[id]
+	if !ok {
+		return database.Conversation{}, database.ErrNotFound
+	}
+
+	c.Members = []database.Member{}
+	for _, m := range tx.data.members[id] {
+		m.Name = tx.data.users[m.UserID].Name
+		c.Members = append(c.Members, m)
+	}
+	sort.Slice(c.Members, func(i, j int) bool {
+		a, b := c.Members[i], c.Members[j]
+		if !a.JoinedAt.Equal(b.JoinedAt) {
+			return a.JoinedAt.Before(b.JoinedAt)
+		}
+		return a.UserID < b.UserID
+	})
+	return c, nil
+}
+
+func (tx *memtx) ListUserConversations(ctx context.Context, userID string) ([]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var ids = []int64{}
+	for id, members := range tx.data.members {
+		if _, ok := members[userID]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+func (tx *memtx) ListConversations(ctx context.Context, limit int) ([]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var ids = []int64{}
+	for id := range tx.data.conversations {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (tx *memtx) MarkDelivered(ctx context.Context, conversationID int64, userID string, messageID int64) error {
+	return tx.updateMember(ctx, conversationID, userID, func(m *database.Member) {
+		m.LastDeliveredID = maxID(m.LastDeliveredID, messageID)
+	})
+}
+
+func (tx *memtx) MarkRead(ctx context.Context, conversationID int64, userID string, messageID int64) error {
+	return tx.updateMember(ctx, conversationID, userID, func(m *database.Member) {
+		m.LastDeliveredID = maxID(m.LastDeliveredID, messageID)
+		m.LastReadID = maxID(m.LastReadID, messageID)
+	})
+}
+
+func (tx *memtx) SetConversationSettings(ctx context.Context, conversationID int64, userID string,
+	s database.ConversationSettings) error {
+	// Times are stored with the precision of SQLite timestamps
+	for _, t := range []*time.Time{&s.MutedUntil, &s.PinnedAt} {
+		if !t.IsZero() {
+			*t = time.UnixMilli(t.UnixMilli()).UTC()
+		}
+	}
+	return tx.updateMember(ctx, conversationID, userID, func(m *database.Member) {
+		m.Settings = s
+	})
+}
+
+func (tx *memtx) UnarchiveConversation(ctx context.Context, conversationID int64, mentionedIDs []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var mentioned = map[string]bool{}
+	for _, id := range mentionedIDs {
+		mentioned[id] = true
+	}
+	for uid, m := range tx.data.members[conversationID] {
+		if m.Settings.Archived && (!m.Settings.Muted(tx.db.now()) || mentioned[uid]) {
+			m.Settings.Archived = false
+			tx.data.members[conversationID][uid] = m
+		}
+	}
+	return nil
+}
+
+// updateMember applies fn to a member, or returns database.ErrNotFound if the user is not a member.
+func (tx *memtx) updateMember(ctx context.Context, conversationID int64, userID string, fn func(m *database.Member)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m, ok := tx.data.members[conversationID][userID]
+	if !ok {
+		return database.ErrNotFound
+	}
+	fn(&m)
+	tx.data.members[conversationID][userID] = m
+	return nil
+}
+
+func maxID(a int64, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// newConversation stores a new conversation without members, and returns its ID.
+func (d *memdata) newConversation(isGroup bool, name string, photo string, now time.Time) int64 {
+	d.lastConversationID++
+	id := d.lastConversationID
+	d.conversations[id] = database.Conversation{ID: id, IsGroup: isGroup, Name: name, Photo: photo, CreatedAt: now,
+		Permissions: defaultPermissions}
+	d.members[id] = map[string]database.Member{}
+	return id
+}
+
+// defaultPermissions are the permissions of a new conversation, like the column defaults of the SQLite database.
+var defaultPermissions = database.GroupPermissions{
+	Rename:        database.PermissionMembers,
+	AddMembers:    database.PermissionMembers,
+	RemoveMembers: database.PermissionAdmins,
+}
+
+// addMember adds a member to a conversation. Messages sent before joining count as delivered and read.
+func (d *memdata) addMember(conversationID int64, userID string, role string, now time.Time) {
+	var latest int64
+	for _, m := range d.messages {
+		if m.ConversationID == conversationID {
+			latest = maxID(latest, m.ID)
+		}
+	}
+	d.members[conversationID][userID] = database.Member{
+		UserID:          userID,
+		JoinedAt:        now,
+		Role:            role,
+		LastDeliveredID: latest,
+		LastReadID:      latest,
+	}
+}
@@ -0,0 +1,182 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+)
+
+func (db *memdb) CreateGroup(ctx context.Context, name string, photo string, memberIDs []string) (id int64, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		id, err = tx.CreateGroup(ctx, name, photo, memberIDs)
+		return err
+	})
+	return id, err
+}
+
+func (db *memdb) SetGroupName(ctx context.Context, id int64, name string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetGroupName(ctx, id, name)
+	})
+}
+
+func (db *memdb) SetGroupPhoto(ctx context.Context, id int64, photo string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetGroupPhoto(ctx, id, photo)
+	})
+}
+
+func (db *memdb) SetGroupPermissions(ctx context.Context, id int64, perms database.GroupPermissions) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetGroupPermissions(ctx, id, perms)
+	})
+}
+
+func (db *memdb) SetMemberRole(ctx context.Context, conversationID int64, userID string, role string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetMemberRole(ctx, conversationID, userID, role)
+	})
+}
+
+func (db *memdb) AddMember(ctx context.Context, conversationID int64, userID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.AddMember(ctx, conversationID, userID)
+	})
+}
+
+func (db *memdb) RemoveMember(ctx context.Context, conversationID int64, userID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.RemoveMember(ctx, conversationID, userID)
+	})
+}
+
+func (db *memdb) DeleteConversation(ctx context.Context, id int64) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.DeleteConversation(ctx, id)
+	})
+}
+
+func (tx *memtx) CreateGroup(ctx context.Context, name string, photo string, memberIDs []string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	id := tx.data.newConversation(true, name, photo, tx.db.now())
+	for i, uid := range memberIDs {
+		if _, err := tx.GetUser(ctx, uid); err != nil {
+			return 0, fmt.Errorf("user %s: %w", uid, err)
+		}
+		if _, ok := tx.data.members[id][uid]; ok {
+			return 0, fmt.Errorf("%w: user %s is already a member", database.ErrConflict, uid)
+		}
+		role := database.RoleMember
+		if i == 0 {
+			role = database.RoleOwner
+		}
+		tx.data.addMember(id, uid, role, tx.db.now())
+	}
+	return id, nil
+}
+
+func (tx *memtx) SetGroupName(ctx context.Context, id int64, name string) error {
+	return tx.updateGroup(ctx, id, func(c *database.Conversation) { c.Name = name })
+}
+
+func (tx *memtx) SetGroupPhoto(ctx context.Context, id int64, photo string) error {
+	return tx.updateGroup(ctx, id, func(c *database.Conversation) { c.Photo = photo })
+}
+
+// updateGroup applies fn to a group, or returns database.ErrNotFound if the group does not exist.
+func (tx *memtx) updateGroup(ctx context.Context, id int64, fn func(c *database.Conversation)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c, ok := tx.data.conversations[id]
+	if !ok || !c.IsGroup {
+		return database.ErrNotFound
+	}
+	fn(&c)
+	tx.data.conversations[id] = c
+	return nil
+}
+
+func (tx *memtx) SetGroupPermissions(ctx context.Context, id int64, perms database.GroupPermissions) error {
+	for _, p := range []string{perms.Rename, perms.AddMembers, perms.RemoveMembers} {
+		if p != database.PermissionAdmins && p != database.PermissionMembers {
+			return fmt.Errorf("%w: permission %q", database.ErrInvalid, p)
+		}
+	}
+	return tx.updateGroup(ctx, id, func(c *database.Conversation) { c.Permissions = perms })
+}
+
+func (tx *memtx) SetMemberRole(ctx context.Context, conversationID int64, userID string, role string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m, ok := tx.data.members[conversationID][userID]
+	if !ok {
+		return database.ErrNotFound
+	}
+	switch role {
+	case database.RoleOwner, database.RoleAdmin, database.RoleMember:
+	default:
+		return fmt.Errorf("%w: role %q", database.ErrInvalid, role)
+	}
+	m.Role = role
+	tx.data.members[conversationID][userID] = m
+	return nil
+}
+
+func (tx *memtx) AddMember(ctx context.Context, conversationID int64, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c, ok := tx.data.conversations[conversationID]; !ok || !c.IsGroup {
+		return fmt.Errorf("group %d: %w", conversationID, database.ErrNotFound)
+	}
+	if _, err := tx.GetUser(ctx, userID); err != nil {
+		return fmt.Errorf("user %s: %w", userID, err)
+	}
+	if _, ok := tx.data.members[conversationID][userID]; ok {
+		return fmt.Errorf("%w: user %s is already a member", database.ErrConflict, userID)
+	}
+	tx.data.addMember(conversationID, userID, database.RoleMember, tx.db.now())
+	return nil
+}
+
+func (tx *memtx) RemoveMember(ctx context.Context, conversationID int64, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := tx.data.members[conversationID][userID]; !ok {
+		return database.ErrNotFound
+	}
+	delete(tx.data.members[conversationID], userID)
+	return nil
+}
+
+func (tx *memtx) DeleteConversation(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := tx.data.conversations[id]; !ok {
+		return database.ErrNotFound
+	}
+	for mid, m := range tx.data.messages {
+		if m.ConversationID == id {
+			tx.data.deleteMessage(mid)
+		}
+	}
+	for key, cid := range tx.data.direct {
+		if cid == id {
+			delete(tx.data.direct, key)
+		}
+	}
+	for token, inv := range tx.data.invites {
+		if inv.ConversationID == id {
+			delete(tx.data.invites, token)
+		}
+	}
+	delete(tx.data.members, id)
+	delete(tx.data.conversations, id)
+	return nil
+}
@@ -0,0 +1,295 @@
+/*
+Package inmemory contains an implementation of database.AppDatabase that keeps all data in memory. It's meant for
+tests, where a real SQLite file is not needed: data is lost when the instance is garbage collected.
+
+The semantics (conflicts, not found errors, ordering, transactions) must be identical to the SQLite implementation in
+the parent package. The conformance suite in the `dbtest` package checks both implementations.
+*/
+package inmemory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"sync"
+	"time"
+)
+
+type memdb struct {
+	// txmu serializes writes (including transactions), like the single writer connection of SQLite
+	txmu sync.Mutex
+
+	// mu protects data. Readers always see the last committed data
+	mu   sync.RWMutex
+	data *memdata
+
+	clock globaltime.Clock
+}
+
+// memdata contains all tables. Transactions work on a copy, which replaces the original on commit.
+type memdata struct {
+	// names is the content of `example_table`, by ID
+	names map[int]string
+
+	// users and sessions are indexed by user ID
+	users    map[string]database.User
+	sessions map[string]database.Session
+
+	// conversations are stored without members, which are indexed by conversation and user ID
+	conversations map[int64]database.Conversation
+	members       map[int64]map[string]database.Member
+
+	// direct maps the key of each one-to-one conversation (see directKey) to its ID
+	direct map[string]int64
+
+	// messages are stored without reactions and sender name. Reactions are indexed by message and user ID
+	messages  map[int64]database.Message
+	reactions map[int64]map[string]database.Reaction
+
+	// revisions contains the previous contents of edited messages, oldest first, by message ID
+	revisions map[int64][]database.Revision
+
+	// mentions contains the mentions of each message, sorted by offset, by message ID
+	mentions map[int64][]database.Mention
+
+	// hidden contains the users that have hidden each message, by message and user ID
+	hidden map[int64]map[string]bool
+
+	// invites are indexed by token
+	invites map[string]database.Invite
+
+	// blocks contains the users blocked by each user, by blocker and blocked user ID
+	blocks map[string]map[string]bool
+
+	// lastConversationID and lastMessageID are never reused, like AUTOINCREMENT columns
+	lastConversationID int64
+	lastMessageID      int64
+}
+
+// memtx is the AppDatabase passed to the function in WithTx. It's not safe for concurrent use, like *sql.Tx.
+type memtx struct {
+	db   *memdb
+	data *memdata
+}
+
+// New returns a new, empty, in-memory AppDatabase using `clock` as source of time.
+// `clock` is required - an error will be returned if `clock` is `nil`.
+func New(clock globaltime.Clock) (database.AppDatabase, error) {
+	if clock == nil {
+		return nil, errors.New("clock is required when building a AppDatabase")
+	}
+	return &memdb{
+		clock: clock,
+		data:  newMemdata(),
+	}, nil
+}
+
+// view runs fn on the committed data. fn must not change the data.
+func (db *memdb) view(ctx context.Context, fn func(tx *memtx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(&memtx{db: db, data: db.data})
+}
+
+// update runs fn in a transaction, so that a failed method leaves no partial changes, like a single SQL statement.
+func (db *memdb) update(ctx context.Context, fn func(tx *memtx) error) error {
+	return db.WithTx(ctx, func(tx database.AppDatabase) error {
+		return fn(tx.(*memtx))
+	})
+}
+
+// now returns the current time of the clock, with the precision of the timestamps stored by SQLite.
+func (db *memdb) now() time.Time {
+	return time.UnixMilli(db.clock.Now().UnixMilli()).UTC()
+}
+
+// GetName returns the name with ID 1, or database.ErrNotFound if it has not been set.
+func (db *memdb) GetName(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.data.getName()
+}
+
+// SetName inserts the name with ID 1. Like the SQLite implementation, it fails if the name has already been set.
+func (db *memdb) SetName(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	db.txmu.Lock()
+	defer db.txmu.Unlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.data.setName(name)
+}
+
+func (db *memdb) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// SchemaVersion always returns the latest version, as there is no schema to migrate.
+func (db *memdb) SchemaVersion(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return database.LatestSchemaVersion(), nil
+}
+
+// WithTx runs fn on a copy of the data, which replaces the current data only if fn returns nil. The in-memory
+// database is never busy, so there are no retries.
+func (db *memdb) WithTx(ctx context.Context, fn func(tx database.AppDatabase) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	db.txmu.Lock()
+	defer db.txmu.Unlock()
+
+	db.mu.RLock()
+	tx := &memtx{db: db, data: db.data.clone()}
+	db.mu.RUnlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	// Like a commit on a canceled context in database/sql
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.data = tx.data
+	db.mu.Unlock()
+	return nil
+}
+
+func (tx *memtx) GetName(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return tx.data.getName()
+}
+
+func (tx *memtx) SetName(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return tx.data.setName(name)
+}
+
+func (tx *memtx) Ping(ctx context.Context) error {
+	return tx.db.Ping(ctx)
+}
+
+func (tx *memtx) SchemaVersion(ctx context.Context) (int, error) {
+	return tx.db.SchemaVersion(ctx)
+}
+
+// WithTx runs the nested fn in the same transaction.
+func (tx *memtx) WithTx(ctx context.Context, fn func(tx database.AppDatabase) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(tx)
+}
+
+func newMemdata() *memdata {
+	return &memdata{
+		names:    map[int]string{},
+		users:    map[string]database.User{},
+		sessions: map[string]database.Session{},
+
+		conversations: map[int64]database.Conversation{},
+		members:       map[int64]map[string]database.Member{},
+		direct:        map[string]int64{},
+		messages:      map[int64]database.Message{},
+		reactions:     map[int64]map[string]database.Reaction{},
+		revisions:     map[int64][]database.Revision{},
+		mentions:      map[int64][]database.Mention{},
+		hidden:        map[int64]map[string]bool{},
+		invites:       map[string]database.Invite{},
+		blocks:        map[string]map[string]bool{},
+	}
+}
+
+// clone returns a copy of all tables. Rows are stored as values without slices, so copying the maps is enough, except
+// for the revisions and the mentions: the slices are copied, so changing them doesn't change the original.
+func (d *memdata) clone() *memdata {
+	c := newMemdata()
+	for id, name := range d.names {
+		c.names[id] = name
+	}
+	for id, u := range d.users {
+		c.users[id] = u
+	}
+	for id, s := range d.sessions {
+		c.sessions[id] = s
+	}
+	for id, conv := range d.conversations {
+		c.conversations[id] = conv
+	}
+	for id, members := range d.members {
+		c.members[id] = make(map[string]database.Member, len(members))
+		for uid, m := range members {
+			c.members[id][uid] = m
+		}
+	}
+	for key, id := range d.direct {
+		c.direct[key] = id
+	}
+	for id, m := range d.messages {
+		c.messages[id] = m
+	}
+	for id, reactions := range d.reactions {
+		c.reactions[id] = make(map[string]database.Reaction, len(reactions))
+		for uid, r := range reactions {
+			c.reactions[id][uid] = r
+		}
+	}
+	for id, revisions := range d.revisions {
+		c.revisions[id] = append([]database.Revision(nil), revisions...)
+	}
+	for id, mentions := range d.mentions {
+		c.mentions[id] = append([]database.Mention(nil), mentions...)
+	}
+	for id, users := range d.hidden {
+		c.hidden[id] = make(map[string]bool, len(users))
+		for uid := range users {
+			c.hidden[id][uid] = true
+		}
+	}
+	for token, inv := range d.invites {
+		c.invites[token] = inv
+	}
+	for id, users := range d.blocks {
+		c.blocks[id] = make(map[string]bool, len(users))
+		for uid := range users {
+			c.blocks[id][uid] = true
+		}
+	}
+	c.lastConversationID = d.lastConversationID
+	c.lastMessageID = d.lastMessageID
+	return c
+}
+
+func (d *memdata) getName() (string, error) {
+	name, ok := d.names[1]
+	if !ok {
+		return "", database.ErrNotFound
+	}
+	return name, nil
+}
+
+func (d *memdata) setName(name string) error {
+	if _, ok := d.names[1]; ok {
+		return fmt.Errorf("%w: name already set", database.ErrConflict)
+	}
+	d.names[1] = name
+	return nil
+}
@@ -0,0 +1,129 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sort"
+	"time"
+)
+
+func (db *memdb) CreateInvite(ctx context.Context, ni database.NewInvite) (inv database.Invite, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		inv, err = tx.CreateInvite(ctx, ni)
+		return err
+	})
+	return inv, err
+}
+
+func (db *memdb) GetInvite(ctx context.Context, token string) (inv database.Invite, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		inv, err = tx.GetInvite(ctx, token)
+		return err
+	})
+	return inv, err
+}
+
+func (db *memdb) ListInvites(ctx context.Context, conversationID int64) (list []database.Invite, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		list, err = tx.ListInvites(ctx, conversationID)
+		return err
+	})
+	return list, err
+}
+
+func (db *memdb) UseInvite(ctx context.Context, token string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.UseInvite(ctx, token)
+	})
+}
+
+func (db *memdb) DeleteInvite(ctx context.Context, conversationID int64, token string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.DeleteInvite(ctx, conversationID, token)
+	})
+}
+
+func (tx *memtx) CreateInvite(ctx context.Context, ni database.NewInvite) (database.Invite, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Invite{}, err
+	}
+	if c, ok := tx.data.conversations[ni.ConversationID]; !ok || !c.IsGroup {
+		return database.Invite{}, fmt.Errorf("group %d: %w", ni.ConversationID, database.ErrNotFound)
+	}
+	if _, err := tx.GetUser(ctx, ni.CreatedBy); err != nil {
+		return database.Invite{}, fmt.Errorf("user %s: %w", ni.CreatedBy, err)
+	}
+	if _, ok := tx.data.invites[ni.Token]; ok {
+		return database.Invite{}, fmt.Errorf("%w: token already used", database.ErrConflict)
+	} else if ni.MaxUses < 0 {
+		return database.Invite{}, fmt.Errorf("%w: max uses %d", database.ErrInvalid, ni.MaxUses)
+	}
+
+	inv := database.Invite{
+		Token:          ni.Token,
+		ConversationID: ni.ConversationID,
+		CreatedBy:      ni.CreatedBy,
+		CreatedAt:      tx.db.now(),
+		MaxUses:        ni.MaxUses,
+	}
+	if !ni.ExpiresAt.IsZero() {
+		inv.ExpiresAt = time.UnixMilli(ni.ExpiresAt.UnixMilli()).UTC()
+	}
+	tx.data.invites[inv.Token] = inv
+	return inv, nil
+}
+
+func (tx *memtx) GetInvite(ctx context.Context, token string) (database.Invite, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Invite{}, err
+	}
+	inv, ok := tx.data.invites[token]
+	if !ok {
+		return database.Invite{}, database.ErrNotFound
+	}
+	return inv, nil
+}
+
+func (tx *memtx) ListInvites(ctx context.Context, conversationID int64) ([]database.Invite, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var list = []database.Invite{}
+	for _, inv := range tx.data.invites {
+		if inv.ConversationID == conversationID {
+			list = append(list, inv)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.Token < b.Token
+	})
+	return list, nil
+}
+
+func (tx *memtx) UseInvite(ctx context.Context, token string) error {
+	inv, err := tx.GetInvite(ctx, token)
+	if err != nil {
+		return err
+	} else if inv.MaxUses != 0 && inv.Uses >= inv.MaxUses {
+		return fmt.Errorf("%w: invite used up", database.ErrConflict)
+	}
+	inv.Uses++
+	tx.data.invites[token] = inv
+	return nil
+}
+
+func (tx *memtx) DeleteInvite(ctx context.Context, conversationID int64, token string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if inv, ok := tx.data.invites[token]; !ok || inv.ConversationID != conversationID {
+		return database.ErrNotFound
+	}
+	delete(tx.data.invites, token)
+	return nil
+}
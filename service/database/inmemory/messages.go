@@ -0,0 +1,348 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sort"
+	"time"
+)
+
+func (db *memdb) CreateMessage(ctx context.Context, nm database.NewMessage) (m database.Message, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		m, err = tx.CreateMessage(ctx, nm)
+		return err
+	})
+	return m, err
+}
+
+func (db *memdb) GetMessage(ctx context.Context, id int64) (m database.Message, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		m, err = tx.GetMessage(ctx, id)
+		return err
+	})
+	return m, err
+}
+
+func (db *memdb) ListMessages(ctx context.Context, conversationID int64, userID string, limit int) (list []database.Message, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		list, err = tx.ListMessages(ctx, conversationID, userID, limit)
+		return err
+	})
+	return list, err
+}
+
+func (db *memdb) CountUnread(ctx context.Context, conversationID int64, userID string) (count int, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		count, err = tx.CountUnread(ctx, conversationID, userID)
+		return err
+	})
+	return count, err
+}
+
+func (db *memdb) CountMentions(ctx context.Context, conversationID int64, userID string) (count int, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		count, err = tx.CountMentions(ctx, conversationID, userID)
+		return err
+	})
+	return count, err
+}
+
+func (db *memdb) EditMessage(ctx context.Context, id int64, content string,
+	mentions []database.Mention) (m database.Message, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		m, err = tx.EditMessage(ctx, id, content, mentions)
+		return err
+	})
+	return m, err
+}
+
+func (db *memdb) ListRevisions(ctx context.Context, messageID int64) (list []database.Revision, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		list, err = tx.ListRevisions(ctx, messageID)
+		return err
+	})
+	return list, err
+}
+
+func (db *memdb) DeleteMessage(ctx context.Context, id int64) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.DeleteMessage(ctx, id)
+	})
+}
+
+func (db *memdb) HideMessage(ctx context.Context, messageID int64, userID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.HideMessage(ctx, messageID, userID)
+	})
+}
+
+func (db *memdb) PurgeMessages(ctx context.Context, deletedBefore time.Time) (n int64, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		n, err = tx.PurgeMessages(ctx, deletedBefore)
+		return err
+	})
+	return n, err
+}
+
+func (db *memdb) SetReaction(ctx context.Context, messageID int64, userID string, emoji string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetReaction(ctx, messageID, userID, emoji)
+	})
+}
+
+func (db *memdb) DeleteReaction(ctx context.Context, messageID int64, userID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.DeleteReaction(ctx, messageID, userID)
+	})
+}
+
+func (tx *memtx) CreateMessage(ctx context.Context, nm database.NewMessage) (database.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Message{}, err
+	}
+	if _, ok := tx.data.conversations[nm.ConversationID]; !ok {
+		return database.Message{}, fmt.Errorf("conversation %d: %w", nm.ConversationID, database.ErrNotFound)
+	}
+	if _, err := tx.GetUser(ctx, nm.SenderID); err != nil {
+		return database.Message{}, fmt.Errorf("sender %s: %w", nm.SenderID, err)
+	}
+
+	tx.data.lastMessageID++
+	tx.data.messages[tx.data.lastMessageID] = database.Message{
+		ID:             tx.data.lastMessageID,
+		ConversationID: nm.ConversationID,
+		SenderID:       nm.SenderID,
+		SentAt:         tx.db.now(),
+		Content:        nm.Content,
+		Attachment:     nm.Attachment,
+		ReplyTo:        nm.ReplyTo,
+		Forwarded:      nm.Forwarded,
+	}
+	if err := tx.setMentions(tx.data.lastMessageID, nm.Mentions); err != nil {
+		return database.Message{}, err
+	}
+	return tx.GetMessage(ctx, tx.data.lastMessageID)
+}
+
+func (tx *memtx) GetMessage(ctx context.Context, id int64) (database.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Message{}, err
+	}
+	if _, ok := tx.data.messages[id]; !ok {
+		return database.Message{}, database.ErrNotFound
+	}
+	return tx.data.message(id), nil
+}
+
+func (tx *memtx) ListMessages(ctx context.Context, conversationID int64, userID string, limit int) ([]database.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for id, m := range tx.data.messages {
+		if m.ConversationID == conversationID && !tx.data.hidden[id][userID] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	var list = make([]database.Message, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, tx.data.message(id))
+	}
+	return list, nil
+}
+
+func (tx *memtx) CountUnread(ctx context.Context, conversationID int64, userID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	member, ok := tx.data.members[conversationID][userID]
+	if !ok {
+		return 0, nil
+	}
+	var count int
+	for id, m := range tx.data.messages {
+		if m.ConversationID == conversationID && id > member.LastReadID && m.SenderID != userID &&
+			m.DeletedAt.IsZero() && !tx.data.hidden[id][userID] {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (tx *memtx) CountMentions(ctx context.Context, conversationID int64, userID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	member, ok := tx.data.members[conversationID][userID]
+	if !ok {
+		return 0, nil
+	}
+	var count int
+	for id, m := range tx.data.messages {
+		if m.ConversationID != conversationID || id <= member.LastReadID || m.SenderID == userID ||
+			!m.DeletedAt.IsZero() || tx.data.hidden[id][userID] {
+			continue
+		}
+		for _, mention := range tx.data.mentions[id] {
+			if mention.UserID == userID {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+func (tx *memtx) EditMessage(ctx context.Context, id int64, content string, mentions []database.Mention) (database.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Message{}, err
+	}
+	m, ok := tx.data.messages[id]
+	if !ok {
+		return database.Message{}, database.ErrNotFound
+	}
+	now := tx.db.now()
+	tx.data.revisions[id] = append(tx.data.revisions[id], database.Revision{Content: m.Content, EditedAt: now})
+	m.Content, m.EditedAt = content, now
+	tx.data.messages[id] = m
+	if err := tx.setMentions(id, mentions); err != nil {
+		return database.Message{}, err
+	}
+	return tx.data.message(id), nil
+}
+
+func (tx *memtx) ListRevisions(ctx context.Context, messageID int64) ([]database.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return append([]database.Revision{}, tx.data.revisions[messageID]...), nil
+}
+
+func (tx *memtx) DeleteMessage(ctx context.Context, id int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m, ok := tx.data.messages[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	m.Content, m.Attachment = "", ""
+	if m.DeletedAt.IsZero() {
+		m.DeletedAt = tx.db.now()
+	}
+	tx.data.messages[id] = m
+	delete(tx.data.reactions, id)
+	delete(tx.data.revisions, id)
+	delete(tx.data.mentions, id)
+	return nil
+}
+
+func (tx *memtx) HideMessage(ctx context.Context, messageID int64, userID string) error {
+	if _, err := tx.GetMessage(ctx, messageID); err != nil {
+		return fmt.Errorf("message %d: %w", messageID, err)
+	}
+	if tx.data.hidden[messageID] == nil {
+		tx.data.hidden[messageID] = map[string]bool{}
+	}
+	tx.data.hidden[messageID][userID] = true
+	return nil
+}
+
+func (tx *memtx) PurgeMessages(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	var purged = map[int64]bool{}
+	for id, m := range tx.data.messages {
+		if !m.DeletedAt.IsZero() && m.DeletedAt.Before(deletedBefore) {
+			purged[id] = true
+		}
+	}
+	for id, m := range tx.data.messages {
+		if purged[id] {
+			tx.data.deleteMessage(id)
+		} else if purged[m.ReplyTo] {
+			m.ReplyTo = 0
+			tx.data.messages[id] = m
+		}
+	}
+	return int64(len(purged)), nil
+}
+
+func (tx *memtx) SetReaction(ctx context.Context, messageID int64, userID string, emoji string) error {
+	if _, err := tx.GetMessage(ctx, messageID); err != nil {
+		return fmt.Errorf("message %d: %w", messageID, err)
+	}
+	if tx.data.reactions[messageID] == nil {
+		tx.data.reactions[messageID] = map[string]database.Reaction{}
+	}
+	tx.data.reactions[messageID][userID] = database.Reaction{UserID: userID, Emoji: emoji, CreatedAt: tx.db.now()}
+	return nil
+}
+
+func (tx *memtx) DeleteReaction(ctx context.Context, messageID int64, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := tx.data.reactions[messageID][userID]; !ok {
+		return database.ErrNotFound
+	}
+	delete(tx.data.reactions[messageID], userID)
+	return nil
+}
+
+// setMentions replaces the mentions of the message `id`, checking them like the constraints of the SQLite table.
+func (tx *memtx) setMentions(id int64, mentions []database.Mention) error {
+	var offsets = map[int]bool{}
+	for _, mention := range mentions {
+		if mention.Offset < 0 || mention.Length < 2 {
+			return fmt.Errorf("%w: mention at %d, length %d", database.ErrInvalid, mention.Offset, mention.Length)
+		} else if offsets[mention.Offset] {
+			return fmt.Errorf("%w: two mentions at %d", database.ErrConflict, mention.Offset)
+		}
+		offsets[mention.Offset] = true
+	}
+
+	list := append([]database.Mention(nil), mentions...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Offset < list[j].Offset })
+	if len(list) == 0 {
+		delete(tx.data.mentions, id)
+	} else {
+		tx.data.mentions[id] = list
+	}
+	return nil
+}
+
+// deleteMessage removes the message `id` with the rows referring to it.
+func (d *memdata) deleteMessage(id int64) {
+	delete(d.messages, id)
+	delete(d.reactions, id)
+	delete(d.revisions, id)
+	delete(d.hidden, id)
+	delete(d.mentions, id)
+}
+
+// message returns the message `id` with the sender name and the reactions. The message must exist.
+func (d *memdata) message(id int64) database.Message {
+	m := d.messages[id]
+	m.SenderName = d.users[m.SenderID].Name
+	m.Mentions = append([]database.Mention{}, d.mentions[id]...)
+	m.Reactions = []database.Reaction{}
+	for _, r := range d.reactions[id] {
+		m.Reactions = append(m.Reactions, r)
+	}
+	sort.Slice(m.Reactions, func(i, j int) bool {
+		a, b := m.Reactions[i], m.Reactions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.UserID < b.UserID
+	})
+	return m
+}
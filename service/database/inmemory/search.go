@@ -0,0 +1,53 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sort"
+	"time"
+)
+
+func (db *memdb) SearchMessages(ctx context.Context, s database.MessageSearch) (list []database.Message, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		list, err = tx.SearchMessages(ctx, s)
+		return err
+	})
+	return list, err
+}
+
+func (tx *memtx) SearchMessages(ctx context.Context, s database.MessageSearch) ([]database.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if len(s.Words) == 0 {
+		return nil, fmt.Errorf("%w: no words to search", database.ErrInvalid)
+	}
+
+	// The bounds are compared with the precision of the timestamps, like SQLite
+	from, to := time.UnixMilli(s.From.UnixMilli()), time.UnixMilli(s.To.UnixMilli())
+	var ids []int64
+	for id, m := range tx.data.messages {
+		if _, member := tx.data.members[m.ConversationID][s.UserID]; !member || !m.DeletedAt.IsZero() ||
+			tx.data.hidden[id][s.UserID] {
+			continue
+		}
+		if (s.ConversationID != 0 && m.ConversationID != s.ConversationID) ||
+			(s.SenderID != "" && m.SenderID != s.SenderID) ||
+			(!s.From.IsZero() && m.SentAt.Before(from)) || (!s.To.IsZero() && !m.SentAt.Before(to)) {
+			continue
+		}
+		if database.ContainsWords(m.Content, s.Words) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	if len(ids) > s.Limit {
+		ids = ids[:s.Limit]
+	}
+
+	var list = make([]database.Message, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, tx.data.message(id))
+	}
+	return list, nil
+}
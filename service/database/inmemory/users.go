@@ -0,0 +1,212 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (db *memdb) CreateUser(ctx context.Context, id string, name string, photo string) (u database.User, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		u, err = tx.CreateUser(ctx, id, name, photo)
+		return err
+	})
+	return u, err
+}
+
+func (db *memdb) GetUser(ctx context.Context, id string) (u database.User, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		u, err = tx.GetUser(ctx, id)
+		return err
+	})
+	return u, err
+}
+
+func (db *memdb) GetUserByName(ctx context.Context, name string) (u database.User, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		u, err = tx.GetUserByName(ctx, name)
+		return err
+	})
+	return u, err
+}
+
+func (db *memdb) SetUserName(ctx context.Context, id string, name string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetUserName(ctx, id, name)
+	})
+}
+
+func (db *memdb) SetUserPhoto(ctx context.Context, id string, photo string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetUserPhoto(ctx, id, photo)
+	})
+}
+
+func (db *memdb) SetUserBanned(ctx context.Context, id string, banned bool) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.SetUserBanned(ctx, id, banned)
+	})
+}
+
+func (db *memdb) SearchUsers(ctx context.Context, query string, viewerID string,
+	limit int) (users []database.User, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		users, err = tx.SearchUsers(ctx, query, viewerID, limit)
+		return err
+	})
+	return users, err
+}
+
+func (db *memdb) CreateSession(ctx context.Context, userID string) (s database.Session, err error) {
+	err = db.update(ctx, func(tx *memtx) error {
+		s, err = tx.CreateSession(ctx, userID)
+		return err
+	})
+	return s, err
+}
+
+func (db *memdb) GetSession(ctx context.Context, userID string) (s database.Session, err error) {
+	err = db.view(ctx, func(tx *memtx) error {
+		s, err = tx.GetSession(ctx, userID)
+		return err
+	})
+	return s, err
+}
+
+func (db *memdb) DeleteSession(ctx context.Context, userID string) error {
+	return db.update(ctx, func(tx *memtx) error {
+		return tx.DeleteSession(ctx, userID)
+	})
+}
+
+func (tx *memtx) CreateUser(ctx context.Context, id string, name string, photo string) (database.User, error) {
+	if err := ctx.Err(); err != nil {
+		return database.User{}, err
+	}
+	if _, ok := tx.data.users[id]; ok {
+		return database.User{}, fmt.Errorf("%w: user ID %s already exists", database.ErrConflict, id)
+	} else if _, err := tx.data.userByName(name); err == nil {
+		return database.User{}, fmt.Errorf("%w: user name %s already exists", database.ErrConflict, name)
+	}
+
+	u := database.User{ID: id, Name: name, Photo: photo, CreatedAt: tx.db.now()}
+	tx.data.users[id] = u
+	return u, nil
+}
+
+func (tx *memtx) GetUser(ctx context.Context, id string) (database.User, error) {
+	if err := ctx.Err(); err != nil {
+		return database.User{}, err
+	}
+	u, ok := tx.data.users[id]
+	if !ok {
+		return database.User{}, database.ErrNotFound
+	}
+	return u, nil
+}
+
+func (tx *memtx) GetUserByName(ctx context.Context, name string) (database.User, error) {
+	if err := ctx.Err(); err != nil {
+		return database.User{}, err
+	}
+	return tx.data.userByName(name)
+}
+
+func (tx *memtx) SetUserName(ctx context.Context, id string, name string) error {
+	u, err := tx.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if other, err := tx.data.userByName(name); err == nil && other.ID != id {
+		return fmt.Errorf("%w: user name %s already exists", database.ErrConflict, name)
+	}
+	u.Name = name
+	tx.data.users[id] = u
+	return nil
+}
+
+func (tx *memtx) SetUserPhoto(ctx context.Context, id string, photo string) error {
+	u, err := tx.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.Photo = photo
+	tx.data.users[id] = u
+	return nil
+}
+
+func (tx *memtx) SetUserBanned(ctx context.Context, id string, banned bool) error {
+	u, err := tx.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !banned {
+		u.BannedAt = time.Time{}
+	} else if u.BannedAt.IsZero() {
+		u.BannedAt = tx.db.now()
+	}
+	tx.data.users[id] = u
+	return nil
+}
+
+func (tx *memtx) SearchUsers(ctx context.Context, query string, viewerID string, limit int) ([]database.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var users = []database.User{}
+	query = strings.ToLower(query)
+	for _, u := range tx.data.users {
+		if strings.Contains(strings.ToLower(u.Name), query) && !tx.data.blocks[viewerID][u.ID] {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (tx *memtx) CreateSession(ctx context.Context, userID string) (database.Session, error) {
+	if _, err := tx.GetUser(ctx, userID); err != nil {
+		return database.Session{}, fmt.Errorf("user %s: %w", userID, err)
+	}
+	s := database.Session{UserID: userID, CreatedAt: tx.db.now()}
+	tx.data.sessions[userID] = s
+	return s, nil
+}
+
+func (tx *memtx) GetSession(ctx context.Context, userID string) (database.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return database.Session{}, err
+	}
+	s, ok := tx.data.sessions[userID]
+	if !ok {
+		return database.Session{}, database.ErrNotFound
+	}
+	return s, nil
+}
+
+func (tx *memtx) DeleteSession(ctx context.Context, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := tx.data.sessions[userID]; !ok {
+		return database.ErrNotFound
+	}
+	delete(tx.data.sessions, userID)
+	return nil
+}
+
+// userByName scans all users, as names are not indexed in memory.
+func (d *memdata) userByName(name string) (database.User, error) {
+	for _, u := range d.users {
+		if u.Name == name {
+			return u, nil
+		}
+	}
+	return database.User{}, database.ErrNotFound
+}
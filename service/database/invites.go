@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Invite is a token that lets users join a group.
+type Invite struct {
+	Token          string
+	ConversationID int64
+	CreatedBy      string
+	CreatedAt      time.Time
+
+	// ExpiresAt is the time from which the invite cannot be used, zero if it never expires
+	ExpiresAt time.Time
+
+	// MaxUses is the number of users that can join with the invite, zero if unlimited. Uses is the number of users
+	// who joined
+	MaxUses int
+	Uses    int
+}
+
+// Usable returns true if the invite is neither expired nor used up at time `now`.
+func (inv Invite) Usable(now time.Time) bool {
+	return (inv.ExpiresAt.IsZero() || now.Before(inv.ExpiresAt)) && (inv.MaxUses == 0 || inv.Uses < inv.MaxUses)
+}
+
+// NewInvite contains the fields of an invite to be created with CreateInvite.
+type NewInvite struct {
+	Token          string
+	ConversationID int64
+	CreatedBy      string
+	ExpiresAt      time.Time
+	MaxUses        int
+}
+
+// inviteColumns are the columns read by scanInvite, in order.
+const inviteColumns = `token, conversation_id, created_by, created_at, expires_at, max_uses, uses`
+
+func scanInvite(row scanner) (Invite, error) {
+	var inv Invite
+	var createdAt int64
+	var expiresAt, maxUses sql.NullInt64
+	err := row.Scan(&inv.Token, &inv.ConversationID, &inv.CreatedBy, &createdAt, &expiresAt, &maxUses, &inv.Uses)
+	inv.CreatedAt = fromUnixMilli(createdAt)
+	if expiresAt.Valid {
+		inv.ExpiresAt = fromUnixMilli(expiresAt.Int64)
+	}
+	inv.MaxUses = int(maxUses.Int64)
+	return inv, err
+}
+
+func (db *appdbimpl) CreateInvite(ctx context.Context, ni NewInvite) (Invite, error) {
+	err := db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		var exists bool
+		err := tdb.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM conversations WHERE id = ? AND is_group = 1)`,
+			ni.ConversationID).Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("group %d: %w", ni.ConversationID, ErrNotFound)
+		}
+		if _, err := tdb.GetUser(ctx, ni.CreatedBy); err != nil {
+			return fmt.Errorf("user %s: %w", ni.CreatedBy, err)
+		}
+
+		var expiresAt = sql.NullInt64{Int64: toUnixMilli(ni.ExpiresAt), Valid: !ni.ExpiresAt.IsZero()}
+		var maxUses = sql.NullInt64{Int64: int64(ni.MaxUses), Valid: ni.MaxUses != 0}
+		_, err = tdb.w.ExecContext(ctx, `INSERT INTO group_invites (token, conversation_id, created_by, created_at,
+			expires_at, max_uses) VALUES (?, ?, ?, ?, ?, ?)`, ni.Token, ni.ConversationID, ni.CreatedBy,
+			toUnixMilli(tdb.now()), expiresAt, maxUses)
+		return translateError(err)
+	})
+	if err != nil {
+		return Invite{}, err
+	}
+	return db.GetInvite(ctx, ni.Token)
+}
+
+func (db *appdbimpl) GetInvite(ctx context.Context, token string) (Invite, error) {
+	inv, err := scanInvite(db.c.QueryRowContext(ctx, `SELECT `+inviteColumns+` FROM group_invites WHERE token = ?`,
+		token))
+	return inv, translateError(err)
+}
+
+func (db *appdbimpl) ListInvites(ctx context.Context, conversationID int64) ([]Invite, error) {
+	rows, err := db.c.QueryContext(ctx, `SELECT `+inviteColumns+` FROM group_invites WHERE conversation_id = ?
+		ORDER BY created_at, token`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	var invites = []Invite{}
+	err = eachRow(rows, func(row scanner) error {
+		inv, err := scanInvite(row)
+		if err != nil {
+			return err
+		}
+		invites = append(invites, inv)
+		return nil
+	})
+	return invites, err
+}
+
+func (db *appdbimpl) UseInvite(ctx context.Context, token string) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		inv, err := tdb.GetInvite(ctx, token)
+		if err != nil {
+			return err
+		} else if inv.MaxUses != 0 && inv.Uses >= inv.MaxUses {
+			return fmt.Errorf("%w: invite used up", ErrConflict)
+		}
+		_, err = tdb.w.ExecContext(ctx, `UPDATE group_invites SET uses = uses + 1 WHERE token = ?`, token)
+		return translateError(err)
+	})
+}
+
+func (db *appdbimpl) DeleteInvite(ctx context.Context, conversationID int64, token string) error {
+	res, err := db.w.ExecContext(ctx, `DELETE FROM group_invites WHERE conversation_id = ? AND token = ?`,
+		conversationID, token)
+	return checkAffected(res, err)
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInviteUsable(t *testing.T) {
+	now := time.Date(2025, 10, 7, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		inv  Invite
+		want bool
+	}{
+		{Invite{}, true},
+		{Invite{ExpiresAt: now.Add(time.Millisecond)}, true},
+		{Invite{ExpiresAt: now}, false},
+		{Invite{MaxUses: 2, Uses: 1}, true},
+		{Invite{MaxUses: 2, Uses: 2}, false},
+	} {
+		if got := tc.inv.Usable(now); got != tc.want {
+			t.Errorf("%+v.Usable() = %v, want %v", tc.inv, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Message is a message in a conversation.
+type Message struct {
+	ID             int64
+	ConversationID int64
+	SenderID       string
+
+	// SenderName is the current name of the sender
+	SenderName string
+
+	SentAt time.Time
+
+	// Content is the text, and Attachment the Base64 image. At least one of them is not empty
+	Content    string
+	Attachment string
+
+	// ReplyTo is the ID of the message this message replies to, zero if none
+	ReplyTo int64
+
+	Forwarded bool
+
+	// Mentions are sorted by offset. Tombstones have no mentions
+	Mentions []Mention
+
+	// EditedAt is the time of the last edit, zero if the message has never been edited
+	EditedAt time.Time
+
+	// DeletedAt is the time of the deletion, zero if the message has not been deleted. Deleted messages are tombstones:
+	// content and attachment are empty, and there are no reactions
+	DeletedAt time.Time
+
+	// Reactions are sorted by time, then by user ID
+	Reactions []Reaction
+}
+
+// Reaction is the emoji reaction of a user to a message. Each user has at most one reaction per message.
+type Reaction struct {
+	UserID    string
+	Emoji     string
+	CreatedAt time.Time
+}
+
+// Mention is a mention of a user in the content of a message, with `@name`. Offset and Length are counted in Unicode
+// code points, and cover the whole token, `@` included.
+type Mention struct {
+	UserID string
+	Offset int
+	Length int
+}
+
+// Revision is a previous content of an edited message.
+type Revision struct {
+	Content string
+
+	// EditedAt is the time of the edit that replaced this content
+	EditedAt time.Time
+}
+
+// NewMessage contains the fields of a message to be created with CreateMessage.
+type NewMessage struct {
+	ConversationID int64
+	SenderID       string
+	Content        string
+	Attachment     string
+	ReplyTo        int64
+	Forwarded      bool
+	Mentions       []Mention
+}
+
+// messageColumns are the columns read by scanMessage, in order. Queries must join `users u` on the sender.
+const messageColumns = `m.id, m.conversation_id, m.sender_id, u.name, m.sent_at, m.content, m.attachment, m.reply_to,
+	m.forwarded, m.edited_at, m.deleted_at`
+
+func scanMessage(row scanner) (Message, error) {
+	var m Message
+	var sentAt int64
+	var replyTo, editedAt, deletedAt sql.NullInt64
+	err := row.Scan(&m.ID, &m.ConversationID, &m.SenderID, &m.SenderName, &sentAt, &m.Content, &m.Attachment, &replyTo,
+		&m.Forwarded, &editedAt, &deletedAt)
+	m.SentAt = fromUnixMilli(sentAt)
+	m.ReplyTo = replyTo.Int64
+	if editedAt.Valid {
+		m.EditedAt = fromUnixMilli(editedAt.Int64)
+	}
+	if deletedAt.Valid {
+		m.DeletedAt = fromUnixMilli(deletedAt.Int64)
+	}
+	m.Reactions = []Reaction{}
+	m.Mentions = []Mention{}
+	return m, err
+}
+
+func (db *appdbimpl) CreateMessage(ctx context.Context, nm NewMessage) (Message, error) {
+	var id int64
+	err := db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		var exists bool
+		err := tdb.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM conversations WHERE id = ?)`,
+			nm.ConversationID).Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("conversation %d: %w", nm.ConversationID, ErrNotFound)
+		}
+		if _, err := tdb.GetUser(ctx, nm.SenderID); err != nil {
+			return fmt.Errorf("sender %s: %w", nm.SenderID, err)
+		}
+
+		var replyTo = sql.NullInt64{Int64: nm.ReplyTo, Valid: nm.ReplyTo != 0}
+		res, err := tdb.w.ExecContext(ctx, `INSERT INTO messages (conversation_id, sender_id, sent_at, content,
+			attachment, reply_to, forwarded) VALUES (?, ?, ?, ?, ?, ?, ?)`, nm.ConversationID, nm.SenderID,
+			toUnixMilli(tdb.now()), nm.Content, nm.Attachment, replyTo, nm.Forwarded)
+		if err != nil {
+			return translateError(err)
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		return tdb.insertMentions(ctx, id, nm.Mentions)
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return db.GetMessage(ctx, id)
+}
+
+func (db *appdbimpl) GetMessage(ctx context.Context, id int64) (Message, error) {
+	m, err := scanMessage(db.c.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM messages m
+		JOIN users u ON u.id = m.sender_id WHERE m.id = ?`, id))
+	if err != nil {
+		return Message{}, translateError(err)
+	}
+
+	var list = []Message{m}
+	err = db.loadReactions(ctx, list, `SELECT message_id, user_id, emoji, created_at FROM reactions
+		WHERE message_id = ? ORDER BY created_at, user_id`, id)
+	if err != nil {
+		return Message{}, err
+	}
+	err = db.loadMentions(ctx, list, `SELECT message_id, user_id, position, length FROM mentions
+		WHERE message_id = ? ORDER BY position`, id)
+	return list[0], err
+}
+
+// notHidden is the condition excluding the messages `m` hidden by the user in the first parameter. An empty user ID
+// excludes no message.
+const notHidden = `NOT EXISTS (SELECT 1 FROM hidden_messages h WHERE h.message_id = m.id AND h.user_id = ?)`
+
+func (db *appdbimpl) ListMessages(ctx context.Context, conversationID int64, userID string, limit int) ([]Message, error) {
+	rows, err := db.c.QueryContext(ctx, `SELECT `+messageColumns+` FROM messages m JOIN users u ON u.id = m.sender_id
+		WHERE m.conversation_id = ? AND `+notHidden+` ORDER BY m.id DESC LIMIT ?`, conversationID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	var list = []Message{}
+	err = eachRow(rows, func(row scanner) error {
+		m, err := scanMessage(row)
+		if err != nil {
+			return err
+		}
+		list = append(list, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.loadReactions(ctx, list, `SELECT message_id, user_id, emoji, created_at FROM reactions
+		WHERE message_id IN (SELECT m.id FROM messages m WHERE m.conversation_id = ? AND `+notHidden+`
+		ORDER BY m.id DESC LIMIT ?) ORDER BY created_at, user_id`, conversationID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	err = db.loadMentions(ctx, list, `SELECT message_id, user_id, position, length FROM mentions
+		WHERE message_id IN (SELECT m.id FROM messages m WHERE m.conversation_id = ? AND `+notHidden+`
+		ORDER BY m.id DESC LIMIT ?) ORDER BY position`, conversationID, userID, limit)
+	return list, err
+}
+
+func (db *appdbimpl) CountUnread(ctx context.Context, conversationID int64, userID string) (int, error) {
+	// Only the messages after the read marker are scanned, with a range search on `messages_by_conversation`
+	var count int
+	err := db.c.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages m JOIN members r ON r.conversation_id =
+		m.conversation_id AND r.user_id = ? WHERE m.conversation_id = ? AND m.id > r.last_read_id AND m.sender_id <> ?
+		AND m.deleted_at IS NULL AND `+notHidden, userID, conversationID, userID, userID).Scan(&count)
+	return count, err
+}
+
+func (db *appdbimpl) CountMentions(ctx context.Context, conversationID int64, userID string) (int, error) {
+	var count int
+	err := db.c.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages m JOIN members r ON r.conversation_id =
+		m.conversation_id AND r.user_id = ? WHERE m.conversation_id = ? AND m.id > r.last_read_id AND m.sender_id <> ?
+		AND m.deleted_at IS NULL AND `+notHidden+` AND EXISTS (SELECT 1 FROM mentions x WHERE x.message_id = m.id
+		AND x.user_id = ?)`, userID, conversationID, userID, userID, userID).Scan(&count)
+	return count, err
+}
+
+func (db *appdbimpl) EditMessage(ctx context.Context, id int64, content string, mentions []Mention) (Message, error) {
+	err := db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		now := toUnixMilli(tdb.now())
+		res, err := tdb.w.ExecContext(ctx, `INSERT INTO message_revisions (message_id, content, edited_at)
+			SELECT id, content, ? FROM messages WHERE id = ?`, now, id)
+		if err := checkAffected(res, err); err != nil {
+			return err
+		}
+		res, err = tdb.w.ExecContext(ctx, `UPDATE messages SET content = ?, edited_at = ? WHERE id = ?`, content, now,
+			id)
+		if err := checkAffected(res, err); err != nil {
+			return err
+		}
+		if _, err := tdb.w.ExecContext(ctx, `DELETE FROM mentions WHERE message_id = ?`, id); err != nil {
+			return err
+		}
+		return tdb.insertMentions(ctx, id, mentions)
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	return db.GetMessage(ctx, id)
+}
+
+func (db *appdbimpl) ListRevisions(ctx context.Context, messageID int64) ([]Revision, error) {
+	rows, err := db.c.QueryContext(ctx, `SELECT content, edited_at FROM message_revisions WHERE message_id = ?
+		ORDER BY id`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	var list = []Revision{}
+	err = eachRow(rows, func(row scanner) error {
+		var r Revision
+		var editedAt int64
+		if err := row.Scan(&r.Content, &editedAt); err != nil {
+			return err
+		}
+		r.EditedAt = fromUnixMilli(editedAt)
+		list = append(list, r)
+		return nil
+	})
+	return list, err
+}
+
+func (db *appdbimpl) DeleteMessage(ctx context.Context, id int64) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		res, err := tdb.w.ExecContext(ctx, `UPDATE messages SET content = '', attachment = '',
+			deleted_at = COALESCE(deleted_at, ?) WHERE id = ?`, toUnixMilli(tdb.now()), id)
+		if err := checkAffected(res, err); err != nil {
+			return err
+		}
+		for _, query := range []string{
+			`DELETE FROM reactions WHERE message_id = ?`,
+			`DELETE FROM message_revisions WHERE message_id = ?`,
+			`DELETE FROM mentions WHERE message_id = ?`,
+		} {
+			if _, err := tdb.w.ExecContext(ctx, query, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *appdbimpl) HideMessage(ctx context.Context, messageID int64, userID string) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		var exists bool
+		err := tdb.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM messages WHERE id = ?)`, messageID).
+			Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("message %d: %w", messageID, ErrNotFound)
+		}
+
+		_, err = tdb.w.ExecContext(ctx, `INSERT INTO hidden_messages (message_id, user_id, hidden_at) VALUES (?, ?, ?)
+			ON CONFLICT (message_id, user_id) DO NOTHING`, messageID, userID, toUnixMilli(tdb.now()))
+		return translateError(err)
+	})
+}
+
+func (db *appdbimpl) PurgeMessages(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		before := toUnixMilli(deletedBefore)
+		// Foreign keys may be disabled, so the rows referring to the messages are updated explicitly
+		for _, query := range []string{
+			`UPDATE messages SET reply_to = NULL WHERE reply_to IN (SELECT id FROM messages WHERE deleted_at < ?)`,
+			`DELETE FROM reactions WHERE message_id IN (SELECT id FROM messages WHERE deleted_at < ?)`,
+			`DELETE FROM message_revisions WHERE message_id IN (SELECT id FROM messages WHERE deleted_at < ?)`,
+			`DELETE FROM hidden_messages WHERE message_id IN (SELECT id FROM messages WHERE deleted_at < ?)`,
+			`DELETE FROM mentions WHERE message_id IN (SELECT id FROM messages WHERE deleted_at < ?)`,
+		} {
+			if _, err := tdb.w.ExecContext(ctx, query, before); err != nil {
+				return err
+			}
+		}
+		res, err := tdb.w.ExecContext(ctx, `DELETE FROM messages WHERE deleted_at < ?`, before)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	return purged, err
+}
+
+// insertMentions adds the mentions of the message `id`.
+func (db *appdbimpl) insertMentions(ctx context.Context, id int64, mentions []Mention) error {
+	for _, mention := range mentions {
+		_, err := db.w.ExecContext(ctx, `INSERT INTO mentions (message_id, user_id, position, length) VALUES (?, ?, ?, ?)`,
+			id, mention.UserID, mention.Offset, mention.Length)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// loadMentions runs `query`, which returns (message_id, user_id, position, length) rows, and adds the mentions to the
+// messages in `list`.
+func (db *appdbimpl) loadMentions(ctx context.Context, list []Message, query string, args ...interface{}) error {
+	var byID = make(map[int64]*Message, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+	}
+
+	rows, err := db.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return eachRow(rows, func(row scanner) error {
+		var messageID int64
+		var mention Mention
+		if err := row.Scan(&messageID, &mention.UserID, &mention.Offset, &mention.Length); err != nil {
+			return err
+		}
+		if m, ok := byID[messageID]; ok {
+			m.Mentions = append(m.Mentions, mention)
+		}
+		return nil
+	})
+}
+
+// loadReactions runs `query`, which returns (message_id, user_id, emoji, created_at) rows, and adds the reactions to
+// the messages in `list`.
+func (db *appdbimpl) loadReactions(ctx context.Context, list []Message, query string, args ...interface{}) error {
+	var byID = make(map[int64]*Message, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+	}
+
+	rows, err := db.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return eachRow(rows, func(row scanner) error {
+		var messageID, createdAt int64
+		var r Reaction
+		if err := row.Scan(&messageID, &r.UserID, &r.Emoji, &createdAt); err != nil {
+			return err
+		}
+		r.CreatedAt = fromUnixMilli(createdAt)
+		if m, ok := byID[messageID]; ok {
+			m.Reactions = append(m.Reactions, r)
+		}
+		return nil
+	})
+}
+
+func (db *appdbimpl) SetReaction(ctx context.Context, messageID int64, userID string, emoji string) error {
+	return db.WithTx(ctx, func(tx AppDatabase) error {
+		tdb := tx.(*appdbimpl)
+		var exists bool
+		err := tdb.c.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM messages WHERE id = ?)`, messageID).
+			Scan(&exists)
+		if err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("message %d: %w", messageID, ErrNotFound)
+		}
+
+		_, err = tdb.w.ExecContext(ctx, `INSERT INTO reactions (message_id, user_id, emoji, created_at)
+			VALUES (?, ?, ?, ?) ON CONFLICT (message_id, user_id) DO UPDATE SET emoji = excluded.emoji,
+			created_at = excluded.created_at`, messageID, userID, emoji, toUnixMilli(tdb.now()))
+		return translateError(err)
+	})
+}
+
+func (db *appdbimpl) DeleteReaction(ctx context.Context, messageID int64, userID string) error {
+	res, err := db.w.ExecContext(ctx, `DELETE FROM reactions WHERE message_id = ? AND user_id = ?`, messageID, userID)
+	return checkAffected(res, err)
+}
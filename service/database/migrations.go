@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations contains the SQL statements that upgrade the database schema, in order. The schema version is stored in
+// SQLite `user_version` pragma: applying migrations[i] brings the database to version i+1.
+// Append new migrations at the end, never modify or remove existing ones.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS example_table (id INTEGER NOT NULL PRIMARY KEY, name TEXT);`,
+	`CREATE TABLE users (
+		id TEXT NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		photo TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE sessions (
+		user_id TEXT NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+		created_at INTEGER NOT NULL
+	);`,
+	`CREATE TABLE conversations (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		is_group INTEGER NOT NULL CHECK (is_group IN (0, 1)),
+		name TEXT NOT NULL,
+		photo TEXT NOT NULL,
+		direct_key TEXT UNIQUE,
+		created_at INTEGER NOT NULL
+	);
+	CREATE TABLE members (
+		conversation_id INTEGER NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		joined_at INTEGER NOT NULL,
+		last_delivered_id INTEGER NOT NULL DEFAULT 0,
+		last_read_id INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (conversation_id, user_id)
+	);
+	CREATE INDEX members_by_user ON members (user_id);
+	CREATE TABLE messages (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+		sender_id TEXT NOT NULL REFERENCES users (id),
+		sent_at INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		attachment TEXT NOT NULL,
+		reply_to INTEGER REFERENCES messages (id) ON DELETE SET NULL,
+		forwarded INTEGER NOT NULL CHECK (forwarded IN (0, 1))
+	);
+	CREATE INDEX messages_by_conversation ON messages (conversation_id, id);
+	CREATE TABLE reactions (
+		message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		emoji TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (message_id, user_id)
+	);`,
+	`ALTER TABLE users ADD COLUMN banned_at INTEGER;`,
+	`ALTER TABLE messages ADD COLUMN edited_at INTEGER;
+	CREATE TABLE message_revisions (
+		id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+		content TEXT NOT NULL,
+		edited_at INTEGER NOT NULL
+	);
+	CREATE INDEX message_revisions_by_message ON message_revisions (message_id, id);`,
+	`ALTER TABLE messages ADD COLUMN deleted_at INTEGER;
+	CREATE INDEX messages_by_deleted_at ON messages (deleted_at) WHERE deleted_at IS NOT NULL;`,
+	`CREATE TABLE hidden_messages (
+		message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		hidden_at INTEGER NOT NULL,
+		PRIMARY KEY (message_id, user_id)
+	);`,
+	`ALTER TABLE members ADD COLUMN role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member'));
+	ALTER TABLE conversations ADD COLUMN perm_rename TEXT NOT NULL DEFAULT 'members'
+		CHECK (perm_rename IN ('admins', 'members'));
+	ALTER TABLE conversations ADD COLUMN perm_add_members TEXT NOT NULL DEFAULT 'members'
+		CHECK (perm_add_members IN ('admins', 'members'));
+	ALTER TABLE conversations ADD COLUMN perm_remove_members TEXT NOT NULL DEFAULT 'admins'
+		CHECK (perm_remove_members IN ('admins', 'members'));
+	UPDATE members SET role = 'owner' WHERE rowid IN (SELECT (SELECT m.rowid FROM members m
+		WHERE m.conversation_id = c.id ORDER BY m.joined_at, m.user_id LIMIT 1) FROM conversations c WHERE c.is_group = 1);`,
+	`CREATE TABLE group_invites (
+		token TEXT NOT NULL PRIMARY KEY,
+		conversation_id INTEGER NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+		created_by TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		created_at INTEGER NOT NULL,
+		expires_at INTEGER,
+		max_uses INTEGER CHECK (max_uses > 0),
+		uses INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX group_invites_by_conversation ON group_invites (conversation_id, created_at);`,
+	`CREATE TABLE conversation_settings (
+		conversation_id INTEGER NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		muted_until INTEGER,
+		archived INTEGER NOT NULL DEFAULT 0 CHECK (archived IN (0, 1)),
+		pinned_at INTEGER,
+		PRIMARY KEY (conversation_id, user_id)
+	);`,
+	`CREATE TABLE blocks (
+		blocker_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		blocked_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (blocker_id, blocked_id)
+	);
+	CREATE INDEX blocks_by_blocked ON blocks (blocked_id);`,
+	`CREATE TABLE mentions (
+		message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+		user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		position INTEGER NOT NULL CHECK (position >= 0),
+		length INTEGER NOT NULL CHECK (length >= 2),
+		PRIMARY KEY (message_id, position)
+	);
+	CREATE INDEX mentions_by_user ON mentions (user_id, message_id);`,
+}
+
+// LatestSchemaVersion returns the schema version after applying all migrations embedded in the executable.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+// schemaVersion reads the current schema version of the database.
+func schemaVersion(ctx context.Context, db querier) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version)
+	return version, err
+}
+
+// Migrate applies all missing migrations to `db`, returning the number of migrations applied. Each migration runs in
+// its own transaction together with the version update, so a failed migration leaves the database at the previous
+// version. The version is read inside the transaction, which takes the write lock immediately: when more processes
+// migrate the same database at once, each migration is applied only once. Then the full-text index of the messages is
+// created, if SQLite supports FTS5 (see setupSearchIndex). New calls Migrate automatically.
+func Migrate(db *sql.DB) (int, error) {
+	ctx := context.Background()
+	// BEGIN and COMMIT are issued as statements, so all of them must run on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	applied := 0
+	for {
+		done, err := migrateOne(ctx, conn)
+		if err != nil {
+			return applied, err
+		} else if done {
+			// The full-text index depends on the build tags, so it's not a migration
+			return applied, setupSearchIndex(ctx, conn)
+		}
+		applied++
+	}
+}
+
+// migrateOne applies the next missing migration on `conn`. It returns true if the schema was already up-to-date.
+func migrateOne(ctx context.Context, conn *sql.Conn) (bool, error) {
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return false, fmt.Errorf("starting migration: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+
+	version, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return false, fmt.Errorf("reading schema version: %w", err)
+	} else if version > len(migrations) {
+		return false, fmt.Errorf("database schema version %d is newer than this executable (%d)", version, len(migrations))
+	} else if version == len(migrations) {
+		return true, nil
+	}
+
+	if _, err = conn.ExecContext(ctx, migrations[version]); err != nil {
+		return false, fmt.Errorf("applying migration %d: %w", version+1, err)
+	}
+	// PRAGMA statements do not support parameters
+	if _, err = conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+		return false, fmt.Errorf("updating schema version to %d: %w", version+1, err)
+	}
+	if _, err = conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return false, fmt.Errorf("committing migration %d: %w", version+1, err)
+	}
+	committed = true
+	return false, nil
+}
+
+// SchemaVersion returns the current schema version of the database. It's equal to LatestSchemaVersion() when all
+// migrations have been applied.
+func (db *appdbimpl) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(ctx, db.c)
+}
@@ -0,0 +1,90 @@
+package database_test
+
+import (
+	"database/sql"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if applied, err := database.Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	} else if applied != database.LatestSchemaVersion() {
+		t.Fatalf("Migrate applied %d migrations, want %d", applied, database.LatestSchemaVersion())
+	}
+	// An up-to-date database is left untouched
+	if applied, err := database.Migrate(db); err != nil || applied != 0 {
+		t.Fatalf("second Migrate = %d, %v; want 0, nil", applied, err)
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	exec(t, db, "PRAGMA user_version = 1000")
+
+	if _, err := database.Migrate(db); err == nil {
+		t.Fatal("Migrate on a database newer than the executable succeeded")
+	}
+}
+
+func TestMigrateConcurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.db")
+
+	// Switching a new file to WAL fails with SQLITE_BUSY, without waiting, while another connection holds a lock: create
+	// the file first, as it would be after the first start
+	first, err := database.Open(filename, database.ConnOptions{JournalMode: "WAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec(t, first, "PRAGMA user_version = 0")
+	_ = first.Close()
+
+	// Like more processes starting at once, each with its own connection pool
+	const processes = 4
+	var dbs []*sql.DB
+	for i := 0; i < processes; i++ {
+		db, err := database.Open(filename, database.ConnOptions{JournalMode: "WAL", BusyTimeout: 5 * time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = db.Close() }()
+		dbs = append(dbs, db)
+	}
+
+	var wg sync.WaitGroup
+	applied := make([]int, processes)
+	errs := make([]error, processes)
+	for i := range dbs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied[i], errs[i] = database.Migrate(dbs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range dbs {
+		if errs[i] != nil {
+			t.Fatalf("Migrate: %v", errs[i])
+		}
+		total += applied[i]
+	}
+	// Each migration is applied exactly once
+	if total != database.LatestSchemaVersion() {
+		t.Fatalf("%d migrations applied in total, want %d", total, database.LatestSchemaVersion())
+	}
+}
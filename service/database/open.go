@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConnOptions contains the SQLite settings applied to every connection, and the connection pool limits.
+type ConnOptions struct {
+	// JournalMode is the SQLite journal mode: DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF. Empty keeps the SQLite
+	// default (DELETE)
+	JournalMode string
+
+	// Synchronous is the SQLite synchronous level: OFF, NORMAL, FULL or EXTRA. Empty keeps the SQLite default (FULL)
+	Synchronous string
+
+	// BusyTimeout is how long a connection waits for a lock before failing with SQLITE_BUSY
+	BusyTimeout time.Duration
+
+	// ForeignKeys enables the enforcement of foreign key constraints
+	ForeignKeys bool
+
+	// MaxOpenConns is the maximum number of open connections (zero means unlimited). It's ignored by OpenWriter
+	MaxOpenConns int
+
+	// MaxIdleConns is the maximum number of idle connections kept in the pool
+	MaxIdleConns int
+}
+
+// Open opens the SQLite database file `filename`, applying `opts` to every connection of the pool. The returned pool is
+// meant for reads: use OpenWriter for the pool used for writes.
+func Open(filename string, opts ConnOptions) (*sql.DB, error) {
+	db, err := open(filename, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	return db, nil
+}
+
+// OpenWriter opens the SQLite database file `filename` like Open, but with a single connection. SQLite allows only one
+// writer at a time: funneling all writes through one connection avoids SQLITE_BUSY errors between concurrent writers.
+// Transactions on this connection take the write lock immediately (BEGIN IMMEDIATE).
+func OpenWriter(filename string, opts ConnOptions) (*sql.DB, error) {
+	db, err := open(filename, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	// Keep the connection open, so per-connection settings and the page cache are not lost
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+	return db, nil
+}
+
+func open(filename string, opts ConnOptions, writer bool) (*sql.DB, error) {
+	params := url.Values{}
+	if opts.JournalMode != "" {
+		mode := strings.ToUpper(opts.JournalMode)
+		switch mode {
+		case "DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF":
+		default:
+			return nil, fmt.Errorf("invalid journal mode %q", opts.JournalMode)
+		}
+		params.Set("_journal_mode", mode)
+	}
+	if opts.Synchronous != "" {
+		level := strings.ToUpper(opts.Synchronous)
+		switch level {
+		case "OFF", "NORMAL", "FULL", "EXTRA":
+		default:
+			return nil, fmt.Errorf("invalid synchronous level %q", opts.Synchronous)
+		}
+		params.Set("_synchronous", level)
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	params.Set("_foreign_keys", strconv.FormatBool(opts.ForeignKeys))
+	if writer {
+		params.Set("_txlock", "immediate")
+	}
+
+	db, err := sql.Open("sqlite3", filename+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
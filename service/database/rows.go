@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// eachRow calls fn for every row, then closes the rows.
+func eachRow(rows *sql.Rows, fn func(row scanner) error) error {
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// checkAffected returns ErrNotFound if an UPDATE or DELETE did not change any row.
+func checkAffected(res sql.Result, err error) error {
+	if err != nil {
+		return translateError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
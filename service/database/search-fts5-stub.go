@@ -0,0 +1,32 @@
+//go:build !sqlite_fts5
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// FullTextSearch is false when SQLite is built without FTS5: SearchMessages reads the content of the newest
+// searchScanLimit messages of the user, and matches the words in Go.
+const FullTextSearch = false
+
+// setupSearchIndex drops the triggers updating the full-text index, if the database has been used by an executable
+// built with FTS5: without the module, they would make every change of the messages fail. The index is rebuilt when
+// the database is opened again with FTS5.
+func setupSearchIndex(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `DROP TRIGGER IF EXISTS messages_fts_insert;
+		DROP TRIGGER IF EXISTS messages_fts_update;
+		DROP TRIGGER IF EXISTS messages_fts_delete;`)
+	if err != nil {
+		return fmt.Errorf("dropping search index triggers: %w", err)
+	}
+	return nil
+}
+
+// wordsCondition returns a condition matching all the messages with a content: SQLite lower() only folds ASCII
+// letters, so the words are matched by SearchMessages.
+func wordsCondition(words []string) (string, []interface{}) {
+	return `m.content <> ''`, nil
+}
@@ -0,0 +1,72 @@
+//go:build sqlite_fts5
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// FullTextSearch is true when SQLite is built with FTS5, and SearchMessages uses the `messages_fts` index.
+const FullTextSearch = true
+
+// searchIndex creates the full-text index of the content of the messages, and the triggers keeping it up-to-date.
+// Tombstones have an empty content, so the update trigger removes deleted messages from the index. The index is
+// rebuilt, as the messages may have changed while the triggers did not exist (see search-fts5-stub.go).
+const searchIndex = `CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5 (content, content = 'messages',
+		content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 0');
+	CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (rowid, content) VALUES (new.id, new.content);
+	END;
+	CREATE TRIGGER messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+		INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		INSERT INTO messages_fts (rowid, content) VALUES (new.id, new.content);
+	END;
+	CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+		INSERT INTO messages_fts (messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	END;
+	INSERT INTO messages_fts (messages_fts) VALUES ('rebuild');`
+
+// setupSearchIndex creates the full-text index of the messages on `conn`, if missing.
+func setupSearchIndex(ctx context.Context, conn *sql.Conn) error {
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE`); err != nil {
+		return fmt.Errorf("starting search index setup: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+
+	var exists bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger'
+		AND name = 'messages_fts_insert')`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("reading search index: %w", err)
+	} else if exists {
+		return nil
+	}
+
+	if _, err := conn.ExecContext(ctx, searchIndex); err != nil {
+		return fmt.Errorf("creating search index: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return fmt.Errorf("committing search index: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// wordsCondition returns the condition matching the messages `m` containing all the words, with its parameters.
+func wordsCondition(words []string) (string, []interface{}) {
+	var quoted = make([]string, 0, len(words))
+	for _, w := range words {
+		// A quoted string is a single token for FTS5, even if it contains operators
+		quoted = append(quoted, `"`+strings.ReplaceAll(w, `"`, `""`)+`"`)
+	}
+	return `m.id IN (SELECT rowid FROM messages_fts WHERE messages_fts MATCH ?)`,
+		[]interface{}{strings.Join(quoted, " ")}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MessageSearch contains the words and the filters of SearchMessages.
+type MessageSearch struct {
+	// UserID is the user searching: only the messages of their conversations are returned, except the messages hidden
+	// by the user
+	UserID string
+
+	// Words must all appear in the content of the messages, as whole words. They must be lowercase: see SearchWords
+	Words []string
+
+	// ConversationID and SenderID, if not zero, restrict the results to a conversation and to a sender
+	ConversationID int64
+	SenderID       string
+
+	// From and To, if not zero, restrict the results to the messages sent in [From, To)
+	From time.Time
+	To   time.Time
+
+	Limit int
+}
+
+// searchScanLimit is the maximum number of messages whose content is read by SearchMessages without the full-text
+// index (see FullTextSearch): older messages are not found.
+const searchScanLimit = 10000
+
+// IsWordRune returns true if `r` is part of a word for SearchMessages: words are sequences of letters and digits.
+func IsWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// SearchWords splits `text` into lowercase words, as matched by SearchMessages.
+func SearchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !IsWordRune(r) })
+}
+
+// ContainsWords returns true if all the lowercase `words` appear in `text` as whole words, ignoring case.
+func ContainsWords(text string, words []string) bool {
+	var found = map[string]bool{}
+	for _, w := range SearchWords(text) {
+		found[w] = true
+	}
+	for _, w := range words {
+		if !found[w] {
+			return false
+		}
+	}
+	return true
+}
+
+func (db *appdbimpl) SearchMessages(ctx context.Context, s MessageSearch) ([]Message, error) {
+	if len(s.Words) == 0 {
+		return nil, fmt.Errorf("%w: no words to search", ErrInvalid)
+	}
+
+	// The candidates are read without the attachments, which are loaded only for the results
+	match, args := wordsCondition(s.Words)
+	query := `SELECT m.id, m.content FROM messages m
+		WHERE ` + match + ` AND m.deleted_at IS NULL AND ` + notHidden + `
+		AND m.conversation_id IN (SELECT conversation_id FROM members WHERE user_id = ?)`
+	args = append(args, s.UserID, s.UserID)
+	if s.ConversationID != 0 {
+		query += ` AND m.conversation_id = ?`
+		args = append(args, s.ConversationID)
+	}
+	if s.SenderID != "" {
+		query += ` AND m.sender_id = ?`
+		args = append(args, s.SenderID)
+	}
+	if !s.From.IsZero() {
+		query += ` AND m.sent_at >= ?`
+		args = append(args, toUnixMilli(s.From))
+	}
+	if !s.To.IsZero() {
+		query += ` AND m.sent_at < ?`
+		args = append(args, toUnixMilli(s.To))
+	}
+	query += ` ORDER BY m.id DESC LIMIT ?`
+	if FullTextSearch {
+		args = append(args, s.Limit)
+	} else {
+		args = append(args, searchScanLimit)
+	}
+
+	rows, err := db.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	// The IDs are integers, so they can be written in the queries
+	var ids []string
+	err = eachRow(rows, func(row scanner) error {
+		var id int64
+		var content string
+		if err := row.Scan(&id, &content); err != nil {
+			return err
+		}
+		// Without the full-text index, the words are matched here
+		if len(ids) < s.Limit && (FullTextSearch || ContainsWords(content, s.Words)) {
+			ids = append(ids, fmt.Sprint(id))
+		}
+		return nil
+	})
+	if err != nil || len(ids) == 0 {
+		return []Message{}, err
+	}
+	in := `(` + strings.Join(ids, ", ") + `)`
+
+	rows, err = db.c.QueryContext(ctx, `SELECT `+messageColumns+` FROM messages m JOIN users u ON u.id = m.sender_id
+		WHERE m.id IN `+in+` AND m.deleted_at IS NULL ORDER BY m.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	var list = make([]Message, 0, len(ids))
+	err = eachRow(rows, func(row scanner) error {
+		m, err := scanMessage(row)
+		if err != nil {
+			return err
+		}
+		list = append(list, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.loadReactions(ctx, list, `SELECT message_id, user_id, emoji, created_at FROM reactions
+		WHERE message_id IN `+in+` ORDER BY created_at, user_id`)
+	if err != nil {
+		return nil, err
+	}
+	err = db.loadMentions(ctx, list, `SELECT message_id, user_id, position, length FROM mentions
+		WHERE message_id IN `+in+` ORDER BY position`)
+	return list, err
+}
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Session is the login session of a user. The bearer token of the session is the user ID.
+type Session struct {
+	UserID    string
+	CreatedAt time.Time
+}
+
+func (db *appdbimpl) CreateSession(ctx context.Context, userID string) (Session, error) {
+	if _, err := db.GetUser(ctx, userID); err != nil {
+		return Session{}, fmt.Errorf("user %s: %w", userID, err)
+	}
+
+	s := Session{UserID: userID, CreatedAt: db.now()}
+	_, err := db.w.ExecContext(ctx, `INSERT INTO sessions (user_id, created_at) VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET created_at = excluded.created_at`, s.UserID, toUnixMilli(s.CreatedAt))
+	if err != nil {
+		return Session{}, translateError(err)
+	}
+	return s, nil
+}
+
+func (db *appdbimpl) GetSession(ctx context.Context, userID string) (Session, error) {
+	var s = Session{UserID: userID}
+	var createdAt int64
+	err := db.c.QueryRowContext(ctx, `SELECT created_at FROM sessions WHERE user_id = ?`, userID).Scan(&createdAt)
+	if err != nil {
+		return Session{}, translateError(err)
+	}
+	s.CreatedAt = fromUnixMilli(createdAt)
+	return s, nil
+}
+
+func (db *appdbimpl) DeleteSession(ctx context.Context, userID string) error {
+	res, err := db.w.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID)
+	return checkAffected(res, err)
+}
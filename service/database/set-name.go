@@ -1,7 +1,10 @@
 package database
 
-// SetName is an example that shows you how to execute insert/update
-func (db *appdbimpl) SetName(name string) error {
-	_, err := db.c.Exec("INSERT INTO example_table (id, name) VALUES (1, ?)", name)
-	return err
+import "context"
+
+// SetName is an example that shows you how to execute insert/update. It returns ErrConflict if the name has already
+// been set.
+func (db *appdbimpl) SetName(ctx context.Context, name string) error {
+	_, err := db.w.ExecContext(ctx, "INSERT INTO example_table (id, name) VALUES (1, ?)", name)
+	return translateError(err)
 }
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Stats contains statistics about the database file, for administration tools.
+type Stats struct {
+	// SchemaVersion is the current schema version
+	SchemaVersion int
+
+	// SizeBytes is the size of the database (page count * page size), excluding the journal
+	SizeBytes int64
+
+	// FreeBytes is the size of unused pages, which can be reclaimed with VACUUM
+	FreeBytes int64
+
+	// JournalMode is the SQLite journal mode (e.g., "delete" or "wal")
+	JournalMode string
+
+	// Tables maps each table name to its number of rows
+	Tables map[string]int64
+}
+
+// ReadStats reads the statistics of the database `db`.
+func ReadStats(db *sql.DB) (Stats, error) {
+	var stats = Stats{Tables: map[string]int64{}}
+	var err error
+
+	if stats.SchemaVersion, err = schemaVersion(context.Background(), db); err != nil {
+		return stats, fmt.Errorf("reading schema version: %w", err)
+	}
+
+	var pageCount, pageSize, freePages int64
+	for pragma, dest := range map[string]interface{}{
+		"page_count":     &pageCount,
+		"page_size":      &pageSize,
+		"freelist_count": &freePages,
+		"journal_mode":   &stats.JournalMode,
+	} {
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(dest); err != nil {
+			return stats, fmt.Errorf("reading %s: %w", pragma, err)
+		}
+	}
+	stats.SizeBytes = pageCount * pageSize
+	stats.FreeBytes = freePages * pageSize
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return stats, fmt.Errorf("listing tables: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return stats, fmt.Errorf("listing tables: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("listing tables: %w", err)
+	}
+	_ = rows.Close()
+
+	for _, name := range tables {
+		var count int64
+		// Table names cannot be parameters: quote the identifier
+		query := `SELECT COUNT(*) FROM "` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		if err := db.QueryRow(query).Scan(&count); err != nil {
+			return stats, fmt.Errorf("counting rows in %s: %w", name, err)
+		}
+		stats.Tables[name] = count
+	}
+	return stats, nil
+}
@@ -0,0 +1,19 @@
+package database
+
+import "time"
+
+// Timestamps are stored as Unix time in milliseconds (INTEGER columns). Times returned by AppDatabase methods are in
+// UTC, truncated to milliseconds, so that a value read back is equal to the one returned when it was written.
+
+func toUnixMilli(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+// now returns the current time of the database clock, with the precision of stored timestamps.
+func (db *appdbimpl) now() time.Time {
+	return fromUnixMilli(toUnixMilli(db.clock.Now()))
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+const (
+	// txMaxAttempts is the maximum number of times a transaction is run when the database is busy
+	txMaxAttempts = 5
+
+	// txRetryDelay is the wait before the first retry. It's doubled after every failed attempt
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// WithTx runs fn in a transaction on the writer connection, retrying when SQLite reports that the database is busy or
+// locked. Inside the transaction, both reads and writes go through the transaction.
+func (db *appdbimpl) WithTx(ctx context.Context, fn func(tx AppDatabase) error) error {
+	if db.tx != nil {
+		// Already inside a transaction: join it
+		return fn(db)
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := db.runTx(ctx, fn)
+		if err == nil || !isBusy(err) || attempt == txMaxAttempts {
+			return translateError(err)
+		}
+
+		// The retry delay is not related to the application time, so the real clock is used
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runTx runs fn in a single transaction, rolling back if fn returns an error or panics.
+func (db *appdbimpl) runTx(ctx context.Context, fn func(tx AppDatabase) error) error {
+	tx, err := db.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = fn(&appdbimpl{
+		c:      tx,
+		w:      tx,
+		reader: db.reader,
+		writer: db.writer,
+		tx:     tx,
+		clock:  db.clock,
+	})
+	if err != nil {
+		return err
+	}
+
+	committed = true
+	return tx.Commit()
+}
+
+// isBusy returns true if err is caused by another connection holding a lock on the database.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// User is a registered user.
+type User struct {
+	ID   string
+	Name string
+
+	// Photo is the Base64 profile photo
+	Photo string
+
+	CreatedAt time.Time
+
+	// BannedAt is the time when the user has been banned, zero if the user is not banned
+	BannedAt time.Time
+}
+
+// userColumns are the columns read by scanUser, in order.
+const userColumns = `id, name, photo, created_at, banned_at`
+
+func scanUser(row scanner) (User, error) {
+	var u User
+	var createdAt int64
+	var bannedAt sql.NullInt64
+	if err := row.Scan(&u.ID, &u.Name, &u.Photo, &createdAt, &bannedAt); err != nil {
+		return u, err
+	}
+	u.CreatedAt = fromUnixMilli(createdAt)
+	if bannedAt.Valid {
+		u.BannedAt = fromUnixMilli(bannedAt.Int64)
+	}
+	return u, nil
+}
+
+func (db *appdbimpl) CreateUser(ctx context.Context, id string, name string, photo string) (User, error) {
+	u := User{ID: id, Name: name, Photo: photo, CreatedAt: db.now()}
+	_, err := db.w.ExecContext(ctx, `INSERT INTO users (id, name, photo, created_at) VALUES (?, ?, ?, ?)`,
+		u.ID, u.Name, u.Photo, toUnixMilli(u.CreatedAt))
+	if err != nil {
+		return User{}, translateError(err)
+	}
+	return u, nil
+}
+
+func (db *appdbimpl) GetUser(ctx context.Context, id string) (User, error) {
+	u, err := scanUser(db.c.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+	return u, translateError(err)
+}
+
+func (db *appdbimpl) GetUserByName(ctx context.Context, name string) (User, error) {
+	u, err := scanUser(db.c.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE name = ?`, name))
+	return u, translateError(err)
+}
+
+func (db *appdbimpl) SetUserName(ctx context.Context, id string, name string) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE users SET name = ? WHERE id = ?`, name, id)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SetUserPhoto(ctx context.Context, id string, photo string) error {
+	res, err := db.w.ExecContext(ctx, `UPDATE users SET photo = ? WHERE id = ?`, photo, id)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SetUserBanned(ctx context.Context, id string, banned bool) error {
+	// Banning twice keeps the time of the first ban
+	res, err := db.w.ExecContext(ctx, `UPDATE users SET banned_at = CASE WHEN ? THEN COALESCE(banned_at, ?) END
+		WHERE id = ?`, banned, toUnixMilli(db.now()), id)
+	return checkAffected(res, err)
+}
+
+func (db *appdbimpl) SearchUsers(ctx context.Context, query string, viewerID string, limit int) ([]User, error) {
+	// instr() does not treat any character as a wildcard, unlike LIKE (`_` is valid in names)
+	rows, err := db.c.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+		WHERE instr(lower(name), lower(?)) > 0
+		AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = ? AND blocked_id = users.id)
+		ORDER BY name LIMIT ?`, query, viewerID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never nil, so that an empty result is encoded as an empty JSON array
+	var users = []User{}
+	err = eachRow(rows, func(row scanner) error {
+		u, err := scanUser(row)
+		if err != nil {
+			return err
+		}
+		users = append(users, u)
+		return nil
+	})
+	return users, err
+}
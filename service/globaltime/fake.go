@@ -0,0 +1,192 @@
+package globaltime
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time moves only when Advance or Set are called. Timers, tickers and After channels fire
+// synchronously inside Advance, in order of deadline (and of creation for equal deadlines), so tests are deterministic.
+//
+// Fake is safe for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake returns a Fake clock set at the given time.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// fakeTimer is an event scheduled in the Fake clock. Exactly one of ch and fn is set. A period greater than zero means
+// that the event is a ticker.
+type fakeTimer struct {
+	clock    *Fake
+	deadline time.Time
+	period   time.Duration
+	ch       chan time.Time
+	fn       func()
+}
+
+// Now returns the current time of the fake clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Since returns the time passed since tm, according to the fake clock.
+func (f *Fake) Since(tm time.Time) time.Duration {
+	return f.Now().Sub(tm)
+}
+
+// After returns a channel that receives the fake time once Advance moves it past d. Like time.After, if d is not
+// positive the channel receives the current time immediately.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	t := &fakeTimer{ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- f.Now()
+		return t.ch
+	}
+	f.schedule(t, d)
+	return t.ch
+}
+
+// NewTicker returns a Ticker that ticks every d of fake time. As with time.Ticker, ticks are dropped if the receiver
+// is not keeping up.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{ch: make(chan time.Time, 1), period: d}
+	f.schedule(t, d)
+	return fakeTicker{t}
+}
+
+// AfterFunc schedules f to be called once Advance moves the fake time past d. f is called synchronously by Advance; if
+// d is not positive, f is called by the next Advance (even Advance(0)).
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{fn: fn}
+	f.schedule(t, d)
+	return t
+}
+
+// Pending returns the number of timers and tickers waiting to fire. It's useful in tests to wait for a goroutine to
+// schedule its timers before calling Advance.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// Advance moves the fake time forward by d, firing all timers whose deadline is reached, in order. While a timer
+// fires, Now returns its deadline.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	target := f.now.Add(d)
+	f.mu.Unlock()
+	f.advanceTo(target)
+}
+
+// Set moves the fake time to tm, firing timers like Advance. Moving the time backwards doesn't fire any timer.
+func (f *Fake) Set(tm time.Time) {
+	f.advanceTo(tm)
+}
+
+func (f *Fake) advanceTo(target time.Time) {
+	for {
+		f.mu.Lock()
+		if len(f.timers) == 0 || f.timers[0].deadline.After(target) {
+			f.now = target
+			f.mu.Unlock()
+			return
+		}
+
+		t := f.timers[0]
+		f.timers = f.timers[1:]
+		if t.deadline.After(f.now) {
+			f.now = t.deadline
+		}
+		now := f.now
+		if t.period > 0 {
+			t.deadline = t.deadline.Add(t.period)
+			f.insert(t)
+		}
+		f.mu.Unlock()
+
+		// Fire outside the lock, so callbacks can use the clock
+		if t.fn != nil {
+			t.fn()
+		} else {
+			select {
+			case t.ch <- now:
+			default:
+			}
+		}
+	}
+}
+
+// schedule adds t to the pending timers with a deadline d after the current fake time.
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t.clock = f
+	t.deadline = f.now.Add(d)
+	f.insert(t)
+}
+
+// insert adds t to the pending timers, keeping them sorted by deadline and then by insertion order. The caller must
+// hold f.mu.
+func (f *Fake) insert(t *fakeTimer) {
+	i := sort.Search(len(f.timers), func(i int) bool {
+		return f.timers[i].deadline.After(t.deadline)
+	})
+	f.timers = append(f.timers, nil)
+	copy(f.timers[i+1:], f.timers[i:])
+	f.timers[i] = t
+}
+
+// remove deletes t from the pending timers, returning false if it was not pending. The caller must hold f.mu.
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+// fakeTicker adapts a periodic fakeTimer to the Ticker interface.
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t fakeTicker) Stop() {
+	_ = t.fakeTimer.Stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.clock.remove(t.fakeTimer)
+	t.period = d
+	t.deadline = t.clock.now.Add(d)
+	t.clock.insert(t.fakeTimer)
+}
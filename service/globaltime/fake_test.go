@@ -0,0 +1,221 @@
+package globaltime_test
+
+import (
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+func TestFakeNowAndAdvance(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+	if got := clock.Now(); !got.Equal(epoch) {
+		t.Fatalf("Now() = %v, want %v", got, epoch)
+	}
+	clock.Advance(90 * time.Second)
+	if got := clock.Since(epoch); got != 90*time.Second {
+		t.Fatalf("Since(epoch) = %v, want 90s", got)
+	}
+}
+
+func TestFakeFiresInDeadlineOrder(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+
+	var fired []string
+	record := func(name string) func() {
+		return func() {
+			fired = append(fired, name+"@"+clock.Since(epoch).String())
+		}
+	}
+	clock.AfterFunc(3*time.Second, record("c"))
+	clock.AfterFunc(1*time.Second, record("a"))
+	clock.AfterFunc(2*time.Second, record("b1"))
+	clock.AfterFunc(2*time.Second, record("b2"))
+	clock.AfterFunc(10*time.Second, record("late"))
+
+	clock.Advance(5 * time.Second)
+
+	want := []string{"a@1s", "b1@2s", "b2@2s", "c@3s"}
+	if !reflect.DeepEqual(fired, want) {
+		t.Fatalf("fired = %v, want %v", fired, want)
+	}
+	if got := clock.Now(); !got.Equal(epoch.Add(5 * time.Second)) {
+		t.Fatalf("Now() after Advance = %v, want epoch+5s", got)
+	}
+	if got := clock.Pending(); got != 1 {
+		t.Fatalf("Pending() = %d, want 1", got)
+	}
+}
+
+func TestFakeAfter(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+
+	ch := clock.After(time.Minute)
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("After(1m) fired after 59s")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case tm := <-ch:
+		if !tm.Equal(epoch.Add(time.Minute)) {
+			t.Fatalf("After(1m) sent %v, want epoch+1m", tm)
+		}
+	default:
+		t.Fatal("After(1m) did not fire after 1m")
+	}
+}
+
+func TestFakeAfterZero(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+	select {
+	case tm := <-clock.After(0):
+		if !tm.Equal(epoch) {
+			t.Fatalf("After(0) sent %v, want %v", tm, epoch)
+		}
+	default:
+		t.Fatal("After(0) did not fire immediately")
+	}
+	if got := clock.Pending(); got != 0 {
+		t.Fatalf("Pending() = %d, want 0", got)
+	}
+}
+
+func TestFakeTickerRearms(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+	ticker := clock.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for i := 1; i <= 3; i++ {
+		clock.Advance(10 * time.Second)
+		select {
+		case tm := <-ticker.C():
+			if want := epoch.Add(time.Duration(i) * 10 * time.Second); !tm.Equal(want) {
+				t.Fatalf("tick %d at %v, want %v", i, tm, want)
+			}
+		default:
+			t.Fatalf("tick %d not delivered", i)
+		}
+	}
+
+	// Ticks are dropped when the receiver is not keeping up, like time.Ticker
+	clock.Advance(30 * time.Second)
+	if got := len(ticker.C()); got != 1 {
+		t.Fatalf("buffered ticks = %d, want 1", got)
+	}
+}
+
+func TestFakeTickerStopAndReset(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+	ticker := clock.NewTicker(10 * time.Second)
+
+	clock.Advance(5 * time.Second)
+	ticker.Reset(time.Minute)
+	clock.Advance(55 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before the reset period")
+	default:
+	}
+	clock.Advance(5 * time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("ticker did not fire after the reset period")
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired after Stop")
+	default:
+	}
+	if got := clock.Pending(); got != 0 {
+		t.Fatalf("Pending() after Stop = %d, want 0", got)
+	}
+}
+
+func TestFakeTimerStop(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+	fired := false
+	timer := clock.AfterFunc(time.Second, func() { fired = true })
+
+	if !timer.Stop() {
+		t.Fatal("Stop() on a pending timer = false, want true")
+	}
+	if timer.Stop() {
+		t.Fatal("second Stop() = true, want false")
+	}
+	clock.Advance(time.Minute)
+	if fired {
+		t.Fatal("stopped timer fired")
+	}
+
+	timer = clock.AfterFunc(time.Second, func() {})
+	clock.Advance(time.Second)
+	if timer.Stop() {
+		t.Fatal("Stop() on a fired timer = true, want false")
+	}
+}
+
+func TestFakeCallbackCanScheduleTimers(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+
+	var fired []time.Duration
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, clock.Since(epoch))
+		// Scheduled from inside Advance, with a deadline still in the advanced range
+		clock.AfterFunc(time.Second, func() {
+			fired = append(fired, clock.Since(epoch))
+		})
+	})
+	clock.Advance(5 * time.Second)
+
+	want := []time.Duration{time.Second, 2 * time.Second}
+	if !reflect.DeepEqual(fired, want) {
+		t.Fatalf("fired at %v, want %v", fired, want)
+	}
+}
+
+func TestFakeSetBackwards(t *testing.T) {
+	clock := globaltime.NewFake(epoch)
+	fired := false
+	clock.AfterFunc(10*time.Second, func() { fired = true })
+
+	clock.Set(epoch.Add(-time.Hour))
+	if fired {
+		t.Fatal("timer fired when moving the time backwards")
+	}
+	if got := clock.Now(); !got.Equal(epoch.Add(-time.Hour)) {
+		t.Fatalf("Now() = %v, want epoch-1h", got)
+	}
+
+	// The deadline is absolute: the timer fires when the time reaches it again
+	clock.Set(epoch.Add(9 * time.Second))
+	if fired {
+		t.Fatal("timer fired before its deadline")
+	}
+	clock.Set(epoch.Add(10 * time.Second))
+	if !fired {
+		t.Fatal("timer did not fire at its deadline")
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	clock := globaltime.Real()
+	start := clock.Now()
+	select {
+	case <-clock.After(time.Millisecond):
+	case <-time.After(5 * time.Second):
+		t.Fatal("Real().After(1ms) did not fire")
+	}
+	if clock.Since(start) <= 0 {
+		t.Fatal("Real().Since() is not positive")
+	}
+}
@@ -1,21 +1,92 @@
+/*
+Package globaltime contains the Clock interface, used in place of the functions in the `time` package to allow testing
+with a controllable time.
+
+Code that depends on the current time, or that schedules timers and tickers, should receive a Clock as dependency and
+use it instead of calling `time` functions directly. In production, use Real(); in tests, use NewFake and move the
+time forward with Fake.Advance.
+*/
 package globaltime
 
 import "time"
 
-// FixedTime represent a fixed moment in time. Set this variable to anything different from the default value for
-// time.Time and the value will be returned in Now() function in place of the current time
-var FixedTime time.Time
+// Clock is the interface for reading the time and scheduling events.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Since returns the time passed since tm
+	Since(tm time.Time) time.Duration
+
+	// After waits for the duration to elapse and then sends the current time on the returned channel
+	After(d time.Duration) <-chan time.Time
+
+	// NewTicker returns a new Ticker sending the current time on its channel every d. d must be greater than zero
+	NewTicker(d time.Duration) Ticker
+
+	// AfterFunc waits for the duration to elapse and then calls f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Ticker holds a channel that delivers ticks of a clock at intervals, like time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks will be sent after Stop
+	Stop()
 
-// Now returns the current time (time.Now()) if no FixedTime has been set. Otherwise, it returns FixedTime.
-// Use this in place of time.Now() to allow testing w/ custom time.
+	// Reset stops the ticker and resets its period to d
+	Reset(d time.Duration)
+}
+
+// Timer represents a single event scheduled with AfterFunc, like time.Timer.
+type Timer interface {
+	// Stop prevents the Timer from firing. It returns false if the timer has already fired or been stopped
+	Stop() bool
+}
+
+// Real returns the Clock backed by the `time` package.
+func Real() Clock {
+	return realClock{}
+}
+
+// Now returns the current time using the Real clock.
 func Now() time.Time {
-	if FixedTime.After(time.Time{}) {
-		return FixedTime
-	}
 	return time.Now()
 }
 
-// Since returns the time passed since the parameter tm.
+// Since returns the time passed since the parameter tm, using the Real clock.
 func Since(tm time.Time) time.Duration {
-	return Now().Sub(tm)
+	return time.Since(tm)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(tm time.Time) time.Duration {
+	return time.Since(tm)
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
 }
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"github.com/gorilla/handlers"
+	"net/http"
+)
+
+// CORSConfig contains the configurable part of the CORS policy.
+type CORSConfig struct {
+	// AllowedHeaders is the list of request headers allowed in cross-origin requests
+	AllowedHeaders []string
+
+	// ExposedHeaders is the list of response headers readable by JavaScript in cross-origin requests
+	ExposedHeaders []string
+}
+
+// applyCORSHandler applies a CORS policy to the router. CORS stands for Cross-Origin Resource Sharing: it's a security
+// feature present in web browsers that blocks JavaScript requests going across different domains if not specified in a
+// policy. This function sends the policy of this API server.
+//
+// Credentials (cookies, TLS client certificates) are not allowed, as browsers reject them when the allowed origin is
+// "*". The `Authorization` header is not affected, as it's an allowed header.
+func applyCORSHandler(h http.Handler, cfg CORSConfig) http.Handler {
+	options := []handlers.CORSOption{
+		handlers.AllowedHeaders(cfg.AllowedHeaders),
+		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS", "DELETE", "PUT", "PATCH"}),
+		// Do not modify the CORS origin and max age, they are used in the evaluation.
+		handlers.AllowedOrigins([]string{"*"}),
+		handlers.MaxAge(1),
+	}
+	if len(cfg.ExposedHeaders) > 0 {
+		options = append(options, handlers.ExposedHeaders(cfg.ExposedHeaders))
+	}
+	return handlers.CORS(options...)(h)
+}
@@ -0,0 +1,21 @@
+/*
+Package middleware contains the HTTP handlers that wrap the API router before it's served: the web UI (when embedded)
+and the CORS policy. Both the `webapi` executable and the integration tests use Chain, so that tests see the same
+handler chain as production.
+*/
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Chain wraps the API handler `h` with the web UI (if the executable has been built with the `webui` tag) and then
+// with the CORS policy.
+func Chain(h http.Handler, cors CORSConfig) (http.Handler, error) {
+	h, err := registerWebUI(h)
+	if err != nil {
+		return nil, fmt.Errorf("registering web UI handler: %w", err)
+	}
+	return applyCORSHandler(h, cors), nil
+}
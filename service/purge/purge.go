@@ -0,0 +1,146 @@
+/*
+Package purge permanently removes the deleted messages (tombstones, see database.AppDatabase.DeleteMessage) after a
+retention period. Until then, tombstones keep replies and forwarded copies consistent.
+
+To use this package, create a Purger with New, and stop it with Close when the program terminates:
+
+	purger, err := purge.New(purge.Config{
+		Logger:    logger,
+		Clock:     globaltime.Real(),
+		DB:        appdb,
+		Interval:  time.Hour,
+		Retention: 30 * 24 * time.Hour,
+	})
+	if err != nil {
+		return err
+	}
+	defer purger.Close()
+*/
+package purge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// Config is used to provide dependencies and configuration to the New function.
+type Config struct {
+	// Logger where log entries are sent
+	Logger logrus.FieldLogger
+
+	// Clock is the source of time, for the retention and scheduling
+	Clock globaltime.Clock
+
+	// DB is the database to purge
+	DB database.AppDatabase
+
+	// Interval is the time between purges. It must be greater than zero
+	Interval time.Duration
+
+	// Retention is how long tombstones are kept after the deletion
+	Retention time.Duration
+}
+
+// Purger removes the expired tombstones periodically.
+type Purger struct {
+	cfg Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// mu protects lastErr, the error of the last scheduled purge
+	mu      sync.Mutex
+	lastErr error
+}
+
+// New starts the Purger. The first purge runs after Interval.
+func New(cfg Config) (*Purger, error) {
+	if cfg.Logger == nil {
+		return nil, errors.New("logger is required")
+	}
+	if cfg.Clock == nil {
+		return nil, errors.New("clock is required")
+	}
+	if cfg.DB == nil {
+		return nil, errors.New("database is required")
+	}
+	if cfg.Interval <= 0 {
+		return nil, errors.New("interval must be greater than zero")
+	}
+	if cfg.Retention < 0 {
+		return nil, errors.New("retention must not be negative")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Purger{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.loop(cfg.Clock.NewTicker(cfg.Interval))
+	return p, nil
+}
+
+func (p *Purger) loop(ticker globaltime.Ticker) {
+	defer close(p.done)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C():
+			_, err := p.PurgeNow(p.ctx)
+			if errors.Is(err, context.Canceled) {
+				continue
+			} else if err != nil {
+				p.cfg.Logger.WithError(err).Error("scheduled purge failed")
+			}
+			p.mu.Lock()
+			p.lastErr = err
+			p.mu.Unlock()
+		}
+	}
+}
+
+// PurgeNow removes the tombstones older than the retention, and returns how many messages have been removed.
+func (p *Purger) PurgeNow(ctx context.Context) (int64, error) {
+	n, err := p.cfg.DB.PurgeMessages(ctx, p.cfg.Clock.Now().Add(-p.cfg.Retention))
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		p.cfg.Logger.WithField("messages", n).Info("deleted messages purged")
+	}
+	return n, nil
+}
+
+// Check returns an error if the scheduled purges stopped, or if the last one failed. It can be used as a readiness
+// check.
+func (p *Purger) Check(context.Context) error {
+	select {
+	case <-p.done:
+		return errors.New("purge worker stopped")
+	default:
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lastErr != nil {
+		return fmt.Errorf("last purge failed: %w", p.lastErr)
+	}
+	return nil
+}
+
+// Close stops the scheduled purges, waiting for a running purge to be canceled.
+func (p *Purger) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
@@ -0,0 +1,220 @@
+package purge_test
+
+import (
+	"context"
+	"errors"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/database/inmemory"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/globaltime"
+	"git.sapienzaapps.it/fantasticcoffee/fantastic-coffee-decaffeinated/service/purge"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPurger returns a Purger running every `interval`, with a retention of one day, and a database with a one-to-one
+// conversation between u1 and u2.
+func newPurger(t *testing.T, clock globaltime.Clock, interval time.Duration) (*purge.Purger, database.AppDatabase, int64) {
+	t.Helper()
+	db, err := inmemory.New(clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, id := range []string{"u1", "u2"} {
+		if _, err := db.CreateUser(ctx, id, "user_"+id, "aGVsbG8="); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conversationID, err := db.CreateDirectConversation(ctx, "u1", "u2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p, err := purge.New(purge.Config{
+		Logger:    logger,
+		Clock:     clock,
+		DB:        db,
+		Interval:  interval,
+		Retention: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("creating purger: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p, db, conversationID
+}
+
+// deletedMessage creates a message and deletes it.
+func deletedMessage(t *testing.T, db database.AppDatabase, conversationID int64) int64 {
+	t.Helper()
+	ctx := context.Background()
+	m, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: conversationID, SenderID: "u1", Content: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteMessage(ctx, m.ID); err != nil {
+		t.Fatal(err)
+	}
+	return m.ID
+}
+
+// waitForPurge waits until the message `id` has been removed, as scheduled purges run in another goroutine.
+func waitForPurge(t *testing.T, db database.AppDatabase, id int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err := db.GetMessage(context.Background(), id)
+		if errors.Is(err, database.ErrNotFound) {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("message %d not purged", id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduledPurge(t *testing.T) {
+	clock := globaltime.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, db, conversationID := newPurger(t, clock, time.Hour)
+	old := deletedMessage(t, db, conversationID)
+	clock.Advance(12 * time.Hour)
+	recent := deletedMessage(t, db, conversationID)
+
+	// After 25 hours, only the first tombstone is older than the retention
+	clock.Advance(13 * time.Hour)
+	waitForPurge(t, db, old)
+	if m, err := db.GetMessage(context.Background(), recent); err != nil || m.DeletedAt.IsZero() {
+		t.Fatalf("recent tombstone = %+v, %v; want it kept", m, err)
+	}
+
+	clock.Advance(12 * time.Hour)
+	waitForPurge(t, db, recent)
+}
+
+func TestPurgeNow(t *testing.T) {
+	clock := globaltime.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	// Scheduled purges never run in this test
+	p, db, conversationID := newPurger(t, clock, 1000*time.Hour)
+	ctx := context.Background()
+	id := deletedMessage(t, db, conversationID)
+	kept, err := db.CreateMessage(ctx, database.NewMessage{ConversationID: conversationID, SenderID: "u2",
+		Content: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := p.PurgeNow(ctx); err != nil || n != 0 {
+		t.Fatalf("PurgeNow() before the retention = %d, %v; want 0, nil", n, err)
+	}
+	clock.Advance(24*time.Hour + time.Millisecond)
+	if n, err := p.PurgeNow(ctx); err != nil || n != 1 {
+		t.Fatalf("PurgeNow() after the retention = %d, %v; want 1, nil", n, err)
+	}
+	if _, err := db.GetMessage(ctx, id); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetMessage() after purge: error = %v, want database.ErrNotFound", err)
+	}
+	if _, err := db.GetMessage(ctx, kept.ID); err != nil {
+		t.Fatalf("messages not deleted must be kept: %v", err)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	db, err := inmemory.New(globaltime.Real())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cfg := range []purge.Config{
+		{Clock: globaltime.Real(), DB: db, Interval: time.Hour},
+		{Logger: logrus.New(), DB: db, Interval: time.Hour},
+		{Logger: logrus.New(), Clock: globaltime.Real(), Interval: time.Hour},
+		{Logger: logrus.New(), Clock: globaltime.Real(), DB: db},
+		{Logger: logrus.New(), Clock: globaltime.Real(), DB: db, Interval: time.Hour, Retention: -time.Hour},
+	} {
+		if _, err := purge.New(cfg); err == nil {
+			t.Fatalf("New(%+v) succeeded", cfg)
+		}
+	}
+}
+
+// failingDB is a database whose PurgeMessages fails with `err`, if set.
+type failingDB struct {
+	database.AppDatabase
+
+	mu  sync.Mutex
+	err error
+}
+
+func (db *failingDB) setError(err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.err = err
+}
+
+func (db *failingDB) PurgeMessages(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	db.mu.Lock()
+	err := db.err
+	db.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return db.AppDatabase.PurgeMessages(ctx, deletedBefore)
+}
+
+// waitForCheck waits until the result of Check satisfies `ok`, as scheduled purges run in another goroutine.
+func waitForCheck(t *testing.T, p *purge.Purger, ok func(error) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := p.Check(context.Background())
+		if ok(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected Check() result: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	clock := globaltime.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	inner, err := inmemory.New(clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &failingDB{AppDatabase: inner}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	p, err := purge.New(purge.Config{Logger: logger, Clock: clock, DB: db, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = p.Close() }()
+
+	if err := p.Check(context.Background()); err != nil {
+		t.Fatalf("Check() before any purge = %v, want nil", err)
+	}
+
+	errPurge := errors.New("disk I/O error")
+	db.setError(errPurge)
+	clock.Advance(time.Hour)
+	waitForCheck(t, p, func(err error) bool { return errors.Is(err, errPurge) })
+
+	// A successful purge clears the error
+	db.setError(nil)
+	clock.Advance(time.Hour)
+	waitForCheck(t, p, func(err error) bool { return err == nil })
+
+	_ = p.Close()
+	if err := p.Check(context.Background()); err == nil {
+		t.Fatal("Check() after Close succeeded")
+	}
+}